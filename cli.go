@@ -0,0 +1,832 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"goldenMagic/internal/api"
+	"goldenMagic/internal/config"
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/journal"
+	"goldenMagic/internal/jsonops"
+	"goldenMagic/internal/tree"
+)
+
+// Exit codes of the command-line interface
+const (
+	exitOK         = 0
+	exitFileErrors = 1 // the command ran but at least one file failed
+	exitFailure    = 2 // bad usage, or the command could not run at all
+)
+
+// command is a subcommand of the command-line interface. Setup registers the
+// command's own flags and returns the function that runs it.
+type command struct {
+	name    string
+	args    string
+	summary string
+	mutates bool
+	setup   func(fs *flag.FlagSet) func(c *cli, args []string) (int, error)
+}
+
+// cliOptions holds the flags shared by every subcommand
+type cliOptions struct {
+	basePaths  stringList
+	ext        string
+	keyFilter  string
+	jsonOutput bool
+	verbose    bool
+	dryRun     bool
+	exclude    stringList
+	gitignore  bool
+	hidden     bool
+	lenient    stringList
+}
+
+// cli runs a single subcommand against an App
+type cli struct {
+	app    *App
+	opts   cliOptions
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// stringList is a repeatable flag that also accepts comma or semicolon separated values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		*l = append(*l, strings.TrimSpace(part))
+	}
+	return nil
+}
+
+var commands = []command{
+	{name: "search", summary: "List the files matching the search filters", setup: setupSearch},
+	{name: "keys", summary: "List the keys and paths of the matching files, or complete a prefix", setup: setupKeys},
+	{name: "show", args: "FILE...", summary: "Print the content of JSON files", setup: setupShow},
+	{name: "add", args: "[FILE...]", summary: "Add a key-value pair to the objects at a path", mutates: true, setup: setupAdd},
+	{name: "insert-after", args: "[FILE...]", summary: "Add an object after every occurrence of a target key", mutates: true, setup: setupInsertAfter},
+	{name: "replace-key", args: "[FILE...]", summary: "Rename a key everywhere it occurs", mutates: true, setup: setupReplaceKey},
+	{name: "convert-case", args: "[FILE...]", summary: "Convert key names to camel, snake, kebab or pascal case", mutates: true, setup: setupConvertCase},
+	{name: "delete-key", args: "[FILE...]", summary: "Remove the members selected by a path", mutates: true, setup: setupDeleteKey},
+	{name: "set-value", args: "[FILE...]", summary: "Update or add the value at a path", mutates: true, setup: setupSetValue},
+	{name: "patch", args: "[FILE...]", summary: "Apply an RFC 6902 JSON Patch", mutates: true, setup: setupPatch},
+	{name: "merge-patch", args: "[FILE...]", summary: "Deep-merge an RFC 7386 merge patch", mutates: true, setup: setupMergePatch},
+	{name: "history", summary: "List the batch operations recorded in the undo journal", setup: setupHistory},
+	{name: "undo", summary: "Undo the last batch operation, or the one given by -id", setup: setupUndo},
+	{name: "serve", summary: "Serve the UI and a JSON API over HTTP for any browser", setup: setupServe},
+}
+
+// runCLI runs the subcommand named by the first argument and returns the exit code
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+		return exitOK
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		printUsage(stderr)
+		return exitFailure
+	}
+
+	fs := flag.NewFlagSet("goldenMagic "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var opts cliOptions
+	fs.Var(&opts.basePaths, "base-path", "base path to search (repeatable; defaults to JSON_MANAGER_BASE_PATHS)")
+	fs.StringVar(&opts.ext, "ext", "", "file extension filter, e.g. .json or .golden")
+	fs.StringVar(&opts.keyFilter, "key-filter", "", "only include files matching this query, e.g. errorCode or 'status == \"FAILED\"'")
+	fs.Var(&opts.exclude, "exclude", "gitignore-style pattern to skip while scanning (repeatable)")
+	fs.BoolVar(&opts.gitignore, "gitignore", false, "honor .gitignore files while scanning")
+	fs.BoolVar(&opts.hidden, "hidden", false, "scan hidden directories such as .git")
+	fs.Var(&opts.lenient, "lenient", "extension whose files may contain comments and trailing commas, e.g. .json (repeatable; .jsonc and .json5 always may)")
+	fs.BoolVar(&opts.jsonOutput, "json", false, "print machine-readable JSON")
+	fs.BoolVar(&opts.verbose, "v", false, "log progress to stderr")
+	if cmd.mutates {
+		fs.BoolVar(&opts.dryRun, "dry-run", false, "show a diff of the changes without writing any file")
+	}
+	run := cmd.setup(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: goldenMagic %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitFailure
+	}
+
+	if opts.verbose {
+		log.SetOutput(stderr)
+	} else {
+		log.SetOutput(io.Discard)
+	}
+
+	app, err := opts.newApp()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailure
+	}
+
+	c := &cli{app: app, opts: opts, stdout: stdout, stderr: stderr}
+	code, err := run(c, positional)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitFailure
+	}
+	return code
+}
+
+// parseInterspersed parses flags that may appear before, between or after the
+// positional arguments, and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// printUsage lists the available subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: goldenMagic [command] [flags] [args]\n\n")
+	fmt.Fprintf(w, "Without a command the desktop UI is started.\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-13s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'goldenMagic <command> -h' for the flags of a command.\n")
+	fmt.Fprintf(w, "Exit codes: 0 success, 1 one or more files failed, 2 usage or fatal error.\n")
+}
+
+// newApp creates the App, using the base paths and scan settings from the flags when given
+func (o *cliOptions) newApp() (*App, error) {
+	var cfg *config.Config
+	var err error
+	if len(o.basePaths) == 0 {
+		cfg, err = config.LoadConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %v", err)
+		}
+	} else {
+		cfg, err = config.NewConfig(o.basePaths)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.Exclude = append(cfg.Exclude, o.exclude...)
+	cfg.Gitignore = cfg.Gitignore || o.gitignore
+	cfg.IncludeHidden = cfg.IncludeHidden || o.hidden
+	cfg.LenientExtensions = append(cfg.LenientExtensions, o.lenient...)
+	return NewAppWithConfig(cfg), nil
+}
+
+// targetFiles returns the files given as arguments, or else every file matching the search filters
+func (c *cli) targetFiles(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+
+	files, err := c.search()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match the search filters")
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths, nil
+}
+
+// search returns the files matching the search filters
+func (c *cli) search() ([]fileops.JSONFile, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	root, err := c.app.BrowseFolderContext(ctx, c.opts.ext, c.opts.keyFilter)
+	if err != nil {
+		return nil, err
+	}
+	return tree.FlattenTree(root), nil
+}
+
+// searchValues lists the files with a value matching the search across the base paths
+func (c *cli) searchValues(search fileops.ValueSearch) ([]fileops.JSONFile, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	root, err := c.app.SearchValuesContext(ctx, c.opts.ext, search)
+	if err != nil {
+		return nil, err
+	}
+	return tree.FlattenTree(root), nil
+}
+
+// report prints per-file results and returns exitFileErrors if any file failed.
+// The verb describes what happened to the successful files.
+func (c *cli) report(results any, outcomes []jsonops.Outcome, verb string) (int, error) {
+	if c.opts.jsonOutput {
+		return exitCode(outcomes), c.printJSON(results)
+	}
+
+	changed, skipped, failed := 0, 0, 0
+	var warnings []string
+	for _, outcome := range outcomes {
+		switch {
+		case outcome.Success:
+			changed++
+			if outcome.Warning != "" && !slices.Contains(warnings, outcome.Warning) {
+				warnings = append(warnings, outcome.Warning)
+			}
+			stats := ""
+			if outcome.Change != nil {
+				stats = fmt.Sprintf(" (+%d -%d)", outcome.Change.LinesAdded, outcome.Change.LinesRemoved)
+			}
+			fmt.Fprintf(c.stdout, "ok    %s%s\n", outcome.FilePath, stats)
+			for _, failure := range outcome.RecordErrors {
+				fmt.Fprintf(c.stdout, "      %s%s\n", recordLabel(failure.Record), failure.Error)
+			}
+			if c.opts.dryRun && outcome.Change != nil {
+				fmt.Fprint(c.stdout, outcome.Change.Diff)
+			}
+		case outcome.Skipped:
+			skipped++
+			fmt.Fprintf(c.stdout, "skip  %s: %s\n", outcome.FilePath, outcome.Error)
+		default:
+			failed++
+			fmt.Fprintf(c.stdout, "FAIL  %s: %s\n", outcome.FilePath, outcome.Error)
+		}
+	}
+
+	if c.opts.dryRun {
+		verb = "would change"
+	}
+	fmt.Fprintf(c.stdout, "\n%d %s, %d skipped, %d failed\n", changed, verb, skipped, failed)
+	for _, warning := range warnings {
+		fmt.Fprintf(c.stderr, "warning: %s\n", warning)
+	}
+
+	return exitCode(outcomes), nil
+}
+
+// exitCode returns exitFileErrors if any of the results failed without being skipped
+func exitCode(outcomes []jsonops.Outcome) int {
+	for _, outcome := range outcomes {
+		if !outcome.Success && !outcome.Skipped {
+			return exitFileErrors
+		}
+	}
+	return exitOK
+}
+
+// outcomesOf returns the common shape of the per-file results of an operation
+func outcomesOf[T any, R interface {
+	*T
+	Outcome() jsonops.Outcome
+}](results []T) []jsonops.Outcome {
+	outcomes := make([]jsonops.Outcome, len(results))
+	for i := range results {
+		outcomes[i] = R(&results[i]).Outcome()
+	}
+	return outcomes
+}
+
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// readArg returns a flag value, reading it from a file for "@path" or from stdin for "@-"
+func readArg(value string) (string, error) {
+	switch {
+	case value == "@-":
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	case strings.HasPrefix(value, "@"):
+		data, err := os.ReadFile(value[1:])
+		return string(data), err
+	}
+	return value, nil
+}
+
+// required returns an error naming the first empty flag, given as name-value pairs
+func required(flags ...string) error {
+	for i := 0; i+1 < len(flags); i += 2 {
+		if flags[i+1] == "" {
+			return fmt.Errorf("flag -%s is required", flags[i])
+		}
+	}
+	return nil
+}
+
+func setupSearch(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	var search fileops.ValueSearch
+	var keys stringList
+	fs.StringVar(&search.Pattern, "value", "", "only include files with a string, number or boolean value equal to this")
+	fs.BoolVar(&search.Regex, "regex", false, "treat -value as a regular expression matching any part of the value")
+	fs.BoolVar(&search.IgnoreCase, "i", false, "match -value ignoring case")
+	fs.Var(&keys, "keys", "only search the values of these keys (repeatable)")
+
+	return func(c *cli, args []string) (int, error) {
+		var files []fileops.JSONFile
+		var err error
+		if search.Pattern != "" {
+			search.Keys = keys
+			files, err = c.searchValues(search)
+		} else {
+			files, err = c.search()
+		}
+		if err != nil {
+			return exitFailure, err
+		}
+		if c.opts.jsonOutput {
+			if files == nil {
+				files = []fileops.JSONFile{}
+			}
+			return exitOK, c.printJSON(files)
+		}
+		for _, file := range files {
+			fmt.Fprintln(c.stdout, file.Path)
+			for _, match := range file.Matches {
+				fmt.Fprintf(c.stdout, "  %d:%d  %s%s\n", match.Line, match.Column, recordLabel(match.Record), match.Path)
+			}
+		}
+		return exitOK, nil
+	}
+}
+
+func setupKeys(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	prefix := fs.String("prefix", "", "only list the keys and paths starting with this prefix")
+	kind := fs.String("kind", "", "only list keys (key) or paths (path)")
+	limit := fs.Int("limit", 0, "list at most this many, 0 for all")
+
+	return func(c *cli, args []string) (int, error) {
+		if _, err := c.search(); err != nil {
+			return exitFailure, err
+		}
+		terms, err := c.app.CompleteKeyPath(*prefix, *kind, *limit)
+		if err != nil {
+			return exitFailure, err
+		}
+		if c.opts.jsonOutput {
+			return exitOK, c.printJSON(terms)
+		}
+		for _, term := range terms {
+			fmt.Fprintf(c.stdout, "%6d  %-4s  %s\n", term.Files, term.Kind, term.Text)
+		}
+		return exitOK, nil
+	}
+}
+
+func setupShow(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	return func(c *cli, args []string) (int, error) {
+		if len(args) == 0 {
+			return exitFailure, fmt.Errorf("show needs at least one file")
+		}
+
+		type shown struct {
+			FilePath string `json:"filePath"`
+			Content  string `json:"content,omitempty"`
+			Error    string `json:"error,omitempty"`
+		}
+
+		var results []shown
+		code := exitOK
+		for _, filePath := range args {
+			content, err := c.app.GetJSONFileContent(filePath)
+			result := shown{FilePath: filePath, Content: content}
+			if err != nil {
+				result.Error = err.Error()
+				code = exitFileErrors
+			}
+			results = append(results, result)
+		}
+
+		if c.opts.jsonOutput {
+			return code, c.printJSON(results)
+		}
+		for _, result := range results {
+			if len(results) > 1 {
+				fmt.Fprintf(c.stdout, "==> %s <==\n", result.FilePath)
+			}
+			if result.Error != "" {
+				fmt.Fprintf(c.stdout, "FAIL  %s\n", result.Error)
+				continue
+			}
+			fmt.Fprintln(c.stdout, strings.TrimRight(result.Content, "\n"))
+		}
+		return code, nil
+	}
+}
+
+func setupAdd(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	objectPath := fs.String("path", "", "object path to add to (empty for the root), e.g. user.address or ..address")
+	key := fs.String("key", "", "key to add")
+	value := fs.String("value", "", "JSON value to add (@file or @- to read it)")
+	occurrence := occurrenceFlags(fs, "objects the path selects")
+
+	return func(c *cli, args []string) (int, error) {
+		selector, candidates, err := occurrence()
+		if err != nil {
+			return exitFailure, err
+		}
+		if candidates {
+			files, err := c.targetFiles(args)
+			if err != nil {
+				return exitFailure, err
+			}
+			results, err := c.app.AnalyzeObjectPath(files, *objectPath, selector)
+			if err != nil {
+				return exitFailure, err
+			}
+			return c.printCandidates(results)
+		}
+
+		if err := required("key", *key, "value", *value); err != nil {
+			return exitFailure, err
+		}
+		parsed, err := parseJSONArg(*value)
+		if err != nil {
+			return exitFailure, err
+		}
+		files, err := c.targetFiles(args)
+		if err != nil {
+			return exitFailure, err
+		}
+		results, err := c.app.AddJSONItemToFiles(files, *objectPath, *key, parsed, selector, c.opts.dryRun)
+		if err != nil {
+			return exitFailure, err
+		}
+		return c.report(results, outcomesOf(results), "changed")
+	}
+}
+
+func setupInsertAfter(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	target := fs.String("target", "", "key to insert after")
+	key := fs.String("key", "", "key of the new object")
+	object := fs.String("object", "", "JSON object to insert (@file or @- to read it)")
+	occurrence := occurrenceFlags(fs, "occurrences of the target key")
+
+	return func(c *cli, args []string) (int, error) {
+		selector, candidates, err := occurrence()
+		if err != nil {
+			return exitFailure, err
+		}
+		if candidates {
+			if err := required("target", *target); err != nil {
+				return exitFailure, err
+			}
+			files, err := c.targetFiles(args)
+			if err != nil {
+				return exitFailure, err
+			}
+			results, err := c.app.AnalyzeTargetKey(files, *target, selector)
+			if err != nil {
+				return exitFailure, err
+			}
+			return c.printCandidates(results)
+		}
+
+		if err := required("target", *target, "key", *key, "object", *object); err != nil {
+			return exitFailure, err
+		}
+		objectJSON, err := readArg(*object)
+		if err != nil {
+			return exitFailure, err
+		}
+		files, err := c.targetFiles(args)
+		if err != nil {
+			return exitFailure, err
+		}
+		results, err := c.app.AddJSONItemAfter(files, *target, *key, objectJSON, selector, c.opts.dryRun)
+		if err != nil {
+			return exitFailure, err
+		}
+		return c.report(results, outcomesOf(results), "changed")
+	}
+}
+
+func setupReplaceKey(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	oldKey := fs.String("old", "", "key to rename")
+	newKey := fs.String("new", "", "new key name")
+	var scope jsonops.KeyScope
+	fs.StringVar(&scope.ParentPath, "parent", "", "only rename members of the objects at this path, e.g. 'response.body.items[*]'")
+	fs.IntVar(&scope.Depth, "depth", 0, "only rename keys at this depth, 1 being the root's members")
+
+	return func(c *cli, args []string) (int, error) {
+		if err := required("old", *oldKey, "new", *newKey); err != nil {
+			return exitFailure, err
+		}
+		files, err := c.targetFiles(args)
+		if err != nil {
+			return exitFailure, err
+		}
+		results, err := c.app.ReplaceKeys(*oldKey, *newKey, files, scope, c.opts.dryRun)
+		if err != nil {
+			return exitFailure, err
+		}
+		return c.report(results, outcomesOf(results), "changed")
+	}
+}
+
+func setupConvertCase(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	keyCase := fs.String("case", "", "naming convention: camel, snake, kebab or pascal")
+	path := fs.String("path", "", "only convert keys inside the values at this path, e.g. response.body")
+	var allow, deny stringList
+	fs.Var(&allow, "allow", "only convert these keys (repeatable)")
+	fs.Var(&deny, "deny", "never convert these keys (repeatable)")
+
+	return func(c *cli, args []string) (int, error) {
+		if err := required("case", *keyCase); err != nil {
+			return exitFailure, err
+		}
+		files, err := c.targetFiles(args)
+		if err != nil {
+			return exitFailure, err
+		}
+		results, err := c.app.ConvertKeyCase(*keyCase, *path, allow, deny, files, c.opts.dryRun)
+		if err != nil {
+			return exitFailure, err
+		}
+		return c.report(results, outcomesOf(results), "changed")
+	}
+}
+
+func setupDeleteKey(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	keyPath := fs.String("path", "", "path of the members to remove, e.g. meta.legacy or ..debug")
+
+	return func(c *cli, args []string) (int, error) {
+		if err := required("path", *keyPath); err != nil {
+			return exitFailure, err
+		}
+		files, err := c.targetFiles(args)
+		if err != nil {
+			return exitFailure, err
+		}
+		results, err := c.app.DeleteJSONKeys(*keyPath, files, c.opts.dryRun)
+		if err != nil {
+			return exitFailure, err
+		}
+		return c.report(results, outcomesOf(results), "changed")
+	}
+}
+
+func setupSetValue(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	keyPath := fs.String("path", "", "path of the value to set, e.g. version or items[*].status")
+	value := fs.String("value", "", "JSON value to set (@file or @- to read it)")
+	mode := fs.String("mode", string(jsonops.SetUpsert), "missing, update or upsert")
+
+	return func(c *cli, args []string) (int, error) {
+		if err := required("path", *keyPath, "value", *value); err != nil {
+			return exitFailure, err
+		}
+		parsed, err := parseJSONArg(*value)
+		if err != nil {
+			return exitFailure, err
+		}
+		files, err := c.targetFiles(args)
+		if err != nil {
+			return exitFailure, err
+		}
+		results, err := c.app.SetJSONValues(*keyPath, parsed, *mode, files, c.opts.dryRun)
+		if err != nil {
+			return exitFailure, err
+		}
+		return c.report(results, outcomesOf(results), "changed")
+	}
+}
+
+func setupPatch(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	patch := fs.String("patch", "", "JSON Patch document (@file or @- to read it)")
+
+	return func(c *cli, args []string) (int, error) {
+		if err := required("patch", *patch); err != nil {
+			return exitFailure, err
+		}
+		patchJSON, err := readArg(*patch)
+		if err != nil {
+			return exitFailure, err
+		}
+		files, err := c.targetFiles(args)
+		if err != nil {
+			return exitFailure, err
+		}
+		results, err := c.app.ApplyJSONPatch(patchJSON, files, c.opts.dryRun)
+		if err != nil {
+			return exitFailure, err
+		}
+		return c.report(results, outcomesOf(results), "changed")
+	}
+}
+
+func setupMergePatch(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	patch := fs.String("patch", "", "merge patch object (@file or @- to read it)")
+
+	return func(c *cli, args []string) (int, error) {
+		if err := required("patch", *patch); err != nil {
+			return exitFailure, err
+		}
+		patchJSON, err := readArg(*patch)
+		if err != nil {
+			return exitFailure, err
+		}
+		files, err := c.targetFiles(args)
+		if err != nil {
+			return exitFailure, err
+		}
+		results, err := c.app.MergePatchFiles(patchJSON, files, c.opts.dryRun)
+		if err != nil {
+			return exitFailure, err
+		}
+		return c.report(results, outcomesOf(results), "changed")
+	}
+}
+
+func setupHistory(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	return func(c *cli, args []string) (int, error) {
+		history, err := c.app.GetOperationHistory()
+		if err != nil {
+			return exitFailure, err
+		}
+		if c.opts.jsonOutput {
+			return exitOK, c.printJSON(history)
+		}
+		for _, entry := range history {
+			status := ""
+			if entry.Undone {
+				status = " (undone)"
+			}
+			fmt.Fprintf(c.stdout, "%s  %-20s %d files%s\n", entry.ID, entry.Operation, len(entry.Files), status)
+		}
+		return exitOK, nil
+	}
+}
+
+func setupUndo(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	id := fs.String("id", "", "journal entry to undo (see 'goldenMagic history'); defaults to the last one")
+
+	return func(c *cli, args []string) (int, error) {
+		undo := c.app.UndoLastOperation
+		if *id != "" {
+			undo = func() (*journal.UndoReport, error) { return c.app.UndoOperation(*id) }
+		}
+		report, err := undo()
+		if err != nil {
+			return exitFailure, err
+		}
+		outcomes := make([]jsonops.Outcome, len(report.Results))
+		for i, result := range report.Results {
+			outcomes[i] = jsonops.Outcome{FilePath: result.FilePath, Success: result.Success, Error: result.Error}
+		}
+		if c.opts.jsonOutput {
+			return exitCode(outcomes), c.printJSON(report)
+		}
+		fmt.Fprintf(c.stdout, "Undoing %s (%s)\n", report.Operation, report.ID)
+		return c.report(report.Results, outcomes, "restored")
+	}
+}
+
+func setupServe(fs *flag.FlagSet) func(c *cli, args []string) (int, error) {
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on; use 0.0.0.0:8080 to allow remote browsers")
+
+	return func(c *cli, args []string) (int, error) {
+		// A server is long-running, so always log its operations
+		log.SetOutput(c.stderr)
+
+		token, err := api.NewToken()
+		if err != nil {
+			return exitFailure, err
+		}
+
+		listener, err := net.Listen("tcp", *addr)
+		if err != nil {
+			return exitFailure, err
+		}
+		defer listener.Close()
+
+		fmt.Fprintf(c.stdout, "Serving goldenMagic on http://%s\n", listener.Addr())
+		fmt.Fprintf(c.stdout, "Open http://%s/frontend/ and enter the token when asked\n", listener.Addr())
+		fmt.Fprintf(c.stdout, "Token: %s\n", token)
+		fmt.Fprintf(c.stdout, "API clients must send the header: Authorization: Bearer <token>\n")
+
+		// Any client holding the token can reach the API, so it only sees the base paths
+		c.app.restrictPaths = true
+		return exitOK, http.Serve(listener, newServeMux(c.app, token))
+	}
+}
+
+// occurrenceFlags registers the flags selecting among several candidate locations
+// and returns a func reading the selector and whether only candidates are listed
+func occurrenceFlags(fs *flag.FlagSet, what string) func() (jsonops.Occurrence, bool, error) {
+	occurrence := fs.String("occurrence", "all", "which "+what+" to change: all, first, last or a position from 1")
+	var at stringList
+	fs.Var(&at, "at", "only change the "+what+" at this full path (repeatable)")
+	candidates := fs.Bool("candidates", false, "list the "+what+" per file instead of changing anything")
+
+	return func() (jsonops.Occurrence, bool, error) {
+		if len(at) > 0 {
+			selector := jsonops.Occurrence{Mode: jsonops.OccurrencePaths, Paths: at}
+			return selector, *candidates, selector.Validate()
+		}
+		selector, err := jsonops.ParseOccurrence(*occurrence)
+		return selector, *candidates, err
+	}
+}
+
+// recordLabel names the record of a JSON Lines file a location is in, or returns ""
+// for other files
+func recordLabel(record int) string {
+	if record == 0 {
+		return ""
+	}
+	return fmt.Sprintf("record %d  ", record)
+}
+
+// printCandidates prints the candidate locations per file and flags ambiguous files
+func (c *cli) printCandidates(results []jsonops.FileCandidates) (int, error) {
+	code := exitOK
+	for _, result := range results {
+		if result.Error != "" {
+			code = exitFileErrors
+		}
+	}
+	if c.opts.jsonOutput {
+		return code, c.printJSON(results)
+	}
+
+	ambiguous := 0
+	for _, result := range results {
+		status := "ok   "
+		switch {
+		case result.Error != "":
+			status = "FAIL "
+		case result.Ambiguous:
+			status = "MANY "
+			ambiguous++
+		}
+		fmt.Fprintf(c.stdout, "%s %s (%d)\n", status, result.FilePath, len(result.Candidates))
+		for _, candidate := range result.Candidates {
+			mark := " "
+			if candidate.Selected {
+				mark = "*"
+			}
+			fmt.Fprintf(c.stdout, "  %s %d:%d  %s%s (%s)\n", mark, candidate.Line, candidate.Column, recordLabel(candidate.Record), candidate.Path, candidate.Kind)
+		}
+		if result.Error != "" {
+			fmt.Fprintf(c.stdout, "      %s\n", result.Error)
+		}
+	}
+	fmt.Fprintf(c.stdout, "\n%d files, %d with several candidates; * marks the ones that would change\n", len(results), ambiguous)
+	return code, nil
+}
+
+// parseJSONArg reads a flag value and checks that it is JSON. The value is kept as
+// written, so numbers keep their precision and objects their member order.
+func parseJSONArg(value string) (json.RawMessage, error) {
+	text, err := readArg(value)
+	if err != nil {
+		return nil, err
+	}
+	var parsed json.RawMessage
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid JSON value: %v", err)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,64 @@
+// In serve mode the page is marked with a cookie and there are no Lorca bindings.
+// The same functions are then provided as calls to the JSON API, which takes the
+// arguments as a JSON array and returns the Go result. The token printed by
+// 'goldenMagic serve' is asked for once per browser tab and only ever sent as a header.
+(function () {
+    if (!document.cookie.split('; ').includes('goldenMagicServe=1')) {
+        return;
+    }
+
+    const tokenKey = 'goldenMagicToken';
+    const getToken = () => {
+        let token = sessionStorage.getItem(tokenKey);
+        if (!token) {
+            token = (window.prompt('Enter the token printed by goldenMagic serve') || '').trim();
+            if (token) {
+                sessionStorage.setItem(tokenKey, token);
+            }
+        }
+        return token;
+    };
+
+    // The functions are built from the server's own table of bindings; the page
+    // waits for window.apiReady before its first call
+    const loadRoutes = async () => {
+        const response = await fetch('/api/routes', {
+            headers: { 'Authorization': 'Bearer ' + getToken() },
+        });
+        const body = await response.json();
+        if (!response.ok) {
+            if (response.status === 401) {
+                sessionStorage.removeItem(tokenKey);
+            }
+            throw new Error(body.error || response.statusText);
+        }
+        return body;
+    };
+
+    const bind = (routes) => {
+        for (const [name, route] of Object.entries(routes)) {
+            window[name] = async (...args) => {
+                const response = await fetch('/api/' + route, {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                        'Authorization': 'Bearer ' + getToken(),
+                    },
+                    body: JSON.stringify(args),
+                });
+
+                const body = await response.json();
+                if (response.status === 401) {
+                    // Ask again on the next call, e.g. after the server was restarted
+                    sessionStorage.removeItem(tokenKey);
+                }
+                if (!response.ok) {
+                    throw new Error(body.error || response.statusText);
+                }
+                return body;
+            };
+        }
+    };
+
+    window.apiReady = loadRoutes().then(bind);
+})();
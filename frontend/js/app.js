@@ -38,6 +38,11 @@ function handleError(error, context = 'Operation') {
 // Initialize the application
 async function initializeApp() {
     try {
+        // In serve mode the API functions exist once their routes are loaded
+        if (window.apiReady) {
+            await window.apiReady;
+        }
+
         // Load and display base paths
         await loadBasePaths();
         
@@ -131,6 +136,63 @@ function setupEventListeners() {
             }
         });
     }
+
+    ['valueSearch', 'valueSearchKeys'].forEach(id => {
+        const input = document.getElementById(id);
+        if (input) {
+            input.addEventListener('keypress', function(e) {
+                if (e.key === 'Enter') {
+                    searchFiles();
+                }
+            });
+        }
+    });
+}
+
+// Suggest keys and paths of the current search results while typing in an input
+// marked with data-complete="key" or data-complete="path"
+let completionTimer = null;
+function suggestCompletions(event) {
+    const input = event.target;
+    const kind = input.dataset ? input.dataset.complete : null;
+    if (!kind || typeof window.completeKeyPath !== 'function') {
+        return;
+    }
+
+    clearTimeout(completionTimer);
+    completionTimer = setTimeout(async () => {
+        try {
+            const terms = await window.completeKeyPath(input.value.trim(), kind, 50);
+            const datalist = document.getElementById(kind + '-suggestions');
+            if (datalist) {
+                datalist.innerHTML = terms
+                    .map(term => `<option value="${escapeHTML(term.text)}">${term.files} file${term.files !== 1 ? 's' : ''}</option>`)
+                    .join('');
+            }
+        } catch (error) {
+            console.warn('Completion failed:', error);
+        }
+    }, 150);
+}
+document.addEventListener('input', suggestCompletions);
+document.addEventListener('focusin', suggestCompletions);
+
+// Read the value search inputs, or null when no value is given
+function valueSearchInput() {
+    const pattern = document.getElementById('valueSearch')?.value.trim() || '';
+    if (!pattern) {
+        return null;
+    }
+    const keys = (document.getElementById('valueSearchKeys')?.value || '')
+        .split(',')
+        .map(key => key.trim())
+        .filter(key => key);
+    return {
+        pattern,
+        regex: document.getElementById('valueSearchRegex')?.checked || false,
+        ignoreCase: document.getElementById('valueSearchIgnoreCase')?.checked || false,
+        keys,
+    };
 }
 
 // Enhanced search function with better error handling
@@ -147,7 +209,10 @@ async function searchFiles() {
     try {
         showMessage('🔍 Searching files...', 'info');
         
-        const fileTree = await window.browseFolder(extensionFilter, jsonKeyFilter);
+        const valueSearch = valueSearchInput();
+        const fileTree = valueSearch
+            ? await window.searchValues(extensionFilter, valueSearch)
+            : await window.browseFolder(extensionFilter, jsonKeyFilter);
         
         if (!fileTree) {
             throw new Error('No results returned from search');
@@ -193,7 +258,7 @@ function displayFileTree(tree) {
     // Create tree header with controls
     const headerHTML = `
         <div class="tree-header">
-            <h3>${tree.name} (${tree.count} files)</h3>
+            <h3>${tree.name} (${tree.count} files${tree.matchCount ? `, ${tree.matchCount} matches` : ''})</h3>
             <div class="header-controls">
                 <div class="selection-controls">
                     <label class="checkbox-label">
@@ -201,6 +266,10 @@ function displayFileTree(tree) {
                         Select All (<span id="selected-count">0</span>)
                     </label>
                 </div>
+                <label class="checkbox-label preview-toggle" title="Show a diff of every file and write only after you commit">
+                    <input type="checkbox" id="preview-changes" checked>
+                    🔍 Preview before writing
+                </label>
                 <div class="action-buttons">
                     <button id="add-json-item-to-btn" class="action-btn add-operation" onclick="toggleAddJSONItemToForm()">
                         ➕ Add to Selected
@@ -211,47 +280,160 @@ function displayFileTree(tree) {
                     <button id="replace-key-btn" class="action-btn replace-operation" onclick="toggleReplaceKeyForm()">
                         🔄 Replace Key
                     </button>
+                    <button id="convert-case-btn" class="action-btn replace-operation" onclick="toggleConvertCaseForm()">
+                        🔤 Convert Case
+                    </button>
+                    <button id="set-value-btn" class="action-btn set-operation" onclick="toggleSetValueForm()">
+                        ✏️ Set Value
+                    </button>
+                    <button id="delete-key-btn" class="action-btn delete-operation" onclick="toggleDeleteKeyForm()">
+                        🗑️ Delete Key
+                    </button>
+                    <button id="json-patch-btn" class="action-btn patch-operation" onclick="toggleJSONPatchForm()">
+                        🩹 JSON Patch
+                    </button>
+                    <button id="merge-patch-btn" class="action-btn merge-operation" onclick="toggleMergePatchForm()">
+                        🧬 Merge Patch
+                    </button>
+                    <button id="undo-last-btn" class="action-btn undo-operation" onclick="performUndoLast()">
+                        ↩️ Undo Last
+                    </button>
                 </div>
             </div>
         </div>
+        <datalist id="key-suggestions"></datalist>
+        <datalist id="path-suggestions"></datalist>
         <div id="add-json-item-to-form" class="add-json-item-to-form" style="display: none;">
             <h4>➕ Add Property to Selected Files</h4>
             <div class="form-row">
-                <input type="text" id="add-json-object-path" placeholder="Object path (e.g., user.address or leave empty for root)" />
+                <input type="text" id="add-json-object-path" list="path-suggestions" data-complete="path" autocomplete="off" placeholder="Object path (e.g., user.address, items[*], ..address or leave empty for root)" />
                 <input type="text" id="add-json-key" placeholder="Key name" />
             </div>
             <p class="form-help">💡 This will add the property as the FIRST item in the target objects.</p>
+            <div class="form-row occurrence-row">
+                <select id="add-json-occurrence" title="Which matches to change when there are several">
+                    <option value="all">All matches</option>
+                    <option value="first">First only</option>
+                    <option value="last">Last only</option>
+                    <option value="nth">N-th only</option>
+                    <option value="paths">Only these full paths</option>
+                </select>
+                <input type="text" id="add-json-occurrence-value" placeholder="N (from 1) or full paths separated by ;" />
+            </div>
             <textarea id="add-json-value" placeholder="Value (JSON format, e.g., &quot;string&quot;, 123, {&quot;nested&quot;: true})"></textarea>
             <div class="add-json-item-to-buttons">
                 <button id="perform-add-json-item-to" class="btn btn-primary">➕ Add to Selected Files</button>
+                <button id="candidates-add-json-item-to" class="btn">🔎 Show Candidates</button>
                 <button id="cancel-add-json-item-to" class="btn">Cancel</button>
             </div>
         </div>
         <div id="insert-after-form" class="add-json-item-to-form" style="display: none;">
             <h4>📝 Add Object After Target</h4>
             <div class="form-row">
-                <input type="text" id="target-object-key" placeholder="Target object key (to insert after)" />
+                <input type="text" id="target-object-key" list="key-suggestions" data-complete="key" autocomplete="off" placeholder="Target object key (to insert after)" />
                 <input type="text" id="new-object-key" placeholder="New object key name" />
             </div>
-            <p class="form-help">💡 This will add a complete JSON object AFTER ALL OCCURRENCES of the specified target key. If the target key appears multiple times in a file, the new object will be added after each occurrence.</p>
+            <p class="form-help">💡 This will add a complete JSON object after the selected occurrences of the specified target key, all of them by default. Use "Show Candidates" to see where the key occurs in each file.</p>
+            <div class="form-row occurrence-row">
+                <select id="insert-after-occurrence" title="Which occurrences to change when there are several">
+                    <option value="all">All occurrences</option>
+                    <option value="first">First only</option>
+                    <option value="last">Last only</option>
+                    <option value="nth">N-th only</option>
+                    <option value="paths">Only these full paths</option>
+                </select>
+                <input type="text" id="insert-after-occurrence-value" placeholder="N (from 1) or full paths separated by ;" />
+            </div>
             <textarea id="new-object-json" placeholder="New object JSON (e.g., {&quot;name&quot;: &quot;value&quot;, &quot;nested&quot;: {&quot;key&quot;: true}})"></textarea>
             <div class="add-json-item-to-buttons">
                 <button id="perform-insert-after" class="btn btn-primary">📝 Add After Target</button>
+                <button id="candidates-insert-after" class="btn">🔎 Show Candidates</button>
                 <button id="cancel-insert-after" class="btn">Cancel</button>
             </div>
         </div>
         <div id="replace-key-form" class="add-json-item-to-form" style="display: none;">
             <h4>🔄 Replace Key in Selected Files</h4>
             <div class="form-row">
-                <input type="text" id="old-key-name" placeholder="Old key name (to be replaced)" />
+                <input type="text" id="old-key-name" list="key-suggestions" data-complete="key" autocomplete="off" placeholder="Old key name (to be replaced)" />
                 <input type="text" id="new-key-name" placeholder="New key name (replacement)" />
             </div>
-            <p class="form-help">💡 This will rename all occurrences of the old key to the new key in the selected files using simple text replacement.</p>
+            <div class="form-row">
+                <input type="text" id="replace-key-parent" list="path-suggestions" data-complete="path" autocomplete="off" placeholder="Only under parent path (optional, e.g. response.body.items[*])" />
+                <input type="number" id="replace-key-depth" min="0" placeholder="Depth (optional, 1 = root keys)" />
+            </div>
+            <p class="form-help">💡 This will rename all occurrences of the old key to the new key in the selected files, or only those under the parent path and at the depth given. Keys inside string values are left untouched, and a file is skipped when the new key already exists next to the old one.</p>
             <div class="add-json-item-to-buttons">
                 <button id="perform-replace-key" class="btn btn-primary">🔄 Replace Key</button>
                 <button id="cancel-replace-key" class="btn">Cancel</button>
             </div>
         </div>
+        <div id="convert-case-form" class="add-json-item-to-form" style="display: none;">
+            <h4>🔤 Convert Key Naming Convention</h4>
+            <div class="form-row">
+                <select id="convert-case-case">
+                    <option value="camel">camelCase</option>
+                    <option value="snake">snake_case</option>
+                    <option value="kebab">kebab-case</option>
+                    <option value="pascal">PascalCase</option>
+                </select>
+                <input type="text" id="convert-case-path" list="path-suggestions" data-complete="path" autocomplete="off" placeholder="Only under path (optional, e.g. response.body)" />
+            </div>
+            <div class="form-row">
+                <input type="text" id="convert-case-allow" placeholder="Only these keys (optional, comma separated)" />
+                <input type="text" id="convert-case-deny" placeholder="Never these keys (optional, comma separated)" />
+            </div>
+            <p class="form-help">💡 Renames every key under the path, or in the whole file, to the chosen convention. Keys such as <code>$ref</code> or <code>_id</code> are left alone, and a file is skipped when two keys of one object would end up with the same name.</p>
+            <div class="add-json-item-to-buttons">
+                <button id="perform-convert-case" class="btn btn-primary">🔤 Convert Keys</button>
+                <button id="cancel-convert-case" class="btn">Cancel</button>
+            </div>
+        </div>
+        <div id="set-value-form" class="add-json-item-to-form" style="display: none;">
+            <h4>✏️ Set Value in Selected Files</h4>
+            <div class="form-row">
+                <input type="text" id="set-value-path" list="path-suggestions" data-complete="path" autocomplete="off" placeholder="Key path (e.g., version, features.darkMode or items[*].status)" />
+                <select id="set-value-mode">
+                    <option value="upsert">Upsert (update or add)</option>
+                    <option value="update">Update only (key must exist)</option>
+                    <option value="missing">Add only (key must be missing)</option>
+                </select>
+            </div>
+            <p class="form-help">💡 Existing values are replaced in place; missing keys are added as the LAST member of their object.</p>
+            <textarea id="set-value-value" placeholder="Value (JSON format, e.g., &quot;2.0&quot;, true, {&quot;enabled&quot;: false})"></textarea>
+            <div class="add-json-item-to-buttons">
+                <button id="perform-set-value" class="btn btn-primary">✏️ Set Value</button>
+                <button id="cancel-set-value" class="btn">Cancel</button>
+            </div>
+        </div>
+        <div id="delete-key-form" class="add-json-item-to-form" style="display: none;">
+            <h4>🗑️ Delete Key from Selected Files</h4>
+            <div class="form-row">
+                <input type="text" id="delete-key-path" list="path-suggestions" data-complete="path" autocomplete="off" placeholder="Key path (e.g., meta.legacy, items[*].debug or ..deprecated)" />
+            </div>
+            <p class="form-help">💡 This will remove every member matched by the path. A key under an array is removed from every object in that array.</p>
+            <div class="add-json-item-to-buttons">
+                <button id="perform-delete-key" class="btn btn-primary">🗑️ Delete Key</button>
+                <button id="cancel-delete-key" class="btn">Cancel</button>
+            </div>
+        </div>
+        <div id="json-patch-form" class="add-json-item-to-form" style="display: none;">
+            <h4>🩹 Apply JSON Patch to Selected Files</h4>
+            <p class="form-help">💡 Paste an RFC 6902 JSON Patch (add, remove, replace, move, copy, test). Operations run in order; a file is only changed when every operation succeeds.</p>
+            <textarea id="json-patch-value" placeholder="[{&quot;op&quot;: &quot;test&quot;, &quot;path&quot;: &quot;/version&quot;, &quot;value&quot;: &quot;1.0&quot;}, {&quot;op&quot;: &quot;replace&quot;, &quot;path&quot;: &quot;/version&quot;, &quot;value&quot;: &quot;2.0&quot;}]"></textarea>
+            <div class="add-json-item-to-buttons">
+                <button id="perform-json-patch" class="btn btn-primary">🩹 Apply Patch</button>
+                <button id="cancel-json-patch" class="btn">Cancel</button>
+            </div>
+        </div>
+        <div id="merge-patch-form" class="add-json-item-to-form" style="display: none;">
+            <h4>🧬 Merge Patch into Selected Files</h4>
+            <p class="form-help">💡 Paste an RFC 7386 merge patch object. Members are added or overwritten, nested objects are merged, and <code>null</code> deletes a key. Untouched parts of each file keep their formatting.</p>
+            <textarea id="merge-patch-value" placeholder="{&quot;meta&quot;: {&quot;region&quot;: &quot;eu&quot;, &quot;legacy&quot;: null}}"></textarea>
+            <div class="add-json-item-to-buttons">
+                <button id="perform-merge-patch" class="btn btn-primary">🧬 Merge Patch</button>
+                <button id="cancel-merge-patch" class="btn">Cancel</button>
+            </div>
+        </div>
     `;
     
     // Create tree content
@@ -308,6 +490,16 @@ function setupFormEventListeners() {
         performInsertAfterBtn.addEventListener('click', performInsertAfter);
     }
     
+    const candidatesAddJSONItemToBtn = document.getElementById('candidates-add-json-item-to');
+    if (candidatesAddJSONItemToBtn) {
+        candidatesAddJSONItemToBtn.addEventListener('click', showAddCandidates);
+    }
+
+    const candidatesInsertAfterBtn = document.getElementById('candidates-insert-after');
+    if (candidatesInsertAfterBtn) {
+        candidatesInsertAfterBtn.addEventListener('click', showInsertAfterCandidates);
+    }
+
     const cancelAddJSONItemToBtn = document.getElementById('cancel-add-json-item-to');
     if (cancelAddJSONItemToBtn) {
         cancelAddJSONItemToBtn.addEventListener('click', toggleAddJSONItemToForm);
@@ -327,6 +519,56 @@ function setupFormEventListeners() {
     if (cancelReplaceKeyBtn) {
         cancelReplaceKeyBtn.addEventListener('click', toggleReplaceKeyForm);
     }
+    
+    const performConvertCaseBtn = document.getElementById('perform-convert-case');
+    if (performConvertCaseBtn) {
+        performConvertCaseBtn.addEventListener('click', performConvertCase);
+    }
+    
+    const cancelConvertCaseBtn = document.getElementById('cancel-convert-case');
+    if (cancelConvertCaseBtn) {
+        cancelConvertCaseBtn.addEventListener('click', toggleConvertCaseForm);
+    }
+    
+    const performSetValueBtn = document.getElementById('perform-set-value');
+    if (performSetValueBtn) {
+        performSetValueBtn.addEventListener('click', performSetValue);
+    }
+    
+    const cancelSetValueBtn = document.getElementById('cancel-set-value');
+    if (cancelSetValueBtn) {
+        cancelSetValueBtn.addEventListener('click', toggleSetValueForm);
+    }
+    
+    const performDeleteKeyBtn = document.getElementById('perform-delete-key');
+    if (performDeleteKeyBtn) {
+        performDeleteKeyBtn.addEventListener('click', performDeleteKey);
+    }
+    
+    const cancelDeleteKeyBtn = document.getElementById('cancel-delete-key');
+    if (cancelDeleteKeyBtn) {
+        cancelDeleteKeyBtn.addEventListener('click', toggleDeleteKeyForm);
+    }
+
+    const performJSONPatchBtn = document.getElementById('perform-json-patch');
+    if (performJSONPatchBtn) {
+        performJSONPatchBtn.addEventListener('click', performJSONPatch);
+    }
+
+    const cancelJSONPatchBtn = document.getElementById('cancel-json-patch');
+    if (cancelJSONPatchBtn) {
+        cancelJSONPatchBtn.addEventListener('click', toggleJSONPatchForm);
+    }
+
+    const performMergePatchBtn = document.getElementById('perform-merge-patch');
+    if (performMergePatchBtn) {
+        performMergePatchBtn.addEventListener('click', performMergePatch);
+    }
+
+    const cancelMergePatchBtn = document.getElementById('cancel-merge-patch');
+    if (cancelMergePatchBtn) {
+        cancelMergePatchBtn.addEventListener('click', toggleMergePatchForm);
+    }
 }
 
 // Render a tree node (supports multiple base paths)
@@ -365,6 +607,7 @@ function renderTreeNode(node, depth) {
                                 📄 ${file.name}
                             </span>
                             <span class="file-path" title="${file.path}">${file.path}</span>
+                            ${file.matchCount ? '<span class="file-matches" title="' + escapeHTML(file.matches.map(m => `${m.record ? 'record ' + m.record + ': ' : ''}${m.path} (line ${m.line}, col ${m.column})`).join('\n')) + '">' + file.matchCount + ' match' + (file.matchCount !== 1 ? 'es' : '') + '</span>' : ''}
                             ${file.basePath ? '<span class="file-base-path" title="From: ' + file.basePath + '">📂</span>' : ''}
                         </div>
                         <div id="${fileId}" class="inline-file-content" style="display: none; margin-left: 20px; margin-top: 10px; border-left: 3px solid #3b82f6; padding-left: 15px; background: #f8fafc;"></div>
@@ -389,6 +632,12 @@ function renderTreeNode(node, depth) {
     return html;
 }
 
+// Returns the JSON key filter when it is a plain key name, or '' for paths and queries
+function filterKeyName() {
+    const filter = document.getElementById('jsonKeyFilter').value.trim();
+    return /^[\w$-]+$/.test(filter) ? filter : '';
+}
+
 // Flatten file tree to get all files
 function flattenFileTree(node) {
     let files = [];
@@ -493,9 +742,15 @@ async function loadFileContentInline(filePath, containerId) {
         const content = await window.getJSONFileContent(filePath);
         console.log('File content loaded successfully, length:', content.length);
         
-        // Display content inline
-        displayFileContentInline(filePath, content, container);
+        // Display content inline, highlighting the matches of the last search
+        const file = allFiles.find(f => f.path === filePath);
+        displayFileContentInline(filePath, content, container, file ? file.matches : null);
         container.style.display = 'block';
+
+        const firstMatch = container.querySelector('.json-line-match');
+        if (firstMatch) {
+            firstMatch.scrollIntoView({ block: 'center' });
+        }
         
         showMessage('✅ File loaded successfully', 'success');
         
@@ -560,8 +815,8 @@ async function loadFileContent(filePath) {
 }
 
 // Display file content inline in the provided container
-function displayFileContentInline(filePath, content, container) {
-    const formattedContent = formatJsonContent(content);
+function displayFileContentInline(filePath, content, container, matches) {
+    const formattedContent = formatJsonContent(content, matches, filePath);
     const fileName = filePath.split(/[\\\/]/).pop();
     
     container.innerHTML = `
@@ -589,7 +844,7 @@ function displayFileContent(filePath, content) {
         return;
     }
     
-    const formattedContent = formatJsonContent(content);
+    const formattedContent = formatJsonContent(content, null, filePath);
     
     // Show the container
     contentContainer.style.display = 'block';
@@ -617,19 +872,42 @@ function displayFileContent(filePath, content) {
     showMessage('✅ File content loaded successfully', 'success');
 }
 
-// Format JSON content with syntax highlighting and line numbers
-function formatJsonContent(jsonString) {
+// Format JSON content with syntax highlighting and line numbers. With search
+// matches the original layout is kept so their line numbers stay valid.
+function formatJsonContent(jsonString, matches, filePath) {
     try {
-        const parsed = JSON.parse(jsonString);
-        const formatted = JSON.stringify(parsed, null, 2);
+        // YAML files have been validated by the backend and are shown as written
+        const yaml = isYamlFile(filePath);
+
+        // Files with comments or trailing commas (JSONC, JSON5) have been validated
+        // by the backend and are shown as written, like files with matches. So are
+        // JSON Lines files, one record per line next to its record number.
+        const records = isJsonLines(jsonString);
+        let formatted = jsonString;
+        if (!(matches && matches.length) && !isLenientContent(jsonString) && !records && !yaml) {
+            formatted = JSON.stringify(JSON.parse(jsonString), null, 2);
+        }
         const lines = formatted.split('\n');
+
+        const matchedLines = new Set();
+        (matches || []).forEach(match => {
+            for (let line = match.line; line <= match.endLine; line++) {
+                matchedLines.add(line);
+            }
+        });
         
         let result = '<div class="json-lines">';
+        let recordNumber = 0;
         lines.forEach((line, index) => {
             const lineNumber = index + 1;
-            const highlightedLine = highlightJsonSyntax(line);
-            result += `<div class="json-line">`;
+            const highlightedLine = yaml ? highlightYamlSyntax(line) : highlightJsonSyntax(line);
+            result += `<div class="json-line${matchedLines.has(lineNumber) ? ' json-line-match' : ''}">`;
             result += `<span class="line-number">${lineNumber}</span>`;
+            if (records) {
+                const isRecord = line.trim() !== '';
+                recordNumber += isRecord ? 1 : 0;
+                result += `<span class="record-number" title="Record">${isRecord ? '#' + recordNumber : ''}</span>`;
+            }
             result += `<span class="line-content">${highlightedLine}</span>`;
             result += `</div>`;
         });
@@ -641,8 +919,57 @@ function formatJsonContent(jsonString) {
     }
 }
 
+// Check whether content uses lenient syntax that JSON.parse rejects, such as comments
+function isLenientContent(jsonString) {
+    try {
+        JSON.parse(jsonString);
+        return false;
+    } catch (error) {
+        return /\/\/|\/\*|,\s*[}\]]|'|[{,]\s*[A-Za-z_$][\w$]*\s*:/.test(jsonString);
+    }
+}
+
+// Check whether content holds one JSON document per line (JSON Lines, NDJSON)
+function isJsonLines(jsonString) {
+    try {
+        JSON.parse(jsonString);
+        return false;
+    } catch (error) {
+        const lines = jsonString.split('\n').filter(line => line.trim() !== '');
+        return lines.length > 1 && lines.every(line => {
+            try {
+                JSON.parse(line);
+                return true;
+            } catch (lineError) {
+                return false;
+            }
+        });
+    }
+}
+
+// Check whether a file holds YAML, by its extension
+function isYamlFile(filePath) {
+    return /\.ya?ml$/i.test(filePath || '');
+}
+
+// Highlight YAML syntax: comments, keys and anchors or aliases
+function highlightYamlSyntax(line) {
+    const trimmed = line.trim();
+    if (trimmed.startsWith('#')) {
+        return `<span class="json-comment">${escapeHTML(line)}</span>`;
+    }
+    return escapeHTML(line)
+        .replace(/^(\s*(?:-\s+)?)([^\s:#-][^:#]*?)(:)(?=\s|$)/, '$1<span class="json-key">$2</span>$3')
+        .replace(/(\s)(#.*)$/, '$1<span class="json-comment">$2</span>')
+        .replace(/(\s)(&amp;[\w-]+|\*[\w-]+)/g, '$1<span class="json-null">$2</span>');
+}
+
 // Highlight JSON syntax
 function highlightJsonSyntax(line) {
+    const trimmed = line.trim();
+    if (trimmed.startsWith('//') || trimmed.startsWith('/*') || trimmed.startsWith('*')) {
+        return `<span class="json-comment">${escapeHTML(line)}</span>`;
+    }
     return line
         .replace(/(".*?")(\s*:)/g, '<span class="json-key">$1</span>$2')
         .replace(/:\s*(".*?")/g, ': <span class="json-string">$1</span>')
@@ -679,6 +1006,15 @@ async function copyJsonToClipboard(filePath) {
     }
 }
 
+// Hide every mass operation form except the given one
+function hideOperationForms(exceptId) {
+    document.querySelectorAll('.add-json-item-to-form').forEach(form => {
+        if (form.id !== exceptId) {
+            form.style.display = 'none';
+        }
+    });
+}
+
 // Add JSON item functionality
 function toggleAddJSONItemToForm() {
     const form = document.getElementById('add-json-item-to-form');
@@ -689,11 +1025,11 @@ function toggleAddJSONItemToForm() {
     } else {
         form.style.display = 'block';
         
-        // Auto-populate object path from JSON key filter
-        const jsonKeyFilter = document.getElementById('jsonKeyFilter').value.trim();
+        // Auto-populate object path from JSON key filter (matching the key at any depth)
+        const jsonKeyFilter = filterKeyName();
         const objectPathInput = document.getElementById('add-json-object-path');
         if (jsonKeyFilter && objectPathInput) {
-            objectPathInput.value = jsonKeyFilter;
+            objectPathInput.value = '..' + jsonKeyFilter;
         }
         
         // Focus on the first input
@@ -702,17 +1038,8 @@ function toggleAddJSONItemToForm() {
             firstInput.focus();
         }
         
-        // Hide insert after form if open
-        const insertAfterForm = document.getElementById('insert-after-form');
-        if (insertAfterForm && insertAfterForm.style.display === 'block') {
-            insertAfterForm.style.display = 'none';
-        }
-        
-        // Hide replace key form if open
-        const replaceKeyForm = document.getElementById('replace-key-form');
-        if (replaceKeyForm && replaceKeyForm.style.display === 'block') {
-            replaceKeyForm.style.display = 'none';
-        }
+        // Hide the other operation forms if open
+        hideOperationForms('add-json-item-to-form');
     }
 }
 
@@ -743,19 +1070,27 @@ async function performAddJSONItemTo() {
         showMessage(`➕ Adding property to ${filePaths.length} files across multiple paths...`, 'info');
         
         // Call the backend function
-        const results = await window.addJSONItemToFiles(filePaths, objectPath, key, value);
+        const dryRun = isPreviewEnabled();
+        const results = await window.addJSONItemToFiles(filePaths, objectPath, key, value, readOccurrence('add-json'), dryRun);
+        
+        // Show the diff preview instead of a summary when nothing was written
+        if (dryRun) {
+            showChangePreview(results);
+            return;
+        }
+        warnResults(results);
         
         // Process results
         let successCount = 0;
         let errorCount = 0;
         const errors = [];
         
-        for (const [filePath, result] of Object.entries(results)) {
-            if (result === 'SUCCESS') {
+        for (const result of results) {
+            if (result.success) {
                 successCount++;
             } else {
                 errorCount++;
-                errors.push(`${filePath}: ${result}`);
+                errors.push(`${result.filePath}: ${result.error}`);
             }
         }
         
@@ -793,7 +1128,7 @@ function toggleInsertAfterForm() {
         form.style.display = 'block';
         
         // Auto-populate target key from JSON key filter
-        const jsonKeyFilter = document.getElementById('jsonKeyFilter').value.trim();
+        const jsonKeyFilter = filterKeyName();
         const targetKeyInput = document.getElementById('target-object-key');
         if (jsonKeyFilter && targetKeyInput) {
             targetKeyInput.value = jsonKeyFilter;
@@ -805,17 +1140,8 @@ function toggleInsertAfterForm() {
             firstInput.focus();
         }
         
-        // Hide add JSON item form if open
-        const addJSONItemToForm = document.getElementById('add-json-item-to-form');
-        if (addJSONItemToForm && addJSONItemToForm.style.display === 'block') {
-            addJSONItemToForm.style.display = 'none';
-        }
-        
-        // Hide replace key form if open
-        const replaceKeyForm = document.getElementById('replace-key-form');
-        if (replaceKeyForm && replaceKeyForm.style.display === 'block') {
-            replaceKeyForm.style.display = 'none';
-        }
+        // Hide the other operation forms if open
+        hideOperationForms('insert-after-form');
     }
 }
 
@@ -829,7 +1155,7 @@ function toggleReplaceKeyForm() {
         form.style.display = 'block';
         
         // Auto-populate old key name from JSON key filter
-        const jsonKeyFilter = document.getElementById('jsonKeyFilter').value.trim();
+        const jsonKeyFilter = filterKeyName();
         const oldKeyInput = document.getElementById('old-key-name');
         if (jsonKeyFilter && oldKeyInput) {
             oldKeyInput.value = jsonKeyFilter;
@@ -841,17 +1167,8 @@ function toggleReplaceKeyForm() {
             firstInput.focus();
         }
         
-        // Hide add JSON item form if open
-        const addJSONItemToForm = document.getElementById('add-json-item-to-form');
-        if (addJSONItemToForm && addJSONItemToForm.style.display === 'block') {
-            addJSONItemToForm.style.display = 'none';
-        }
-        
-        // Hide insert after form if open
-        const insertAfterForm = document.getElementById('insert-after-form');
-        if (insertAfterForm && insertAfterForm.style.display === 'block') {
-            insertAfterForm.style.display = 'none';
-        }
+        // Hide the other operation forms if open
+        hideOperationForms('replace-key-form');
     }
 }
 
@@ -883,7 +1200,19 @@ async function performReplaceKey() {
         showMessage(`🔄 Replacing "${oldKeyName}" with "${newKeyName}" in ${filePaths.length} files...`, 'info');
         
         // Call the backend function
-        const results = await window.replaceKeys(oldKeyName, newKeyName, filePaths);
+        const dryRun = isPreviewEnabled();
+        const scope = {
+            parentPath: document.getElementById('replace-key-parent').value.trim(),
+            depth: parseInt(document.getElementById('replace-key-depth').value, 10) || 0,
+        };
+        const results = await window.replaceKeys(oldKeyName, newKeyName, filePaths, scope, dryRun);
+        
+        // Show the diff preview instead of a summary when nothing was written
+        if (dryRun) {
+            showChangePreview(results);
+            return;
+        }
+        warnResults(results);
         
         // Process results
         let successCount = 0;
@@ -936,101 +1265,829 @@ async function performReplaceKey() {
     }
 }
 
-async function performInsertAfter() {
-    const targetKey = document.getElementById('target-object-key').value.trim();
-    const newObjectKey = document.getElementById('new-object-key').value.trim();
-    const newObjectJSON = document.getElementById('new-object-json').value.trim();
+function toggleConvertCaseForm() {
+    const form = document.getElementById('convert-case-form');
+    const isVisible = form.style.display === 'block';
+    
+    if (isVisible) {
+        form.style.display = 'none';
+    } else {
+        form.style.display = 'block';
+        hideOperationForms('convert-case-form');
+    }
+}
 
-    if (!targetKey || !newObjectKey || !newObjectJSON) {
-        showMessage('❌ Please fill in all fields', 'error');
+// Split a comma separated list of keys
+function keyList(id) {
+    return document.getElementById(id).value
+        .split(',')
+        .map(key => key.trim())
+        .filter(key => key);
+}
+
+async function performConvertCase() {
+    const keyCase = document.getElementById('convert-case-case').value;
+    const path = document.getElementById('convert-case-path').value.trim();
+
+    try {
+        const filePaths = getSelectedFiles().map(file => file.path);
+        if (filePaths.length === 0) {
+            showMessage('❌ Please select at least one file', 'error');
+            return;
+        }
+
+        showMessage(`🔤 Converting keys to ${keyCase} case in ${filePaths.length} files...`, 'info');
+
+        const dryRun = isPreviewEnabled();
+        const results = await window.convertKeyCase(keyCase, path, keyList('convert-case-allow'), keyList('convert-case-deny'), filePaths, dryRun);
+
+        // Show the diff preview instead of a summary when nothing was written
+        if (dryRun) {
+            showChangePreview(results);
+            return;
+        }
+        warnResults(results);
+
+        const succeeded = results.filter(result => result.success);
+        const collided = results.filter(result => result.collisions && result.collisions.length > 0);
+        const totalRenames = succeeded.reduce((sum, result) => sum + result.replacementCount, 0);
+
+        if (succeeded.length === results.length) {
+            showMessage(`✅ Converted ${totalRenames} keys in ${succeeded.length} files`, 'success');
+        } else {
+            showMessage(`⚠️ Converted keys in ${succeeded.length} files, ${results.length - succeeded.length} unchanged (${collided.length} with collisions). Check console for details.`, 'error');
+            console.error('Convert case errors:', results.filter(result => !result.success));
+        }
+
+        toggleConvertCaseForm();
+    } catch (error) {
+        console.error('Error in performConvertCase:', error);
+        showMessage('❌ Error during case conversion: ' + error.message, 'error');
+    }
+}
+
+function toggleSetValueForm() {
+    const form = document.getElementById('set-value-form');
+    const isVisible = form.style.display === 'block';
+    
+    if (isVisible) {
+        form.style.display = 'none';
+    } else {
+        form.style.display = 'block';
+        
+        // Focus on the first input
+        const firstInput = form.querySelector('input');
+        if (firstInput) {
+            firstInput.focus();
+        }
+        
+        // Hide the other operation forms if open
+        hideOperationForms('set-value-form');
+    }
+}
+
+async function performSetValue() {
+    const keyPath = document.getElementById('set-value-path').value.trim();
+    const mode = document.getElementById('set-value-mode').value;
+    const valueStr = document.getElementById('set-value-value').value.trim();
+
+    if (!keyPath || !valueStr) {
+        showMessage('❌ Please enter both key path and value', 'error');
         return;
     }
 
     try {
-        // Validate JSON
-        JSON.parse(newObjectJSON);
+        // Parse the value as JSON
+        const value = JSON.parse(valueStr);
         
         // Get selected file paths
         const selectedFiles = getSelectedFiles();
-        const filePaths = selectedFiles.map(file => file.path);
-        
-        if (filePaths.length === 0) {
+        if (selectedFiles.length === 0) {
             showMessage('❌ Please select at least one file', 'error');
             return;
         }
 
+        const filePaths = selectedFiles.map(file => file.path);
+
         // Show progress message
-        showMessage(`➕ Adding "${newObjectKey}" after all occurrences of "${targetKey}" in ${filePaths.length} files...`, 'info');
+        showMessage(`✏️ Setting "${keyPath}" in ${filePaths.length} files...`, 'info');
         
         // Call the backend function
-        const results = await window.addJSONItemAfter(filePaths, targetKey, newObjectKey, newObjectJSON);
+        const dryRun = isPreviewEnabled();
+        const results = await window.setJSONValues(keyPath, value, mode, filePaths, dryRun);
         
-        // Check if results is valid
-        if (!results || typeof results !== 'object') {
-            throw new Error('Invalid response from backend: ' + String(results));
+        // Show the diff preview instead of a summary when nothing was written
+        if (dryRun) {
+            showChangePreview(results);
+            return;
         }
+        warnResults(results);
         
         // Process results
         let successCount = 0;
-        let errorCount = 0;
         let skippedCount = 0;
+        let errorCount = 0;
+        let updatedCount = 0;
+        let insertedCount = 0;
         const errors = [];
-        const successDetails = [];
         const skippedDetails = [];
 
-        for (const [filePath, result] of Object.entries(results)) {
-            if (result === 'SUCCESS') {
+        for (const result of results) {
+            if (result.success) {
                 successCount++;
-                successDetails.push(filePath);
-            } else if (typeof result === 'string' && result.startsWith('SKIPPED:')) {
+                updatedCount += result.updatedCount;
+                insertedCount += result.insertedCount;
+            } else if (result.skipped) {
                 skippedCount++;
-                skippedDetails.push({ filePath, reason: result });
+                skippedDetails.push({ filePath: result.filePath, reason: result.error });
             } else {
                 errorCount++;
-                errors.push({ filePath, error: result });
+                errors.push({ filePath: result.filePath, error: result.error });
             }
         }
 
-        // Show results with more detailed feedback
+        const summary = `${updatedCount} updated, ${insertedCount} added`;
         if (errorCount === 0 && skippedCount === 0) {
-            showMessage(`✅ Successfully added "${newObjectKey}" after all occurrences of "${targetKey}" in ${successCount} files`, 'success');
-            console.log('Successfully processed files:', successDetails);
-        } else if (errorCount === 0 && skippedCount > 0) {
-            showMessage(`⚠️ Added to ${successCount} files, ${skippedCount} skipped (duplicates detected). Check console for details.`, 'warning');
-            console.log('Successfully processed files:', successDetails);
-            console.log('Skipped files (duplicates):', skippedDetails);
+            showMessage(`✅ Set "${keyPath}" in ${successCount} files (${summary})`, 'success');
+        } else if (errorCount === 0) {
+            showMessage(`⚠️ Set in ${successCount} files (${summary}), ${skippedCount} skipped. Check console for details.`, 'warning');
+            console.log('Skipped files:', skippedDetails);
         } else {
-            const totalProcessed = successCount + skippedCount;
-            showMessage(`⚠️ Processed ${totalProcessed} files (${successCount} added, ${skippedCount} skipped), ${errorCount} failed. Check console for details.`, 'error');
-            console.error('Insert after errors:', errors);
-            if (successCount > 0) {
-                console.log('Successfully processed files:', successDetails);
-            }
+            showMessage(`⚠️ Set in ${successCount} files, ${skippedCount} skipped, ${errorCount} failed. Check console for details.`, 'error');
+            console.error('Set value errors:', errors);
             if (skippedCount > 0) {
-                console.log('Skipped files (duplicates):', skippedDetails);
+                console.log('Skipped files:', skippedDetails);
             }
         }
         
         // Clear and close form
-        document.getElementById('target-object-key').value = '';
-        document.getElementById('new-object-key').value = '';
-        document.getElementById('new-object-json').value = '';
+        document.getElementById('set-value-path').value = '';
+        document.getElementById('set-value-value').value = '';
+        toggleSetValueForm();
         
-        toggleInsertAfterForm();
+    } catch (error) {
+        console.error('Error in performSetValue:', error);
+        showMessage('❌ Error during set value: ' + error.message, 'error');
+    }
+}
+
+function toggleDeleteKeyForm() {
+    const form = document.getElementById('delete-key-form');
+    const isVisible = form.style.display === 'block';
+    
+    if (isVisible) {
+        form.style.display = 'none';
+    } else {
+        form.style.display = 'block';
         
+        // Auto-populate key path from JSON key filter (matching the key at any depth)
+        const jsonKeyFilter = filterKeyName();
+        const keyPathInput = document.getElementById('delete-key-path');
+        if (jsonKeyFilter && keyPathInput) {
+            keyPathInput.value = '..' + jsonKeyFilter;
+        }
         
-        // Clear the search filter inputs to allow for a fresh search
-        document.getElementById('fileExtension').value = '';
-        document.getElementById('jsonKeyFilter').value = '';
-
-        console.log("T1001")
+        // Focus on the first input
+        const firstInput = form.querySelector('input');
+        if (firstInput) {
+            firstInput.focus();
+        }
         
-    } catch (error) {
-        showMessage('❌ Error during insert after: ' + error.message, 'error');
+        // Hide the other operation forms if open
+        hideOperationForms('delete-key-form');
     }
 }
 
-// Show toast messages using Toastify
+async function performDeleteKey() {
+    const keyPath = document.getElementById('delete-key-path').value.trim();
+
+    if (!keyPath) {
+        showMessage('❌ Please enter a key path', 'error');
+        return;
+    }
+
+    try {
+        // Get selected file paths
+        const selectedFiles = getSelectedFiles();
+        if (selectedFiles.length === 0) {
+            showMessage('❌ Please select at least one file', 'error');
+            return;
+        }
+
+        const filePaths = selectedFiles.map(file => file.path);
+
+        // Show progress message
+        showMessage(`🗑️ Deleting "${keyPath}" from ${filePaths.length} files...`, 'info');
+        
+        // Call the backend function
+        const dryRun = isPreviewEnabled();
+        const results = await window.deleteJSONKeys(keyPath, filePaths, dryRun);
+        
+        // Show the diff preview instead of a summary when nothing was written
+        if (dryRun) {
+            showChangePreview(results);
+            return;
+        }
+        warnResults(results);
+        
+        // Process results
+        let successCount = 0;
+        let errorCount = 0;
+        let totalDeletions = 0;
+        const errors = [];
+
+        for (const result of results) {
+            if (result.success) {
+                successCount++;
+                totalDeletions += result.deletedCount;
+            } else {
+                errorCount++;
+                errors.push({ 
+                    filePath: result.filePath, 
+                    error: result.error 
+                });
+            }
+        }
+
+        if (errorCount === 0) {
+            showMessage(`✅ Successfully deleted "${keyPath}" from ${successCount} files (${totalDeletions} total deletions)`, 'success');
+        } else {
+            showMessage(`⚠️ Deleted from ${successCount} files, ${errorCount} failed. Check console for details.`, 'error');
+            console.error('Delete key errors:', errors);
+        }
+        
+        // Clear and close form
+        document.getElementById('delete-key-path').value = '';
+        toggleDeleteKeyForm();
+        
+    } catch (error) {
+        console.error('Error in performDeleteKey:', error);
+        showMessage('❌ Error during delete key: ' + error.message, 'error');
+    }
+}
+
+function toggleJSONPatchForm() {
+    const form = document.getElementById('json-patch-form');
+    const isVisible = form.style.display === 'block';
+    
+    if (isVisible) {
+        form.style.display = 'none';
+    } else {
+        form.style.display = 'block';
+        
+        // Focus on the patch textarea
+        const textarea = form.querySelector('textarea');
+        if (textarea) {
+            textarea.focus();
+        }
+        
+        // Hide the other operation forms if open
+        hideOperationForms('json-patch-form');
+    }
+}
+
+async function performJSONPatch() {
+    const patchJSON = document.getElementById('json-patch-value').value.trim();
+
+    if (!patchJSON) {
+        showMessage('❌ Please enter a JSON Patch', 'error');
+        return;
+    }
+
+    try {
+        // Validate JSON
+        try {
+            const patch = JSON.parse(patchJSON);
+            if (!Array.isArray(patch)) {
+                showMessage('❌ A JSON Patch must be an array of operations', 'error');
+                return;
+            }
+        } catch (e) {
+            showMessage('❌ Invalid JSON Patch: ' + e.message, 'error');
+            return;
+        }
+
+        // Get selected file paths
+        const selectedFiles = getSelectedFiles();
+        if (selectedFiles.length === 0) {
+            showMessage('❌ Please select at least one file', 'error');
+            return;
+        }
+
+        const filePaths = selectedFiles.map(file => file.path);
+
+        // Show progress message
+        showMessage(`🩹 Applying JSON Patch to ${filePaths.length} files...`, 'info');
+        
+        // Call the backend function
+        const dryRun = isPreviewEnabled();
+        const results = await window.applyJSONPatch(patchJSON, filePaths, dryRun);
+        
+        // Show the diff preview instead of a summary when nothing was written
+        if (dryRun) {
+            showChangePreview(results);
+            return;
+        }
+        warnResults(results);
+        
+        // Process results
+        let successCount = 0;
+        let errorCount = 0;
+        const errors = [];
+
+        for (const result of results) {
+            if (result.success) {
+                successCount++;
+            } else {
+                errorCount++;
+                errors.push({ 
+                    filePath: result.filePath, 
+                    failedOp: result.failedOp,
+                    error: result.error 
+                });
+            }
+        }
+
+        if (errorCount === 0) {
+            showMessage(`✅ Successfully patched ${successCount} files`, 'success');
+        } else {
+            showMessage(`⚠️ Patched ${successCount} files, ${errorCount} failed. Check console for details.`, 'error');
+            console.error('JSON Patch errors:', errors);
+        }
+        
+        // Clear and close form
+        document.getElementById('json-patch-value').value = '';
+        toggleJSONPatchForm();
+        
+    } catch (error) {
+        console.error('Error in performJSONPatch:', error);
+        showMessage('❌ Error during JSON Patch: ' + error.message, 'error');
+    }
+}
+
+function toggleMergePatchForm() {
+    const form = document.getElementById('merge-patch-form');
+    const isVisible = form.style.display === 'block';
+    
+    if (isVisible) {
+        form.style.display = 'none';
+    } else {
+        form.style.display = 'block';
+        
+        // Focus on the patch textarea
+        const textarea = form.querySelector('textarea');
+        if (textarea) {
+            textarea.focus();
+        }
+        
+        // Hide the other operation forms if open
+        hideOperationForms('merge-patch-form');
+    }
+}
+
+async function performMergePatch() {
+    const patchJSON = document.getElementById('merge-patch-value').value.trim();
+
+    if (!patchJSON) {
+        showMessage('❌ Please enter a merge patch', 'error');
+        return;
+    }
+
+    try {
+        // Validate JSON
+        try {
+            JSON.parse(patchJSON);
+        } catch (e) {
+            showMessage('❌ Invalid merge patch: ' + e.message, 'error');
+            return;
+        }
+
+        // Get selected file paths
+        const selectedFiles = getSelectedFiles();
+        if (selectedFiles.length === 0) {
+            showMessage('❌ Please select at least one file', 'error');
+            return;
+        }
+
+        const filePaths = selectedFiles.map(file => file.path);
+
+        // Show progress message
+        showMessage(`🧬 Merging patch into ${filePaths.length} files...`, 'info');
+        
+        // Call the backend function
+        const dryRun = isPreviewEnabled();
+        const results = await window.mergePatchFiles(patchJSON, filePaths, dryRun);
+        
+        // Show the diff preview instead of a summary when nothing was written
+        if (dryRun) {
+            showChangePreview(results);
+            return;
+        }
+        warnResults(results);
+        
+        // Process results
+        let successCount = 0;
+        let skippedCount = 0;
+        let errorCount = 0;
+        let totalChanges = 0;
+        const errors = [];
+
+        for (const result of results) {
+            if (result.success) {
+                successCount++;
+                totalChanges += result.addedCount + result.updatedCount + result.removedCount;
+            } else if (result.skipped) {
+                skippedCount++;
+            } else {
+                errorCount++;
+                errors.push({ 
+                    filePath: result.filePath, 
+                    error: result.error 
+                });
+            }
+        }
+
+        const skippedNote = skippedCount > 0 ? `, ${skippedCount} already up to date` : '';
+        if (errorCount === 0) {
+            showMessage(`✅ Merged patch into ${successCount} files (${totalChanges} total changes${skippedNote})`, 'success');
+        } else {
+            showMessage(`⚠️ Merged into ${successCount} files${skippedNote}, ${errorCount} failed. Check console for details.`, 'error');
+            console.error('Merge patch errors:', errors);
+        }
+        
+        // Clear and close form
+        document.getElementById('merge-patch-value').value = '';
+        toggleMergePatchForm();
+        
+    } catch (error) {
+        console.error('Error in performMergePatch:', error);
+        showMessage('❌ Error during merge patch: ' + error.message, 'error');
+    }
+}
+
+async function performInsertAfter() {
+    const targetKey = document.getElementById('target-object-key').value.trim();
+    const newObjectKey = document.getElementById('new-object-key').value.trim();
+    const newObjectJSON = document.getElementById('new-object-json').value.trim();
+
+    if (!targetKey || !newObjectKey || !newObjectJSON) {
+        showMessage('❌ Please fill in all fields', 'error');
+        return;
+    }
+
+    try {
+        // Validate JSON
+        JSON.parse(newObjectJSON);
+        
+        // Get selected file paths
+        const selectedFiles = getSelectedFiles();
+        const filePaths = selectedFiles.map(file => file.path);
+        
+        if (filePaths.length === 0) {
+            showMessage('❌ Please select at least one file', 'error');
+            return;
+        }
+
+        // Show progress message
+        showMessage(`➕ Adding "${newObjectKey}" after occurrences of "${targetKey}" in ${filePaths.length} files...`, 'info');
+        
+        // Call the backend function
+        const dryRun = isPreviewEnabled();
+        const results = await window.addJSONItemAfter(filePaths, targetKey, newObjectKey, newObjectJSON, readOccurrence('insert-after'), dryRun);
+        
+        // Show the diff preview instead of a summary when nothing was written
+        if (dryRun) {
+            showChangePreview(results);
+            return;
+        }
+        warnResults(results);
+        
+        // Check if results is valid
+        if (!Array.isArray(results)) {
+            throw new Error('Invalid response from backend: ' + String(results));
+        }
+        
+        // Process results
+        let successCount = 0;
+        let errorCount = 0;
+        let skippedCount = 0;
+        const errors = [];
+        const successDetails = [];
+        const skippedDetails = [];
+
+        for (const result of results) {
+            if (result.success) {
+                successCount++;
+                successDetails.push(result.filePath);
+            } else if (result.skipped) {
+                skippedCount++;
+                skippedDetails.push({ filePath: result.filePath, reason: result.error });
+            } else {
+                errorCount++;
+                errors.push({ filePath: result.filePath, error: result.error });
+            }
+        }
+
+        // Show results with more detailed feedback
+        if (errorCount === 0 && skippedCount === 0) {
+            showMessage(`✅ Successfully added "${newObjectKey}" after all occurrences of "${targetKey}" in ${successCount} files`, 'success');
+            console.log('Successfully processed files:', successDetails);
+        } else if (errorCount === 0 && skippedCount > 0) {
+            showMessage(`⚠️ Added to ${successCount} files, ${skippedCount} skipped (duplicates detected). Check console for details.`, 'warning');
+            console.log('Successfully processed files:', successDetails);
+            console.log('Skipped files (duplicates):', skippedDetails);
+        } else {
+            const totalProcessed = successCount + skippedCount;
+            showMessage(`⚠️ Processed ${totalProcessed} files (${successCount} added, ${skippedCount} skipped), ${errorCount} failed. Check console for details.`, 'error');
+            console.error('Insert after errors:', errors);
+            if (successCount > 0) {
+                console.log('Successfully processed files:', successDetails);
+            }
+            if (skippedCount > 0) {
+                console.log('Skipped files (duplicates):', skippedDetails);
+            }
+        }
+        
+        // Clear and close form
+        document.getElementById('target-object-key').value = '';
+        document.getElementById('new-object-key').value = '';
+        document.getElementById('new-object-json').value = '';
+        
+        toggleInsertAfterForm();
+        
+        
+        // Clear the search filter inputs to allow for a fresh search
+        document.getElementById('fileExtension').value = '';
+        document.getElementById('jsonKeyFilter').value = '';
+
+        console.log("T1001")
+        
+    } catch (error) {
+        showMessage('❌ Error during insert after: ' + error.message, 'error');
+    }
+}
+
+// Show toast messages using Toastify
+async function performUndoLast() {
+    try {
+        const history = await window.getOperationHistory();
+        const last = (history || []).find(entry => !entry.undone);
+        if (!last) {
+            showMessage('ℹ️ There is no operation to undo', 'info');
+            return;
+        }
+
+        const when = new Date(last.timestamp).toLocaleString();
+        if (!confirm(`Undo ${last.operation} from ${when}? This restores ${last.files.length} file(s).`)) {
+            return;
+        }
+
+        const report = await window.undoOperation(last.id);
+
+        const restored = report.results.filter(result => result.success).length;
+        const refused = report.results.filter(result => !result.success);
+
+        if (refused.length === 0) {
+            showMessage(`↩️ Undid ${report.operation}: restored ${restored} files`, 'success');
+        } else {
+            showMessage(`⚠️ Restored ${restored} files, ${refused.length} changed since and were left alone. Check console for details.`, 'error');
+            console.error('Undo errors:', refused);
+        }
+    } catch (error) {
+        console.error('Error in performUndoLast:', error);
+        showMessage('❌ Error during undo: ' + error.message, 'error');
+    }
+}
+
+// Changes returned by the last dry run, waiting to be committed
+let pendingChanges = [];
+
+function isPreviewEnabled() {
+    const checkbox = document.getElementById('preview-changes');
+    return checkbox ? checkbox.checked : false;
+}
+
+// Read the occurrence selector of a form, e.g. {mode: 'nth', n: 2}
+function readOccurrence(prefix) {
+    const mode = document.getElementById(prefix + '-occurrence')?.value || 'all';
+    const value = (document.getElementById(prefix + '-occurrence-value')?.value || '').trim();
+    switch (mode) {
+        case 'nth':
+            return { mode, n: parseInt(value, 10) || 0 };
+        case 'paths':
+            return { mode, paths: value.split(';').map(path => path.trim()).filter(path => path) };
+        default:
+            return { mode };
+    }
+}
+
+async function showAddCandidates() {
+    const objectPath = document.getElementById('add-json-object-path').value.trim();
+    const filePaths = getSelectedFiles().map(file => file.path);
+    if (filePaths.length === 0) {
+        showMessage('❌ No files selected', 'error');
+        return;
+    }
+    try {
+        const results = await window.analyzeObjectPath(filePaths, objectPath, readOccurrence('add-json'));
+        showCandidateReport(results, 'add-json', objectPath || '$');
+    } catch (error) {
+        handleError(error, 'Candidate analysis failed');
+    }
+}
+
+async function showInsertAfterCandidates() {
+    const targetKey = document.getElementById('target-object-key').value.trim();
+    const filePaths = getSelectedFiles().map(file => file.path);
+    if (!targetKey || filePaths.length === 0) {
+        showMessage('❌ Please enter a target key and select files', 'error');
+        return;
+    }
+    try {
+        const results = await window.analyzeTargetKey(filePaths, targetKey, readOccurrence('insert-after'));
+        showCandidateReport(results, 'insert-after', targetKey);
+    } catch (error) {
+        handleError(error, 'Candidate analysis failed');
+    }
+}
+
+// Show the candidate locations per file. Ticking candidates selects them by full path.
+function showCandidateReport(results, prefix, target) {
+    const container = document.getElementById('change-preview');
+    const ambiguous = results.filter(result => result.ambiguous).length;
+
+    const filesHTML = results.map(result => `
+        <div class="change-file">
+            <div class="change-file-header">
+                <span class="change-file-path">${escapeHTML(result.filePath)}</span>
+                <span class="change-stats">${result.candidates.length} candidate${result.candidates.length !== 1 ? 's' : ''}${result.ambiguous ? ' ⚠️' : ''}</span>
+            </div>
+            ${result.error ? `<div class="change-unchanged">${escapeHTML(result.error)}</div>` : ''}
+            <ul class="candidate-list">
+                ${result.candidates.map(candidate => `
+                    <li>
+                        <label class="checkbox-label">
+                            <input type="checkbox" class="candidate-path" value="${escapeHTML(candidate.path)}" ${candidate.selected ? 'checked' : ''}>
+                            <code>${escapeHTML(candidate.path)}</code> <span class="candidate-position">line ${candidate.line}, col ${candidate.column}, ${candidate.kind}</span>
+                        </label>
+                    </li>
+                `).join('')}
+            </ul>
+        </div>
+    `).join('');
+
+    container.innerHTML = `
+        <div class="file-content-header">
+            <h3>🔎 Candidates for "${escapeHTML(target)}": ${results.length} file(s), ${ambiguous} with several</h3>
+            <div class="add-json-item-to-buttons">
+                <button class="btn btn-primary" onclick="useCandidateSelection('${prefix}')">Use Ticked Paths</button>
+                <button class="btn" onclick="discardPendingChanges()">Close</button>
+            </div>
+        </div>
+        <p class="form-help">Ticked candidates are the ones the current occurrence setting would change.</p>
+        ${filesHTML}
+    `;
+    container.style.display = 'block';
+    container.scrollIntoView({ behavior: 'smooth' });
+}
+
+// Select the ticked candidates by their full paths in the form's occurrence setting
+function useCandidateSelection(prefix) {
+    const paths = [...new Set([...document.querySelectorAll('#change-preview .candidate-path:checked')].map(input => input.value))];
+    document.getElementById(prefix + '-occurrence').value = 'paths';
+    document.getElementById(prefix + '-occurrence-value').value = paths.join('; ');
+    showMessage(`🎯 ${paths.length} path(s) selected`, 'success');
+}
+
+function showChangePreview(results) {
+    const container = document.getElementById('change-preview');
+    const changed = results.filter(result => result.success && result.change);
+    pendingChanges = changed.map(result => result.change);
+    const unchanged = results.filter(result => !result.success);
+
+    const totalAdded = pendingChanges.reduce((sum, change) => sum + change.linesAdded, 0);
+    const totalRemoved = pendingChanges.reduce((sum, change) => sum + change.linesRemoved, 0);
+
+    const filesHTML = changed.map(({ change, recordErrors }) => `
+        <div class="change-file">
+            <div class="change-file-header">
+                <span class="change-file-path">${escapeHTML(change.filePath)}</span>
+                <span class="change-stats"><span class="lines-added">+${change.linesAdded}</span> <span class="lines-removed">-${change.linesRemoved}</span></span>
+            </div>
+            ${renderRecordErrors(recordErrors)}
+            <pre class="diff">${renderDiff(change.diff)}</pre>
+        </div>
+    `).join('');
+
+    const unchangedHTML = unchanged.length === 0 ? '' : `
+        <div class="change-unchanged">
+            <strong>${unchanged.length} file(s) will not change:</strong>
+            <ul>${unchanged.map(result => `<li>${escapeHTML(result.filePath)}: ${escapeHTML(result.error || 'no changes')}</li>`).join('')}</ul>
+        </div>
+    `;
+
+    container.innerHTML = `
+        <div class="file-content-header">
+            <h3>🔍 Preview: ${pendingChanges.length} file(s), <span class="lines-added">+${totalAdded}</span> <span class="lines-removed">-${totalRemoved}</span></h3>
+            <div class="add-json-item-to-buttons">
+                <button class="btn btn-primary" onclick="commitPendingChanges()" ${pendingChanges.length === 0 ? 'disabled' : ''}>💾 Commit Changes</button>
+                <button class="btn" onclick="discardPendingChanges()">Discard</button>
+            </div>
+        </div>
+        ${unchangedHTML}
+        ${filesHTML}
+    `;
+    container.style.display = 'block';
+    container.scrollIntoView({ behavior: 'smooth' });
+
+    showMessage(`🔍 Previewing changes to ${pendingChanges.length} files. Nothing has been written yet.`, 'info');
+}
+
+// List the records of a JSON Lines file that the operation failed on and left unchanged
+function renderRecordErrors(recordErrors) {
+    if (!recordErrors || recordErrors.length === 0) {
+        return '';
+    }
+    return `
+        <div class="change-unchanged">
+            <strong>${recordErrors.length} record(s) will not change:</strong>
+            <ul>${recordErrors.map(failure => `<li>record ${failure.record}: ${escapeHTML(failure.error)}</li>`).join('')}</ul>
+        </div>
+    `;
+}
+
+// Log the records of JSON Lines files that an operation failed on and left unchanged,
+// and show the warnings of files that were written anyway
+function warnResults(results) {
+    const failures = results.filter(result => result.success && result.recordErrors && result.recordErrors.length > 0);
+    if (failures.length > 0) {
+        console.warn('Records left unchanged:', failures.map(result => ({ filePath: result.filePath, recordErrors: result.recordErrors })));
+    }
+
+    // E.g. files written without completing their undo journal entry
+    const warned = results.find(result => result.success && result.warning);
+    if (warned) {
+        showMessage('⚠️ ' + warned.warning, 'warning');
+    }
+}
+
+function renderDiff(diff) {
+    return diff.split('\n').map(line => {
+        let cssClass = '';
+        if (line.startsWith('+++') || line.startsWith('---')) {
+            cssClass = 'diff-file';
+        } else if (line.startsWith('@@')) {
+            cssClass = 'diff-hunk';
+        } else if (line.startsWith('+')) {
+            cssClass = 'diff-add';
+        } else if (line.startsWith('-')) {
+            cssClass = 'diff-remove';
+        }
+        return `<span class="${cssClass}">${escapeHTML(line)}</span>`;
+    }).join('\n');
+}
+
+function escapeHTML(text) {
+    return String(text)
+        .replace(/&/g, '&amp;')
+        .replace(/</g, '&lt;')
+        .replace(/>/g, '&gt;')
+        .replace(/"/g, '&quot;');
+}
+
+async function commitPendingChanges() {
+    if (pendingChanges.length === 0) {
+        return;
+    }
+
+    try {
+        const results = await window.commitChanges(pendingChanges);
+        warnResults(results);
+
+        const committed = results.filter(result => result.success).length;
+        const refused = results.filter(result => !result.success);
+
+        if (refused.length === 0) {
+            showMessage(`✅ Committed changes to ${committed} files`, 'success');
+        } else {
+            showMessage(`⚠️ Committed ${committed} files, ${refused.length} refused. Check console for details.`, 'error');
+            console.error('Commit errors:', refused);
+        }
+
+        discardPendingChanges();
+    } catch (error) {
+        console.error('Error in commitPendingChanges:', error);
+        showMessage('❌ Error during commit: ' + error.message, 'error');
+    }
+}
+
+function discardPendingChanges() {
+    pendingChanges = [];
+    const container = document.getElementById('change-preview');
+    container.innerHTML = '';
+    container.style.display = 'none';
+}
+
 function showMessage(message, type = 'info') {
     // Convert message to string if it's not already
     const messageStr = String(message);
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// maxBodySize bounds the size of a request body (patches and previews can be large)
+const maxBodySize = 64 * 1024 * 1024
+
+// Binding exposes a Go function under a JavaScript name and an HTTP route
+type Binding struct {
+	Name  string // name of the JavaScript function, e.g. "replaceKeys"
+	Route string // route below /api/, e.g. "operations/replace-key"
+	Func  any
+
+	// Mutates marks calls that may write files; they never run alongside other calls
+	Mutates bool
+}
+
+// RoutesRoute lists the bindings as a JSON object from JavaScript name to route, so
+// the browser builds its functions from the same table as the server. No binding may
+// use it.
+const RoutesRoute = "routes"
+
+// errorResponse is the body of a failed API call
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewToken returns a random per-session token
+func NewToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewHandler serves the bindings as POST /api/<route>. The request body is a JSON
+// array holding the function's arguments, the same way the Lorca bindings are
+// called, and the response is the function's result encoded as JSON. Every request
+// must carry the token as "Authorization: Bearer <token>". Calls that mutate files
+// run one at a time; the others run concurrently. GET /api/routes lists the bindings.
+func NewHandler(bindings []Binding, token string) http.Handler {
+	routes := make(map[string]Binding, len(bindings))
+	names := make(map[string]string, len(bindings))
+	for _, b := range bindings {
+		routes[b.Route] = b
+		names[b.Name] = b.Route
+	}
+
+	var mu sync.RWMutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid token"})
+			return
+		}
+
+		route := strings.TrimPrefix(r.URL.Path, "/api/")
+		if route == RoutesRoute && r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, names)
+			return
+		}
+
+		binding, ok := routes[route]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("unknown endpoint %s", r.URL.Path)})
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use POST"})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("failed to read request: %v", err)})
+			return
+		}
+
+		fn := reflect.ValueOf(binding.Func)
+		args, err := decodeArgs(fn.Type(), body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		lock, unlock := mu.RLock, mu.RUnlock
+		if binding.Mutates {
+			lock, unlock = mu.Lock, mu.Unlock
+		}
+		lock()
+		result, err := call(fn, args)
+		unlock()
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+}
+
+// decodeArgs decodes a JSON array into the parameters of a function
+func decodeArgs(fnType reflect.Type, body []byte) ([]reflect.Value, error) {
+	var raw []json.RawMessage
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, fmt.Errorf("request body must be a JSON array of arguments: %v", err)
+		}
+	}
+	if len(raw) != fnType.NumIn() {
+		return nil, fmt.Errorf("expected %d arguments, got %d", fnType.NumIn(), len(raw))
+	}
+
+	args := make([]reflect.Value, len(raw))
+	for i, arg := range raw {
+		value := reflect.New(fnType.In(i))
+		if err := json.Unmarshal(arg, value.Interface()); err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i, err)
+		}
+		args[i] = value.Elem()
+	}
+	return args, nil
+}
+
+// call invokes a function and splits its results into a value and an error.
+// Functions may return nothing, a value, an error, or a value and an error.
+func call(fn reflect.Value, args []reflect.Value) (any, error) {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+
+	var result any
+	for _, out := range fn.Call(args) {
+		if out.Type() == errorType {
+			if !out.IsNil() {
+				return nil, out.Interface().(error)
+			}
+			continue
+		}
+		result = out.Interface()
+	}
+	return result, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/joho/godotenv"
@@ -13,6 +14,15 @@ import (
 // Config holds the application configuration
 type Config struct {
 	BasePaths []string
+
+	// Scanning
+	Exclude         []string            // gitignore-style patterns excluded below every base path
+	BasePathExclude map[string][]string // additional patterns for a single base path
+	Gitignore       bool                // honor .gitignore files while scanning
+	IncludeHidden   bool                // scan hidden directories such as .git
+
+	// Parsing
+	LenientExtensions []string // extensions whose files may contain comments and trailing commas, besides .jsonc and .json5
 }
 
 // ConfigError represents configuration-related errors
@@ -49,6 +59,10 @@ func LoadConfig() (*Config, error) {
 		BasePaths: basePaths,
 	}
 
+	if err := config.loadScanSettings(); err != nil {
+		return nil, err
+	}
+
 	// Validate configuration
 	if err := config.Validate(); err != nil {
 		return nil, err
@@ -57,6 +71,91 @@ func LoadConfig() (*Config, error) {
 	return config, nil
 }
 
+// loadScanSettings reads the exclude patterns, scan flags and lenient extensions
+// from the environment. JSON_MANAGER_EXCLUDE applies to every base path and
+// JSON_MANAGER_EXCLUDE_<n> to the n-th base path only.
+func (c *Config) loadScanSettings() error {
+	c.Exclude = splitList(os.Getenv("JSON_MANAGER_EXCLUDE"))
+	c.LenientExtensions = splitList(os.Getenv("JSON_MANAGER_LENIENT_EXTENSIONS"))
+
+	c.BasePathExclude = make(map[string][]string)
+	for i, basePath := range c.BasePaths {
+		if patterns := splitList(os.Getenv(fmt.Sprintf("JSON_MANAGER_EXCLUDE_%d", i+1))); len(patterns) > 0 {
+			c.BasePathExclude[basePath] = patterns
+		}
+	}
+
+	flags := []struct {
+		name  string
+		field *bool
+	}{
+		{"JSON_MANAGER_GITIGNORE", &c.Gitignore},
+		{"JSON_MANAGER_INCLUDE_HIDDEN", &c.IncludeHidden},
+	}
+	for _, flag := range flags {
+		value := strings.TrimSpace(os.Getenv(flag.name))
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return &ConfigError{
+				Field:   flag.name,
+				Message: fmt.Sprintf("invalid boolean '%s'", value),
+				Cause:   err,
+			}
+		}
+		*flag.field = enabled
+	}
+
+	return nil
+}
+
+// splitList splits a semicolon or comma separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// NewConfig creates a configuration for explicitly given base paths, e.g. from
+// command-line flags, instead of reading them from the environment. The scan
+// settings are still read from the environment and config.env.
+func NewConfig(basePaths []string) (*Config, error) {
+	// config.env is optional here, as the base paths are already known
+	godotenv.Load("config.env")
+
+	var cleanPaths []string
+	for _, path := range basePaths {
+		cleanPath := strings.TrimSpace(path)
+		if cleanPath == "" {
+			continue
+		}
+		if absPath, err := filepath.Abs(cleanPath); err == nil {
+			cleanPath = absPath
+		}
+		cleanPaths = append(cleanPaths, cleanPath)
+	}
+
+	config := &Config{
+		BasePaths: cleanPaths,
+	}
+
+	if err := config.loadScanSettings(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	if len(c.BasePaths) == 0 {
@@ -183,6 +282,37 @@ func (c *Config) IsValidPath(path string) bool {
 	return err == nil
 }
 
+// Contains reports whether a path lies inside one of the base paths. Symbolic links
+// are resolved first, so a link cannot lead outside them.
+func (c *Config) Contains(path string) bool {
+	target := resolvePath(path)
+	for _, basePath := range c.BasePaths {
+		rel, err := filepath.Rel(resolvePath(basePath), target)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath returns the absolute path with the symbolic links of its longest
+// existing ancestor resolved, so that paths yet to be created are resolved too
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
 // IsValidBasePath checks if a base path exists and is accessible
 func (c *Config) IsValidBasePath(basePath string) bool {
 	_, err := os.Stat(basePath)
@@ -213,3 +343,13 @@ func GetConfigDir() string {
 
 	return "."
 }
+
+// GetJournalDir returns the directory holding the undo journal of batch operations
+func GetJournalDir() string {
+	return filepath.Join(GetConfigDir(), ".goldenmagic", "journal")
+}
+
+// GetIndexDir returns the directory holding the persistent search index
+func GetIndexDir() string {
+	return filepath.Join(GetConfigDir(), ".goldenmagic", "index")
+}
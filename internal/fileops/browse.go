@@ -0,0 +1,208 @@
+package fileops
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// browseWorkers bounds how many files are read and parsed at the same time
+var browseWorkers = max(4, runtime.NumCPU())
+
+// BrowseFolders recursively searches for files across multiple base paths. The base
+// paths are walked concurrently and, when a query is given, candidate files are
+// read and evaluated by a bounded pool of workers. Files are returned in base path
+// order, then in walk order. Base paths that cannot be walked are skipped.
+func BrowseFolders(ctx context.Context, basePaths []string, extensionFilter string, query *Query, opts ScanOptions) ([]JSONFile, error) {
+	// Walk every base path at once, keeping the candidates of each in their own slot
+	candidates := make([][]JSONFile, len(basePaths))
+	var wg sync.WaitGroup
+	for i, basePath := range basePaths {
+		wg.Add(1)
+		go func(i int, basePath string) {
+			defer wg.Done()
+			files, err := walkFolder(ctx, basePath, extensionFilter, opts)
+			if err != nil {
+				// Skip this path but continue with the others
+				return
+			}
+			candidates[i] = files
+		}(i, basePath)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var allFiles []JSONFile
+	for _, files := range candidates {
+		allFiles = append(allFiles, files...)
+	}
+
+	if query == nil {
+		return allFiles, nil
+	}
+	return FilterByQuery(ctx, allFiles, query, opts.Syntaxes)
+}
+
+// BrowseFolder recursively searches for files matching the extension filter and the
+// query; a nil query keeps every file
+func BrowseFolder(ctx context.Context, folderPath, extensionFilter string, query *Query, opts ScanOptions) ([]JSONFile, error) {
+	files, err := walkFolder(ctx, folderPath, extensionFilter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if query == nil {
+		return files, nil
+	}
+	return FilterByQuery(ctx, files, query, opts.Syntaxes)
+}
+
+// walkFolder returns the files below folderPath that match the extension filter and
+// are not excluded by the scan options
+func walkFolder(ctx context.Context, folderPath, extensionFilter string, opts ScanOptions) ([]JSONFile, error) {
+	// Remove the * if present
+	filter := strings.ToLower(strings.TrimPrefix(extensionFilter, "*"))
+	ignore := newIgnoreMatcher(folderPath, opts)
+
+	var files []JSONFile
+	err := filepath.WalkDir(folderPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel := relativePath(folderPath, path)
+		if d.IsDir() {
+			if rel == "." {
+				if opts.Gitignore {
+					ignore.addFile("", filepath.Join(path, ".gitignore"))
+				}
+				return nil
+			}
+			if !opts.IncludeHidden && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if ignore.ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			if opts.Gitignore {
+				ignore.addFile(rel, filepath.Join(path, ".gitignore"))
+			}
+			return nil
+		}
+
+		if ignore.ignored(rel, false) {
+			return nil
+		}
+
+		// Apply extension filter
+		if filter != "" && !strings.HasSuffix(strings.ToLower(d.Name()), filter) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			// Skip files that disappeared during the walk
+			return nil
+		}
+
+		files = append(files, JSONFile{
+			Name:     d.Name(),
+			Path:     path,
+			BasePath: folderPath,
+			Size:     info.Size(),
+		})
+		return nil
+	})
+
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("error walking directory: %v", err)
+	}
+
+	return files, nil
+}
+
+// FilterByQuery keeps the files that match the query together with the locations of
+// their matches, reading and parsing them in parallel while preserving their order.
+// JSON Lines files also list their matching records.
+func FilterByQuery(ctx context.Context, files []JSONFile, query *Query, syntaxes Syntaxes) ([]JSONFile, error) {
+	keep := make([]bool, len(files))
+	matches := make([][]Match, len(files))
+	records := make([][]int, len(files))
+	err := ParallelEach(ctx, len(files), func(i int) {
+		// Files we can't read are skipped
+		keep[i], matches[i], records[i], _ = LocateMatches(files[i].Path, query, syntaxes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []JSONFile
+	for i, file := range files {
+		if keep[i] {
+			file.Matches = matches[i]
+			file.MatchCount = len(matches[i])
+			file.Records = records[i]
+			matched = append(matched, file)
+		}
+	}
+	return matched, nil
+}
+
+// LocateMatches reads a file and reports whether it matches the query, with the
+// locations of its matches and, for a JSON Lines file, its matching records
+func LocateMatches(filePath string, query *Query, syntaxes Syntaxes) (bool, []Match, []int, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if IsRecordFile(filePath) {
+		records, matches := query.FindRecordMatches(content)
+		return len(records) > 0, matches, records, nil
+	}
+	ok, matches := query.FindFileMatches(filePath, content, syntaxes)
+	return ok, matches, nil, nil
+}
+
+// ParallelEach calls fn for every index in [0, count) on a bounded pool of workers.
+// It stops handing out indices once ctx is done and then returns ctx.Err().
+func ParallelEach(ctx context.Context, count int, fn func(i int)) error {
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(browseWorkers, count); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				fn(i)
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < count; i++ {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	return ctx.Err()
+}
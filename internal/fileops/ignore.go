@@ -0,0 +1,156 @@
+package fileops
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ScanOptions controls which directories and files a scan skips
+type ScanOptions struct {
+	Exclude         []string            // gitignore-style patterns applied below every base path
+	BasePathExclude map[string][]string // additional patterns for a single base path
+	Gitignore       bool                // honor .gitignore files found while scanning
+	IncludeHidden   bool                // descend into hidden directories such as .git
+	Syntaxes        Syntaxes            // syntax of the files read to evaluate a query
+}
+
+// ignoreRule is a single gitignore-style pattern
+type ignoreRule struct {
+	dir      string   // directory the pattern is relative to, "" for the base path
+	segments []string // pattern split at "/", "**" matching any number of directories
+	negate   bool
+	dirOnly  bool
+}
+
+// ignoreMatcher decides which paths below a base path are excluded. Rules are
+// evaluated in order and the last one matching a path wins, as in .gitignore.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// newIgnoreMatcher returns a matcher for the patterns configured for a base path
+func newIgnoreMatcher(basePath string, opts ScanOptions) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	m.add("", opts.Exclude)
+	m.add("", opts.BasePathExclude[basePath])
+	return m
+}
+
+// add parses patterns relative to dir, a slash-separated path below the base path
+func (m *ignoreMatcher) add(dir string, patterns []string) {
+	for _, pattern := range patterns {
+		if rule, ok := parseIgnoreRule(dir, pattern); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+}
+
+// addFile adds the patterns of an ignore file found in dir, if there is one
+func (m *ignoreMatcher) addFile(dir, filePath string) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	m.add(dir, patterns)
+}
+
+// parseIgnoreRule parses a single pattern; blank lines and comments yield no rule
+func parseIgnoreRule(dir, pattern string) (ignoreRule, bool) {
+	pattern = strings.TrimRight(strings.TrimSuffix(pattern, "\r"), " ")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{dir: dir}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\`) {
+		// "\#" and "\!" match a literal leading character
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern without an inner slash matches a name at any depth
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	rule.segments = strings.Split(pattern, "/")
+	if !anchored {
+		rule.segments = append([]string{"**"}, rule.segments...)
+	}
+	return rule, true
+}
+
+// ignored reports whether a path, relative to the base path with forward slashes, is excluded
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+		if rule.dir != "" {
+			if !strings.HasPrefix(rel, rule.dir+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, rule.dir+"/")
+		}
+
+		if matchSegments(rule.segments, strings.Split(target, "/")) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// stands for zero or more segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(segments); skip++ {
+			if matchSegments(pattern[1:], segments[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// relativePath returns path relative to basePath with forward slashes
+func relativePath(basePath, fullPath string) string {
+	rel, err := filepath.Rel(basePath, fullPath)
+	if err != nil {
+		return filepath.ToSlash(fullPath)
+	}
+	return filepath.ToSlash(rel)
+}
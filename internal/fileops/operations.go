@@ -1,12 +1,15 @@
 package fileops
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
+
+	"goldenMagic/internal/jsoncst"
 )
 
 // MaxFileSize defines the maximum file size to process (10MB)
@@ -18,10 +21,17 @@ type JSONFile struct {
 	Path     string `json:"path"`
 	BasePath string `json:"basePath"` // Which base path this file belongs to
 	Size     int64  `json:"size"`     // File size in bytes
+
+	// Matches locates the values that made the file match a query
+	Matches    []Match `json:"matches,omitempty"`
+	MatchCount int     `json:"matchCount,omitempty"`
+
+	// Records lists the matching records of a JSON Lines file
+	Records []int `json:"records,omitempty"`
 }
 
 // GetJSONFileContent returns the content of a JSON file with size validation
-func GetJSONFileContent(filePath string) (string, error) {
+func GetJSONFileContent(filePath string, syntaxes Syntaxes) (string, error) {
 	// Check file size first
 	info, err := os.Stat(filePath)
 	if err != nil {
@@ -37,10 +47,11 @@ func GetJSONFileContent(filePath string) (string, error) {
 		return "", fmt.Errorf("error reading file: %v", err)
 	}
 
-	// Validate JSON format only for .json files
-	if strings.HasSuffix(strings.ToLower(filePath), ".json") || strings.HasSuffix(strings.ToLower(filePath), ".golden") {
-		if err := validateJSON(string(content)); err != nil {
-			return "", fmt.Errorf("invalid JSON content: %v", err)
+	// Validate the format of .json and .golden files, of files in lenient syntax, of
+	// every record of JSON Lines files and of YAML files
+	if strings.HasSuffix(strings.ToLower(filePath), ".json") || strings.HasSuffix(strings.ToLower(filePath), ".golden") || syntaxes.SyntaxOf(filePath) == jsoncst.Lenient || IsRecordFile(filePath) || IsYAMLFile(filePath) {
+		if err := syntaxes.ValidateContent(filePath, content); err != nil {
+			return "", fmt.Errorf("invalid %s content: %v", FormatName(filePath), err)
 		}
 	}
 
@@ -92,109 +103,10 @@ func WriteFile(filePath string, data []byte) error {
 	return os.Rename(tempFile, filePath)
 }
 
-// ContainsKeyDeep recursively searches for a key in JSON content
-func ContainsKeyDeep(content []byte, searchKey string) bool {
-	var data any
-	if err := json.Unmarshal(content, &data); err != nil {
-		return false
-	}
-
-	return containsKeyRecursive(data, searchKey)
-}
-
-// containsKeyRecursive recursively searches for a key in any JSON structure
-func containsKeyRecursive(data any, searchKey string) bool {
-	switch v := data.(type) {
-	case map[string]any:
-		// Check if the key exists directly
-		if _, exists := v[searchKey]; exists {
-			return true
-		}
-		// Recursively check nested objects
-		for _, value := range v {
-			if containsKeyRecursive(value, searchKey) {
-				return true
-			}
-		}
-	case []any:
-		// Recursively check array elements
-		for _, item := range v {
-			if containsKeyRecursive(item, searchKey) {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-// BrowseFolders recursively searches for files across multiple base paths
-func BrowseFolders(basePaths []string, extensionFilter, jsonKeyFilter string) ([]JSONFile, error) {
-	var allFiles []JSONFile
-
-	for _, basePath := range basePaths {
-		files, err := BrowseFolder(basePath, extensionFilter, jsonKeyFilter)
-		if err != nil {
-			// Log the error but continue with other paths
-			continue
-		}
-		allFiles = append(allFiles, files...)
-	}
-
-	return allFiles, nil
-}
-
-// BrowseFolder recursively searches for files matching the extension filter and JSON key filter
-func BrowseFolder(folderPath, extensionFilter, jsonKeyFilter string) ([]JSONFile, error) {
-	var files []JSONFile
-
-	err := filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
-
-		// Apply extension filter
-		if extensionFilter != "" {
-			// Remove the * if present
-			filter := strings.TrimPrefix(extensionFilter, "*")
-			if !strings.HasSuffix(strings.ToLower(info.Name()), strings.ToLower(filter)) {
-				return nil
-			}
-		}
-
-		// Apply JSON key filter (only for JSON-like files)
-		if jsonKeyFilter != "" {
-			content, readErr := os.ReadFile(path)
-			if readErr != nil {
-				// Skip files we can't read
-				return nil
-			}
-
-			// Check if file contains the specified JSON key
-			if !ContainsKeyDeep(content, jsonKeyFilter) {
-				return nil
-			}
-		}
-
-		files = append(files, JSONFile{
-			Name:     info.Name(),
-			Path:     path,
-			BasePath: folderPath,
-			Size:     info.Size(),
-		})
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("error walking directory: %v", err)
-	}
-
-	return files, nil
+// ContentHash returns the SHA-256 hex digest of file content
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
 }
 
 // GroupFilesByBasePath groups files by their base path
@@ -0,0 +1,730 @@
+package fileops
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
+)
+
+// Query is a parsed search expression evaluated against the content of a file.
+//
+// Supported syntax:
+//   - errorCode                   the key exists at any depth
+//   - account.type                the member path exists, starting at the root
+//   - status == "FAILED"          a selected value equals a literal
+//   - httpStatus >= 500           numeric comparisons (<, <=, >, >=)
+//   - message =~ /timeout/i       a selected value matches a regex (!~ negates)
+//   - /^error/                    a key matching a regex exists at any depth
+//   - a && b, a || b, !a          combinators, also written AND, OR, NOT
+//   - (a || b) && c               grouping
+//
+// A single key matches at any depth; paths with more segments (or a leading "$")
+// are anchored at the root and use the path expression syntax, so "..code" and
+// "items[*].id" work as well. A condition holds when any selected value satisfies
+// it: "status != 200" requires status to exist, "!(status == 200)" does not.
+// Literals are JSON strings, numbers, true, false, null, or bare words taken as
+// strings.
+type Query struct {
+	text string
+	root queryNode
+}
+
+// ParseQuery parses a search expression. The empty expression yields a nil query,
+// which matches every file.
+func ParseQuery(text string) (*Query, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	tokens, err := tokenizeQuery(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != qtEOF {
+		return nil, fmt.Errorf("unexpected '%s' at position %d in query", tok.text, tok.pos)
+	}
+
+	return &Query{text: strings.TrimSpace(text), root: root}, nil
+}
+
+// MustParseQuery is like ParseQuery but panics if the expression cannot be parsed
+func MustParseQuery(text string) *Query {
+	query, err := ParseQuery(text)
+	if err != nil {
+		panic(err)
+	}
+	return query
+}
+
+// String returns the expression the query was parsed from
+func (q *Query) String() string {
+	if q == nil {
+		return ""
+	}
+	return q.text
+}
+
+// Match reports whether JSON content satisfies the query. Content that does not
+// parse never matches.
+func (q *Query) Match(content []byte) bool {
+	doc, err := jsoncst.Parse(string(content))
+	if err != nil {
+		return false
+	}
+	ok, _ := q.MatchDocument(doc)
+	return ok
+}
+
+// MatchDocument evaluates the query against a parsed document and returns the
+// values that made it match, in no particular order. Values only excluded by a
+// negation are not returned.
+func (q *Query) MatchDocument(doc *jsoncst.Document) (bool, []jsoncst.Match) {
+	if q == nil {
+		return true, nil
+	}
+	return q.root.eval(doc)
+}
+
+// Match locates a value that made a file match a query. Lines and columns are
+// 1-based; the span starts at the member key when the value has one and EndColumn
+// is just past the end of the value. Matches in JSON Lines files carry the number of
+// their record, and the path is relative to that record.
+type Match struct {
+	Path      string `json:"path"`
+	Record    int    `json:"record,omitempty"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+}
+
+// FindMatches evaluates the query against JSON content and returns the location of
+// every value that made it match, in document order and without duplicates
+func (q *Query) FindMatches(content []byte) (bool, []Match) {
+	doc, err := jsoncst.Parse(string(content))
+	if err != nil {
+		return false, nil
+	}
+	return q.locate(doc)
+}
+
+// FindFileMatches is FindMatches for the content of a file, parsed in the syntax
+// the syntaxes choose for its extension. A JSON Lines file matches when any of its records does,
+// and a YAML file is searched through its JSON form.
+func (q *Query) FindFileMatches(filePath string, content []byte, syntaxes Syntaxes) (bool, []Match) {
+	if IsRecordFile(filePath) {
+		records, matches := q.FindRecordMatches(content)
+		return len(records) > 0, matches
+	}
+	if IsYAMLFile(filePath) {
+		return q.findYAMLMatches(content)
+	}
+
+	doc, err := syntaxes.ParseFile(filePath, content)
+	if err != nil {
+		return false, nil
+	}
+	return q.locate(doc)
+}
+
+// FindRecordMatches evaluates the query against every record of JSON Lines content
+// and returns the numbers of the matching records and the location of their
+// matches. Records that do not parse never match.
+func (q *Query) FindRecordMatches(content []byte) ([]int, []Match) {
+	text := string(content)
+	var records []int
+	var matches []Match
+	for _, record := range SplitRecords(text) {
+		doc, err := jsoncst.Parse(text[record.Start:record.End])
+		if err != nil {
+			continue
+		}
+		ok, found := q.locate(doc)
+		if !ok {
+			continue
+		}
+
+		records = append(records, record.Number)
+		for _, match := range found {
+			match.Record = record.Number
+			match.Line += record.Line - 1
+			match.EndLine += record.Line - 1
+			matches = append(matches, match)
+		}
+	}
+	return records, matches
+}
+
+// locate evaluates the query against a document and locates its matches
+func (q *Query) locate(doc *jsoncst.Document) (bool, []Match) {
+	ok, hits := q.MatchDocument(doc)
+	if !ok {
+		return false, nil
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Node.Start < hits[j].Node.Start
+	})
+	var matches []Match
+	seen := make(map[*jsoncst.Node]bool, len(hits))
+	for _, hit := range hits {
+		if seen[hit.Node] {
+			continue
+		}
+		seen[hit.Node] = true
+
+		start := hit.Node.Start
+		if hit.Member != nil {
+			start = hit.Member.KeyStart
+		}
+		match := Match{Path: hit.Path.String()}
+		match.Line, match.Column = doc.Position(start)
+		match.EndLine, match.EndColumn = doc.Position(hit.Node.End)
+		matches = append(matches, match)
+	}
+	return true, matches
+}
+
+// KeyFilter returns the key or member path when the query only checks that one
+// exists, which is what the search index can answer without parsing any file. Both
+// are written as paths, so a key containing a dot comes back quoted (`["a.b"]`)
+// rather than as a member path.
+func (q *Query) KeyFilter() (string, bool) {
+	if q == nil {
+		return "", false
+	}
+	cond, ok := q.root.(*condition)
+	if !ok || cond.op != "" || cond.keyRe != nil {
+		return "", false
+	}
+
+	path := cond.path
+	if len(path) == 2 && path[0].Kind == jsonpath.DescendSegment && path[1].Kind == jsonpath.KeySegment {
+		return path[1:].String(), true
+	}
+	if len(path) < 2 {
+		return "", false
+	}
+	for _, seg := range path {
+		if seg.Kind != jsonpath.KeySegment {
+			return "", false
+		}
+	}
+	return path.String(), true
+}
+
+// queryNode is a node of a parsed query
+type queryNode interface {
+	eval(doc *jsoncst.Document) (bool, []jsoncst.Match)
+}
+
+type orNode struct{ left, right queryNode }
+type andNode struct{ left, right queryNode }
+type notNode struct{ operand queryNode }
+
+func (n *orNode) eval(doc *jsoncst.Document) (bool, []jsoncst.Match) {
+	lok, lhits := n.left.eval(doc)
+	rok, rhits := n.right.eval(doc)
+	return lok || rok, append(lhits, rhits...)
+}
+
+func (n *andNode) eval(doc *jsoncst.Document) (bool, []jsoncst.Match) {
+	lok, lhits := n.left.eval(doc)
+	if !lok {
+		return false, nil
+	}
+	rok, rhits := n.right.eval(doc)
+	if !rok {
+		return false, nil
+	}
+	return true, append(lhits, rhits...)
+}
+
+func (n *notNode) eval(doc *jsoncst.Document) (bool, []jsoncst.Match) {
+	ok, _ := n.operand.eval(doc)
+	return !ok, nil
+}
+
+// condition selects values by path or key regex and tests each of them
+type condition struct {
+	path    jsonpath.Path
+	keyRe   *regexp.Regexp // selects members by key at any depth instead of path
+	op      string         // "" only checks that a value is selected
+	literal any            // string, float64, bool or nil
+	valueRe *regexp.Regexp // for =~ and !~
+}
+
+func (c *condition) eval(doc *jsoncst.Document) (bool, []jsoncst.Match) {
+	var hits []jsoncst.Match
+	for _, match := range c.selectValues(doc) {
+		if c.test(doc, match.Node) {
+			hits = append(hits, match)
+		}
+	}
+	return len(hits) > 0, hits
+}
+
+// selectValues returns the values the condition applies to
+func (c *condition) selectValues(doc *jsoncst.Document) []jsoncst.Match {
+	if c.keyRe == nil {
+		return doc.Select(c.path)
+	}
+
+	var matches []jsoncst.Match
+	doc.Walk(func(path jsonpath.Path, n *jsoncst.Node, m *jsoncst.Member) bool {
+		if m != nil && c.keyRe.MatchString(m.Key) {
+			matches = append(matches, jsoncst.Match{Path: path, Node: n, Member: m})
+		}
+		return true
+	})
+	return matches
+}
+
+// test reports whether a selected value satisfies the comparison
+func (c *condition) test(doc *jsoncst.Document, n *jsoncst.Node) bool {
+	switch c.op {
+	case "":
+		return true
+	case "=~", "!~":
+		text, ok := scalarText(doc, n)
+		if !ok {
+			return false
+		}
+		return c.valueRe.MatchString(text) == (c.op == "=~")
+	}
+
+	value, err := doc.Value(n)
+	if err != nil {
+		return false
+	}
+
+	switch c.op {
+	case "==":
+		return literalEqual(value, c.literal)
+	case "!=":
+		return !literalEqual(value, c.literal)
+	}
+
+	number, ok := value.(float64)
+	limit, isNumber := c.literal.(float64)
+	if !ok || !isNumber {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return number < limit
+	case "<=":
+		return number <= limit
+	case ">":
+		return number > limit
+	default:
+		return number >= limit
+	}
+}
+
+// scalarText returns the text a regex is matched against: the decoded content of a
+// string, or the source text of a number or boolean
+func scalarText(doc *jsoncst.Document, n *jsoncst.Node) (string, bool) {
+	switch n.Kind {
+	case jsoncst.Object, jsoncst.Array, jsoncst.Null:
+		return "", false
+	case jsoncst.String:
+		value, err := doc.Value(n)
+		if err != nil {
+			return "", false
+		}
+		return value.(string), true
+	}
+	return doc.Text(n), true
+}
+
+// literalEqual compares a decoded JSON value with a query literal
+func literalEqual(value, literal any) bool {
+	switch l := literal.(type) {
+	case nil:
+		return value == nil
+	case string:
+		v, ok := value.(string)
+		return ok && v == l
+	case float64:
+		v, ok := value.(float64)
+		return ok && v == l
+	case bool:
+		v, ok := value.(bool)
+		return ok && v == l
+	}
+	return false
+}
+
+// queryTokenKind identifies the type of a query token
+type queryTokenKind int
+
+const (
+	qtEOF queryTokenKind = iota
+	qtWord
+	qtString
+	qtRegex
+	qtCompare
+	qtAnd
+	qtOr
+	qtNot
+	qtLParen
+	qtRParen
+)
+
+// queryToken is a token of a search expression. Text holds the decoded content
+// of strings and the pattern of regexes.
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int
+}
+
+// compareOps lists the comparison operators, longest first
+var compareOps = []string{"==", "!=", "=~", "!~", "<=", ">=", "<", ">", "="}
+
+// tokenizeQuery splits a search expression into tokens
+func tokenizeQuery(text string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: qtLParen, text: "(", pos: i})
+			i++
+			continue
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: qtRParen, text: ")", pos: i})
+			i++
+			continue
+		case strings.HasPrefix(text[i:], "&&"):
+			tokens = append(tokens, queryToken{kind: qtAnd, text: "&&", pos: i})
+			i += 2
+			continue
+		case strings.HasPrefix(text[i:], "||"):
+			tokens = append(tokens, queryToken{kind: qtOr, text: "||", pos: i})
+			i += 2
+			continue
+		case c == '"' || c == '\'':
+			value, next, err := scanQueryString(text, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: qtString, text: value, pos: i})
+			i = next
+			continue
+		case c == '/':
+			pattern, next, err := scanQueryRegex(text, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: qtRegex, text: pattern, pos: i})
+			i = next
+			continue
+		}
+
+		if op := compareOperator(text[i:]); op != "" {
+			tokens = append(tokens, queryToken{kind: qtCompare, text: op, pos: i})
+			i += len(op)
+			continue
+		}
+		if c == '!' {
+			tokens = append(tokens, queryToken{kind: qtNot, text: "!", pos: i})
+			i++
+			continue
+		}
+
+		word, next := scanQueryWord(text, i)
+		if word == "" {
+			return nil, fmt.Errorf("unexpected '%c' at position %d in query", c, i)
+		}
+		kind := qtWord
+		switch strings.ToUpper(word) {
+		case "AND":
+			kind = qtAnd
+		case "OR":
+			kind = qtOr
+		case "NOT":
+			kind = qtNot
+		}
+		tokens = append(tokens, queryToken{kind: kind, text: word, pos: i})
+		i = next
+	}
+
+	return append(tokens, queryToken{kind: qtEOF, text: "end of query", pos: len(text)}), nil
+}
+
+// compareOperator returns the comparison operator at the start of s, if any
+func compareOperator(s string) string {
+	for _, op := range compareOps {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// scanQueryWord reads a path or bare literal. Brackets may hold quoted keys with
+// spaces or operator characters.
+func scanQueryWord(text string, i int) (string, int) {
+	start := i
+	depth := 0
+	for i < len(text) {
+		c := text[i]
+		if depth > 0 {
+			switch c {
+			case '"', '\'':
+				end := i + 1
+				for end < len(text) && text[end] != c {
+					if text[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				i = end + 1
+				continue
+			case ']':
+				depth--
+			}
+			i++
+			continue
+		}
+
+		if strings.ContainsRune(" \t\r\n()!=<>&|\"'", rune(c)) {
+			break
+		}
+		if c == '[' {
+			depth++
+		}
+		i++
+	}
+	return text[start:min(i, len(text))], min(i, len(text))
+}
+
+// scanQueryString reads a double- or single-quoted string and returns its content
+func scanQueryString(text string, i int) (string, int, error) {
+	quote := text[i]
+	end := i + 1
+	for end < len(text) && text[end] != quote {
+		if text[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(text) {
+		return "", 0, fmt.Errorf("unterminated string at position %d in query", i)
+	}
+
+	literal := text[i : end+1]
+	if quote == '\'' {
+		literal = `"` + strings.ReplaceAll(strings.ReplaceAll(literal[1:len(literal)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	var value string
+	if err := json.Unmarshal([]byte(literal), &value); err != nil {
+		return "", 0, fmt.Errorf("invalid string at position %d in query: %v", i, err)
+	}
+	return value, end + 1, nil
+}
+
+// scanQueryRegex reads a /pattern/flags literal and returns the pattern with the
+// flags applied. The only flag is "i" for case-insensitive matching.
+func scanQueryRegex(text string, i int) (string, int, error) {
+	end := i + 1
+	for end < len(text) && text[end] != '/' {
+		if text[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(text) {
+		return "", 0, fmt.Errorf("unterminated regex at position %d in query", i)
+	}
+
+	pattern := strings.ReplaceAll(text[i+1:end], `\/`, "/")
+	end++
+	for end < len(text) && text[end] == 'i' {
+		pattern = "(?i)" + pattern
+		end++
+	}
+	return pattern, end, nil
+}
+
+// queryParser builds a query from its tokens by recursive descent. NOT binds
+// tighter than AND, which binds tighter than OR.
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != qtEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == qtOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == qtAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	switch tok := p.peek(); tok.kind {
+	case qtNot:
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	case qtLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != qtRParen {
+			return nil, fmt.Errorf("expected ')' at position %d in query", closing.pos)
+		}
+		return inner, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *queryParser) parseCondition() (queryNode, error) {
+	tok := p.next()
+	cond := &condition{}
+
+	switch tok.kind {
+	case qtWord:
+		path, err := jsonpath.Parse(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path in query: %v", err)
+		}
+		if path.IsRoot() {
+			return nil, fmt.Errorf("query path at position %d must not refer to the root", tok.pos)
+		}
+		// A lone key matches at any depth, as the plain key filter always did
+		if len(path) == 1 && path[0].Kind == jsonpath.KeySegment && !strings.HasPrefix(tok.text, "$") {
+			path = append(jsonpath.Path{{Kind: jsonpath.DescendSegment}}, path...)
+		}
+		cond.path = path
+	case qtRegex:
+		re, err := regexp.Compile(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key regex at position %d in query: %v", tok.pos, err)
+		}
+		cond.keyRe = re
+	default:
+		return nil, fmt.Errorf("expected a key, path or /regex/ at position %d in query, got '%s'", tok.pos, tok.text)
+	}
+
+	if p.peek().kind != qtCompare {
+		return cond, nil
+	}
+
+	opTok := p.next()
+	cond.op = opTok.text
+	if cond.op == "=" {
+		cond.op = "=="
+	}
+
+	value := p.next()
+	if value.kind == qtRegex && (cond.op == "==" || cond.op == "!=") {
+		// "key == /re/" reads naturally as a regex match
+		cond.op = map[string]string{"==": "=~", "!=": "!~"}[cond.op]
+	}
+
+	switch cond.op {
+	case "=~", "!~":
+		if value.kind != qtRegex && value.kind != qtString && value.kind != qtWord {
+			return nil, fmt.Errorf("expected a regex after '%s' at position %d in query", opTok.text, value.pos)
+		}
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex at position %d in query: %v", value.pos, err)
+		}
+		cond.valueRe = re
+		return cond, nil
+	}
+
+	literal, err := parseLiteral(value)
+	if err != nil {
+		return nil, err
+	}
+	if _, isNumber := literal.(float64); !isNumber && cond.op != "==" && cond.op != "!=" {
+		return nil, fmt.Errorf("'%s' needs a number at position %d in query", cond.op, value.pos)
+	}
+	cond.literal = literal
+	return cond, nil
+}
+
+// parseLiteral converts a value token into a string, float64, bool or nil
+func parseLiteral(tok queryToken) (any, error) {
+	switch tok.kind {
+	case qtString:
+		return tok.text, nil
+	case qtWord:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if number, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			return number, nil
+		}
+		return tok.text, nil
+	}
+	return nil, fmt.Errorf("expected a value at position %d in query, got '%s'", tok.pos, tok.text)
+}
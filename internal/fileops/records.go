@@ -0,0 +1,59 @@
+package fileops
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RecordExtensions are the file extensions of JSON Lines files, which hold one JSON
+// document per line, as written by streaming APIs
+var RecordExtensions = []string{".jsonl", ".ndjson"}
+
+// Record is one document of a JSON Lines file
+type Record struct {
+	Number int // 1-based position among the records of the file
+	Line   int // 1-based line the record is on
+	Start  int // offset of the start of the line
+	End    int // offset of the end of the line, before its line break
+}
+
+// IsRecordFile reports whether a file holds one JSON document per line, chosen by
+// its extension
+func IsRecordFile(filePath string) bool {
+	return hasExtension(filePath, RecordExtensions)
+}
+
+// SplitRecords returns the records of JSON Lines content. Blank lines are not
+// records; "\r\n" line breaks are accepted.
+func SplitRecords(content string) []Record {
+	var records []Record
+	line, start := 1, 0
+	for start <= len(content) {
+		end := strings.IndexByte(content[start:], '\n')
+		next := start + end + 1
+		if end == -1 {
+			end = len(content) - start
+			next = len(content) + 1
+		}
+		end += start
+		if end > start && content[end-1] == '\r' {
+			end--
+		}
+		if strings.TrimSpace(content[start:end]) != "" {
+			records = append(records, Record{Number: len(records) + 1, Line: line, Start: start, End: end})
+		}
+		line++
+		start = next
+	}
+	return records
+}
+
+// validateRecords checks that every record of JSON Lines content is valid JSON
+func validateRecords(content string) error {
+	for _, record := range SplitRecords(content) {
+		if err := validateJSON(content[record.Start:record.End]); err != nil {
+			return fmt.Errorf("record %d (line %d): %v", record.Number, record.Line, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package fileops
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/yamldoc"
+)
+
+// DefaultLenientExtensions are the file extensions always parsed in lenient syntax
+var DefaultLenientExtensions = []string{".jsonc", ".json5"}
+
+// Syntaxes chooses the syntax files are parsed in by their extension. The zero
+// value parses .jsonc and .json5 files in lenient syntax and all others strictly.
+type Syntaxes struct {
+	// LenientExtensions are the extensions, besides the defaults, whose files may
+	// contain comments, trailing commas, unquoted keys and single-quoted strings,
+	// e.g. ".json" for a tree of tsconfig-style files
+	LenientExtensions []string
+}
+
+// Lenient returns the extensions parsed in lenient syntax, lowercased and sorted
+func (s Syntaxes) Lenient() []string {
+	extensions := normalizeExtensions(append(append([]string{}, DefaultLenientExtensions...), s.LenientExtensions...))
+	sort.Strings(extensions)
+	return slices.Compact(extensions)
+}
+
+// SyntaxOf returns the syntax a file is parsed with, chosen by its extension
+func (s Syntaxes) SyntaxOf(filePath string) jsoncst.Syntax {
+	if hasExtension(filePath, s.Lenient()) {
+		return jsoncst.Lenient
+	}
+	return jsoncst.Strict
+}
+
+// ParseFile parses the content of a file in the syntax chosen by its extension
+func (s Syntaxes) ParseFile(filePath string, content []byte) (*jsoncst.Document, error) {
+	return jsoncst.ParseAs(string(content), s.SyntaxOf(filePath))
+}
+
+// ValidateContent checks that content is valid in the syntax of the file it is
+// read from or written to. Every record of a JSON Lines file must be valid JSON, and
+// a YAML file must hold a single YAML document.
+func (s Syntaxes) ValidateContent(filePath string, content []byte) error {
+	if IsRecordFile(filePath) {
+		return validateRecords(string(content))
+	}
+	if IsYAMLFile(filePath) {
+		_, err := yamldoc.Parse(string(content))
+		return err
+	}
+	if s.SyntaxOf(filePath) == jsoncst.Lenient {
+		_, err := jsoncst.ParseAs(string(content), jsoncst.Lenient)
+		return err
+	}
+	return validateJSON(string(content))
+}
+
+// FormatName names the format of a file for messages, chosen by its extension
+func FormatName(filePath string) string {
+	switch {
+	case IsRecordFile(filePath):
+		return "JSON Lines"
+	case IsYAMLFile(filePath):
+		return "YAML"
+	}
+	return "JSON"
+}
+
+// DecodeRecords decodes the content of a file in the syntax chosen by its extension.
+// A JSON Lines file yields one value per record, any other file a single value.
+func (s Syntaxes) DecodeRecords(filePath string, content []byte) ([]any, error) {
+	text := string(content)
+	if IsYAMLFile(filePath) {
+		doc, err := yamldoc.Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		return []any{doc.Value()}, nil
+	}
+	if IsRecordFile(filePath) {
+		var values []any
+		for _, record := range SplitRecords(text) {
+			var data any
+			if err := json.Unmarshal([]byte(text[record.Start:record.End]), &data); err != nil {
+				return nil, fmt.Errorf("record %d: %v", record.Number, err)
+			}
+			values = append(values, data)
+		}
+		return values, nil
+	}
+
+	if s.SyntaxOf(filePath) == jsoncst.Lenient {
+		standard, err := jsoncst.Standard(text)
+		if err != nil {
+			return nil, err
+		}
+		text = standard
+	}
+
+	var data any
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		return nil, err
+	}
+	return []any{data}, nil
+}
+
+// normalizeExtensions lowercases extensions and gives them a leading dot
+func normalizeExtensions(extensions []string) []string {
+	var normalized []string
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "*"))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized = append(normalized, ext)
+	}
+	return normalized
+}
@@ -0,0 +1,83 @@
+package fileops
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"goldenMagic/internal/jsonpath"
+)
+
+// ValueSearch finds files by the content of their string, number and boolean
+// values rather than by key names. A literal pattern must equal the whole value
+// (numbers and booleans are compared by their JSON text); a regex may match any
+// part of it. Keys and escaped JSON inside string values are never searched as
+// structure, so an account number only matches where it is an actual value.
+type ValueSearch struct {
+	Pattern    string   `json:"pattern"`
+	Regex      bool     `json:"regex"`
+	IgnoreCase bool     `json:"ignoreCase"`
+	Keys       []string `json:"keys,omitempty"` // only search the values of members with these keys
+}
+
+// Query compiles the value search into a query usable with BrowseFolder
+func (s ValueSearch) Query() (*Query, error) {
+	if s.Pattern == "" {
+		return nil, fmt.Errorf("value pattern cannot be empty")
+	}
+
+	pattern := "^" + regexp.QuoteMeta(s.Pattern) + "$"
+	if s.Regex {
+		pattern = s.Pattern
+	}
+	if s.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	valueRe, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value regex: %v", err)
+	}
+
+	// Every value at any depth, or only the values of members with the given keys
+	keys := make([]string, 0, len(s.Keys))
+	for _, key := range s.Keys {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, regexp.QuoteMeta(key))
+		}
+	}
+	cond := &condition{path: jsonpath.MustParse("..*"), op: "=~", valueRe: valueRe}
+	if len(keys) > 0 {
+		cond = &condition{keyRe: regexp.MustCompile("^(?:" + strings.Join(keys, "|") + ")$"), op: "=~", valueRe: valueRe}
+	}
+	return &Query{text: s.String(), root: cond}, nil
+}
+
+// String describes the search, e.g. `/acct-\d+/i in [iban, accountNumber]`
+func (s ValueSearch) String() string {
+	text := fmt.Sprintf("%q", s.Pattern)
+	if s.Regex {
+		text = "/" + s.Pattern + "/"
+	}
+	if s.IgnoreCase {
+		text += "i"
+	}
+	if len(s.Keys) > 0 {
+		text += " in [" + strings.Join(s.Keys, ", ") + "]"
+	}
+	return text
+}
+
+// ContainsValueDeep returns the paths of the values in JSON content that match the
+// search, in document order. Content that does not parse has no matches.
+func ContainsValueDeep(content []byte, search ValueSearch) ([]string, error) {
+	query, err := search.Query()
+	if err != nil {
+		return nil, err
+	}
+	_, matches := query.FindMatches(content)
+	var paths []string
+	for _, match := range matches {
+		paths = append(paths, match.Path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,56 @@
+package fileops
+
+import (
+	"path/filepath"
+	"strings"
+
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
+	"goldenMagic/internal/yamldoc"
+)
+
+// YAMLExtensions are the file extensions of YAML files, which are searched and
+// edited through their JSON form
+var YAMLExtensions = []string{".yaml", ".yml"}
+
+// IsYAMLFile reports whether a file holds a YAML document, chosen by its extension
+func IsYAMLFile(filePath string) bool {
+	return hasExtension(filePath, YAMLExtensions)
+}
+
+// findYAMLMatches evaluates the query against the JSON form of a YAML document and
+// locates its matches in the YAML source
+func (q *Query) findYAMLMatches(content []byte) (bool, []Match) {
+	yamlDoc, err := yamldoc.Parse(string(content))
+	if err != nil {
+		return false, nil
+	}
+	doc, err := jsoncst.Parse(yamlDoc.JSON())
+	if err != nil {
+		return false, nil
+	}
+
+	ok, matches := q.locate(doc)
+	for i, match := range matches {
+		path, err := jsonpath.Parse(match.Path)
+		if err != nil {
+			continue
+		}
+		if line, column, endLine, endColumn, found := yamlDoc.Locate(path); found {
+			matches[i].Line, matches[i].Column = line, column
+			matches[i].EndLine, matches[i].EndColumn = endLine, endColumn
+		}
+	}
+	return ok, matches
+}
+
+// hasExtension reports whether a file has one of the extensions
+func hasExtension(filePath string, extensions []string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, candidate := range extensions {
+		if ext == candidate {
+			return true
+		}
+	}
+	return false
+}
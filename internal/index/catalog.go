@@ -0,0 +1,115 @@
+package index
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"goldenMagic/internal/fileops"
+)
+
+// Term kinds
+const (
+	KindKey  = "key"
+	KindPath = "path"
+)
+
+// Term is a key or member path together with the number of files containing it
+type Term struct {
+	Kind  string `json:"kind"`
+	Text  string `json:"text"`
+	Files int    `json:"files"`
+}
+
+// Catalog lists the distinct keys and member paths of a set of files. Paths use a
+// wildcard for array elements, e.g. "items.*.id", so each is a valid object path.
+type Catalog struct {
+	Files int    `json:"files"` // files that parsed as JSON
+	Keys  []Term `json:"keys"`
+	Paths []Term `json:"paths"`
+}
+
+// BuildCatalog parses the files and returns their catalog without using an index
+func BuildCatalog(ctx context.Context, files []fileops.JSONFile, syntaxes fileops.Syntaxes) (*Catalog, error) {
+	entries := make([]*FileEntry, len(files))
+	err := fileops.ParallelEach(ctx, len(files), func(i int) {
+		entries[i], _ = refresh(files[i].Path, nil, syntaxes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newCatalog(entries), nil
+}
+
+// newCatalog counts the keys and paths of the entries, skipping missing and invalid ones
+func newCatalog(entries []*FileEntry) *Catalog {
+	keys := make(map[string]int)
+	paths := make(map[string]int)
+	catalog := &Catalog{}
+	for _, entry := range entries {
+		if entry == nil || !entry.Valid {
+			continue
+		}
+		catalog.Files++
+		for _, key := range entry.Keys {
+			keys[key]++
+		}
+		for _, path := range entry.Paths {
+			paths[path]++
+		}
+	}
+
+	catalog.Keys = terms(KindKey, keys)
+	catalog.Paths = terms(KindPath, paths)
+	return catalog
+}
+
+// Complete returns up to limit keys and paths starting with the prefix, ignoring
+// case, with the most common first. The kind restricts the results to keys or
+// paths; an empty kind returns both. A limit of 0 or less returns every match.
+func (c *Catalog) Complete(prefix, kind string, limit int) []Term {
+	prefix = strings.ToLower(prefix)
+
+	var matches []Term
+	for _, list := range [][]Term{c.Keys, c.Paths} {
+		for _, term := range list {
+			if kind != "" && term.Kind != kind {
+				continue
+			}
+			if strings.HasPrefix(strings.ToLower(term.Text), prefix) {
+				matches = append(matches, term)
+			}
+		}
+	}
+	sortTerms(matches)
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	if matches == nil {
+		matches = []Term{}
+	}
+	return matches
+}
+
+func terms(kind string, counts map[string]int) []Term {
+	list := make([]Term, 0, len(counts))
+	for text, files := range counts {
+		list = append(list, Term{Kind: kind, Text: text, Files: files})
+	}
+	sortTerms(list)
+	return list
+}
+
+// sortTerms orders terms by descending file count, then by kind and text
+func sortTerms(list []Term) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Files != list[j].Files {
+			return list[i].Files > list[j].Files
+		}
+		if list[i].Kind != list[j].Kind {
+			return list[i].Kind < list[j].Kind
+		}
+		return list[i].Text < list[j].Text
+	})
+}
@@ -0,0 +1,324 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsonpath"
+)
+
+// formatVersion is the version of the on-disk format; other versions are rebuilt
+const formatVersion = 4
+
+// FileEntry is what the index knows about a single file
+type FileEntry struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash"`
+	Valid   bool      `json:"valid"` // whether the file parsed as JSON
+	Keys    []string  `json:"keys"`  // every object key, sorted
+	Paths   []string  `json:"paths"` // every object member path with array elements as "*", sorted
+}
+
+// Stats describes how a lookup was answered
+type Stats struct {
+	Files    int `json:"files"`    // files looked up
+	Reparsed int `json:"reparsed"` // files read and parsed because they were new or had changed
+}
+
+// baseIndex is the index of a single base path, stored as one file
+type baseIndex struct {
+	Version  int                   `json:"version"`
+	BasePath string                `json:"basePath"`
+	Lenient  []string              `json:"lenient"` // extensions parsed in lenient syntax
+	Files    map[string]*FileEntry `json:"files"`
+
+	dirty bool
+}
+
+// Index maps JSON keys and paths to the files containing them, one file per base
+// path. Entries are keyed by modification time and size and reused until either
+// changes; a changed file whose content hash is unchanged is not parsed again. An
+// index built with other lenient extensions is rebuilt, as files may parse
+// differently.
+type Index struct {
+	dir      string
+	syntaxes fileops.Syntaxes
+	mu       sync.Mutex
+	bases    map[string]*baseIndex
+}
+
+// Open opens the index in dir, creating the directory if needed. Files are parsed
+// in the syntax the syntaxes choose for their extension.
+func Open(dir string, syntaxes fileops.Syntaxes) (*Index, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %v", err)
+	}
+	return &Index{dir: dir, syntaxes: syntaxes, bases: make(map[string]*baseIndex)}, nil
+}
+
+// Filter returns the files containing the key at any depth when the filter is a
+// single key (e.g. "city", or `["a.b"]` for a key with a dot), or the member path
+// (e.g. "user.address.city" or "items[0].id") when it is a longer path. The files
+// are every file found below their base paths, so entries of files no longer found
+// are dropped. New and changed files are re-indexed in parallel; the order of files
+// is preserved.
+func (ix *Index) Filter(ctx context.Context, files []fileops.JSONFile, filter string) ([]fileops.JSONFile, Stats, error) {
+	entries, stats, err := ix.lookup(ctx, files, true)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	key, path := filterTerms(filter)
+	var matched []fileops.JSONFile
+	for i, file := range files {
+		if entries[i] != nil && entries[i].matches(key, path) {
+			matched = append(matched, file)
+		}
+	}
+	return matched, stats, nil
+}
+
+// Catalog returns every distinct key and member path in the files together with
+// the number of files containing it, refreshing stale entries like Filter
+func (ix *Index) Catalog(ctx context.Context, files []fileops.JSONFile) (*Catalog, Stats, error) {
+	entries, stats, err := ix.lookup(ctx, files, false)
+	if err != nil {
+		return nil, stats, err
+	}
+	return newCatalog(entries), stats, nil
+}
+
+// lookup returns the up-to-date entries of the files, nil for files that could not
+// be read. New and changed files are re-indexed in parallel and the index is saved.
+// When the files are a full rescan of their base paths, the entries of files it did
+// not find are pruned.
+func (ix *Index) lookup(ctx context.Context, files []fileops.JSONFile, rescan bool) ([]*FileEntry, Stats, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	stats := Stats{Files: len(files)}
+
+	bases := make([]*baseIndex, len(files))
+	for i, file := range files {
+		bases[i] = ix.base(file.BasePath)
+	}
+
+	// Refresh stale entries in parallel; the maps are only read until all workers are done
+	entries := make([]*FileEntry, len(files))
+	updated := make([]bool, len(files))
+	err := fileops.ParallelEach(ctx, len(files), func(i int) {
+		entries[i], updated[i] = refresh(files[i].Path, bases[i].Files[files[i].Path], ix.syntaxes)
+	})
+
+	for i, file := range files {
+		if updated[i] && entries[i] != nil {
+			bases[i].Files[file.Path] = entries[i]
+			bases[i].dirty = true
+			stats.Reparsed++
+		}
+	}
+	if rescan {
+		ix.prune(files)
+	}
+	saveErr := ix.save()
+
+	if err != nil {
+		return nil, stats, err
+	}
+	if saveErr != nil {
+		return nil, stats, saveErr
+	}
+	return entries, stats, nil
+}
+
+// refresh returns the up-to-date entry of a file and whether it had to be updated.
+// A nil entry means the file could not be read.
+func refresh(filePath string, entry *FileEntry, syntaxes fileops.Syntaxes) (*FileEntry, bool) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, false
+	}
+	if entry != nil && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return entry, false
+	}
+
+	content, err := fileops.ReadFile(filePath)
+	if err != nil {
+		return nil, false
+	}
+
+	hash := fileops.ContentHash(content)
+	if entry != nil && entry.Hash == hash {
+		// Touched but not changed, e.g. rewritten with the same content
+		unchanged := *entry
+		unchanged.ModTime = info.ModTime()
+		unchanged.Size = info.Size()
+		return &unchanged, true
+	}
+
+	fresh := &FileEntry{
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Hash:    hash,
+	}
+	fresh.Keys, fresh.Paths, fresh.Valid = extract(filePath, content, syntaxes)
+	return fresh, true
+}
+
+// extract returns the sorted keys and member paths of a JSON document, parsed in
+// the syntax of its file. The records of a JSON Lines file are indexed together.
+func extract(filePath string, content []byte, syntaxes fileops.Syntaxes) ([]string, []string, bool) {
+	records, err := syntaxes.DecodeRecords(filePath, content)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	keys := make(map[string]bool)
+	paths := make(map[string]bool)
+
+	var walk func(value any, path jsonpath.Path)
+	walk = func(value any, path jsonpath.Path) {
+		switch v := value.(type) {
+		case map[string]any:
+			for key, child := range v {
+				childPath := path.Child(key)
+				keys[key] = true
+				paths[childPath.String()] = true
+				walk(child, childPath)
+			}
+		case []any:
+			elemPath := append(path[:len(path):len(path)], jsonpath.Segment{Kind: jsonpath.WildcardSegment})
+			for _, child := range v {
+				walk(child, elemPath)
+			}
+		}
+	}
+	for _, data := range records {
+		walk(data, nil)
+	}
+
+	return sortedSet(keys), sortedSet(paths), true
+}
+
+// filterTerms returns the key to look up when the filter is a single key, or else
+// the indexed form of its member path, with array indices matching any element.
+// A filter that is not a valid path is looked up as a literal key.
+func filterTerms(filter string) (key, path string) {
+	p, err := jsonpath.Parse(filter)
+	if err != nil {
+		return filter, ""
+	}
+	if len(p) == 1 && p[0].Kind == jsonpath.KeySegment {
+		return p[0].Key, ""
+	}
+
+	for i, seg := range p {
+		switch seg.Kind {
+		case jsonpath.IndexSegment:
+			p[i] = jsonpath.Segment{Kind: jsonpath.WildcardSegment}
+		case jsonpath.DescendSegment:
+			return "", ""
+		}
+	}
+	return "", p.String()
+}
+
+// matches reports whether the file contains the key, or the member path when one
+// is given
+func (e *FileEntry) matches(key, path string) bool {
+	if path != "" {
+		return contains(e.Paths, path)
+	}
+	return key != "" && contains(e.Keys, key)
+}
+
+// base returns the index of a base path, loading it from disk on first use
+func (ix *Index) base(basePath string) *baseIndex {
+	if b, ok := ix.bases[basePath]; ok {
+		return b
+	}
+
+	lenient := ix.syntaxes.Lenient()
+	b := &baseIndex{Version: formatVersion, BasePath: basePath, Lenient: lenient, Files: make(map[string]*FileEntry)}
+	if data, err := fileops.ReadFile(ix.path(basePath)); err == nil {
+		var stored baseIndex
+		// A corrupt or outdated index, or one built with other syntaxes, is simply rebuilt
+		if json.Unmarshal(data, &stored) == nil && stored.Version == formatVersion && stored.BasePath == basePath &&
+			slices.Equal(stored.Lenient, lenient) && stored.Files != nil {
+			b = &stored
+		}
+	}
+	ix.bases[basePath] = b
+	return b
+}
+
+// prune drops the entries of files a rescan of their base paths did not find. Only
+// files with the extensions it found are pruned, as a search for other extensions
+// does not list them.
+func (ix *Index) prune(files []fileops.JSONFile) {
+	seen := make(map[string]bool, len(files))
+	touched := make(map[string]bool)
+	extensions := make(map[string]bool)
+	for _, file := range files {
+		seen[file.Path] = true
+		touched[file.BasePath] = true
+		extensions[strings.ToLower(filepath.Ext(file.Path))] = true
+	}
+
+	for basePath := range touched {
+		b := ix.bases[basePath]
+		for filePath := range b.Files {
+			if !seen[filePath] && extensions[strings.ToLower(filepath.Ext(filePath))] {
+				delete(b.Files, filePath)
+				b.dirty = true
+			}
+		}
+	}
+}
+
+// save writes every base index that changed
+func (ix *Index) save() error {
+	for basePath, b := range ix.bases {
+		if !b.dirty {
+			continue
+		}
+		data, err := json.Marshal(b)
+		if err != nil {
+			return fmt.Errorf("failed to encode index: %v", err)
+		}
+		if err := fileops.WriteFile(ix.path(basePath), data); err != nil {
+			return fmt.Errorf("failed to write index: %v", err)
+		}
+		b.dirty = false
+	}
+	return nil
+}
+
+// path returns the file holding the index of a base path
+func (ix *Index) path(basePath string) string {
+	return filepath.Join(ix.dir, fileops.ContentHash([]byte(basePath))[:16]+".json")
+}
+
+func contains(sorted []string, s string) bool {
+	i := sort.SearchStrings(sorted, s)
+	return i < len(sorted) && sorted[i] == s
+}
+
+func sortedSet(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for s := range set {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return list
+}
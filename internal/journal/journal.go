@@ -0,0 +1,306 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"goldenMagic/internal/fileops"
+)
+
+// maxEntries bounds how many batch operations are kept on disk
+const maxEntries = 100
+
+// FileRecord is the state of a single file around a batch write
+type FileRecord struct {
+	FilePath   string `json:"filePath"`
+	PreHash    string `json:"preHash"`
+	PostHash   string `json:"postHash"`
+	PreContent string `json:"preContent"`
+}
+
+// Entry is a batch write recorded in the journal. It is written with the pre-image
+// of every file before any file is, and stays pending until the files are written,
+// so a batch that was interrupted halfway can still be undone.
+type Entry struct {
+	ID        string       `json:"id"`
+	Operation string       `json:"operation"`
+	Timestamp time.Time    `json:"timestamp"`
+	Files     []FileRecord `json:"files"`
+	Pending   bool         `json:"pending,omitempty"`
+	Undone    bool         `json:"undone"`
+}
+
+// Summary describes an entry without the file contents it holds
+type Summary struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	Timestamp time.Time `json:"timestamp"`
+	Files     []string  `json:"files"`
+	Pending   bool      `json:"pending,omitempty"`
+	Undone    bool      `json:"undone"`
+}
+
+// UndoResult represents the result of restoring a single file
+type UndoResult struct {
+	FilePath string `json:"filePath"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+}
+
+// UndoReport represents the result of undoing a journal entry
+type UndoReport struct {
+	ID        string       `json:"id"`
+	Operation string       `json:"operation"`
+	Results   []UndoResult `json:"results"`
+}
+
+// Journal stores one JSON file per batch operation in a directory
+type Journal struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open opens the journal in dir, creating the directory if needed
+func Open(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %v", err)
+	}
+	return &Journal{dir: dir}, nil
+}
+
+// Begin adds a batch operation to the journal before its files are written and
+// returns its pending entry. No file may be written when it fails.
+func (j *Journal) Begin(operation string, files []FileRecord) (*Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := &Entry{
+		ID:        j.newID(),
+		Operation: operation,
+		Timestamp: time.Now(),
+		Files:     files,
+		Pending:   true,
+	}
+	if err := j.save(entry); err != nil {
+		return nil, err
+	}
+
+	j.prune()
+	return entry, nil
+}
+
+// Commit marks a pending entry as written, keeping only the files that were. An
+// entry none of whose files were written is removed.
+func (j *Journal) Commit(entry *Entry, written []string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	isWritten := make(map[string]bool, len(written))
+	for _, filePath := range written {
+		isWritten[filePath] = true
+	}
+	var files []FileRecord
+	for _, file := range entry.Files {
+		if isWritten[file.FilePath] {
+			files = append(files, file)
+		}
+	}
+
+	if len(files) == 0 {
+		if err := os.Remove(j.path(entry.ID)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove journal entry: %v", err)
+		}
+		return nil
+	}
+	entry.Files = files
+	entry.Pending = false
+	return j.save(entry)
+}
+
+// List returns a summary of every entry, newest first
+func (j *Journal) List() ([]Summary, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ids, err := j.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []Summary
+	for i := len(ids) - 1; i >= 0; i-- {
+		entry, err := j.load(ids[i])
+		if err != nil {
+			continue
+		}
+		summary := Summary{
+			ID:        entry.ID,
+			Operation: entry.Operation,
+			Timestamp: entry.Timestamp,
+			Pending:   entry.Pending,
+			Undone:    entry.Undone,
+		}
+		for _, file := range entry.Files {
+			summary.Files = append(summary.Files, file.FilePath)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// UndoLast undoes the most recent entry that has not been undone yet
+func (j *Journal) UndoLast() (*UndoReport, error) {
+	j.mu.Lock()
+	ids, err := j.ids()
+	j.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(ids) - 1; i >= 0; i-- {
+		j.mu.Lock()
+		entry, err := j.load(ids[i])
+		j.mu.Unlock()
+		if err != nil || entry.Undone {
+			continue
+		}
+		return j.Undo(entry.ID)
+	}
+	return nil, fmt.Errorf("no operation to undo")
+}
+
+// Undo restores the files of an entry to their pre-image. A file is only restored
+// while its content still matches what the operation wrote; files already back at
+// their pre-image count as restored. The entry is marked undone once every file is.
+func (j *Journal) Undo(id string) (*UndoReport, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, err := j.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Undone {
+		return nil, fmt.Errorf("operation '%s' has already been undone", id)
+	}
+
+	report := &UndoReport{ID: entry.ID, Operation: entry.Operation}
+	restored := 0
+
+	for _, file := range entry.Files {
+		result := UndoResult{
+			FilePath: file.FilePath,
+			Success:  false,
+		}
+
+		content, err := fileops.ReadFile(file.FilePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		switch fileops.ContentHash(content) {
+		case file.PreHash:
+			// Already restored, e.g. by an earlier partial undo
+		case file.PostHash:
+			if err := fileops.WriteFile(file.FilePath, []byte(file.PreContent)); err != nil {
+				result.Error = fmt.Sprintf("failed to write file: %v", err)
+				report.Results = append(report.Results, result)
+				continue
+			}
+		default:
+			result.Error = "file changed after the operation; restore it manually"
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		result.Success = true
+		restored++
+		report.Results = append(report.Results, result)
+	}
+
+	if restored == len(entry.Files) {
+		entry.Undone = true
+		if err := j.save(entry); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// newID returns a sortable, unused entry id
+func (j *Journal) newID() string {
+	base := time.Now().UTC().Format("20060102-150405.000000")
+	id := base
+	for n := 1; fileops.FileExists(j.path(id)); n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	return id
+}
+
+// ids returns the ids of all entries, oldest first
+func (j *Journal) ids() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(j.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = strings.TrimSuffix(filepath.Base(match), ".json")
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// prune removes the oldest entries beyond maxEntries
+func (j *Journal) prune() {
+	ids, err := j.ids()
+	if err != nil {
+		return
+	}
+	for len(ids) > maxEntries {
+		os.Remove(j.path(ids[0]))
+		ids = ids[1:]
+	}
+}
+
+func (j *Journal) path(id string) string {
+	return filepath.Join(j.dir, id+".json")
+}
+
+func (j *Journal) load(id string) (*Entry, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid operation id '%s'", id)
+	}
+
+	data, err := fileops.ReadFile(j.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("operation '%s' not found in journal", id)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to read journal entry '%s': %v", id, err)
+	}
+	return &entry, nil
+}
+
+func (j *Journal) save(entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %v", err)
+	}
+	if err := fileops.WriteFile(j.path(entry.ID), data); err != nil {
+		return fmt.Errorf("failed to write journal entry: %v", err)
+	}
+	return nil
+}
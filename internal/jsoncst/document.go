@@ -0,0 +1,398 @@
+package jsoncst
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"goldenMagic/internal/jsonpath"
+)
+
+// Kind identifies the JSON type of a node
+type Kind int
+
+const (
+	Object Kind = iota
+	Array
+	String
+	Number
+	Bool
+	Null
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Object:
+		return "object"
+	case Array:
+		return "array"
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case Bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// Node is a JSON value in the concrete syntax tree. It records where the
+// value lives in the source rather than copying it, so every byte outside
+// an edited span is preserved exactly.
+type Node struct {
+	Kind    Kind
+	Start   int       // offset of the first byte of the value
+	End     int       // offset just past the last byte of the value
+	Comma   int       // offset of the comma following the value in its container, -1 if none
+	Members []*Member // object members in document order
+	Elems   []*Node   // array elements in document order
+	Parent  *Node
+}
+
+// Member is a single key-value pair of an object
+type Member struct {
+	Key      string // decoded key
+	KeyStart int    // offset of the opening quote of the key
+	KeyEnd   int    // offset just past the closing quote of the key
+	Value    *Node
+}
+
+// Syntax selects the dialect a document is parsed with
+type Syntax int
+
+const (
+	// Strict is standard JSON
+	Strict Syntax = iota
+	// Lenient also accepts what JSONC and JSON5 config files commonly use: "//" and
+	// "/* */" comments, trailing commas, unquoted keys and single-quoted strings.
+	// The rest of JSON5, such as hexadecimal numbers or Infinity, is rejected.
+	Lenient
+)
+
+func (s Syntax) String() string {
+	if s == Lenient {
+		return "lenient"
+	}
+	return "strict"
+}
+
+// Document is a parsed JSON document bound to its source text
+type Document struct {
+	Src    string
+	Root   *Node
+	Syntax Syntax
+
+	comments []Token // comments in source order, lenient syntax only
+}
+
+// Match is a value selected by a path expression
+type Match struct {
+	Path   jsonpath.Path
+	Node   *Node
+	Member *Member // the member holding the value, nil for array elements and the root
+}
+
+// Parse builds the concrete syntax tree of a JSON document
+func Parse(src string) (*Document, error) {
+	return ParseAs(src, Strict)
+}
+
+// ParseAs builds the concrete syntax tree of a document in the given syntax.
+// Comments are kept in the source like whitespace, so edits preserve them.
+func ParseAs(src string, syntax Syntax) (*Document, error) {
+	p := &parser{lex: lexer{src: src, lenient: syntax == Lenient}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseValue(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.Kind != TokenEOF {
+		return nil, p.lex.errorf(p.tok.Start, "unexpected content after end of document")
+	}
+
+	return &Document{Src: src, Root: root, Syntax: syntax, comments: p.lex.comments}, nil
+}
+
+// Text returns the source text of a node
+func (d *Document) Text(n *Node) string {
+	return d.Src[n.Start:n.End]
+}
+
+// Value decodes a node into its Go representation
+func (d *Document) Value(n *Node) (any, error) {
+	text := d.Text(n)
+	if d.Syntax == Lenient {
+		standard, err := Standard(text)
+		if err != nil {
+			return nil, err
+		}
+		text = standard
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Equal reports whether a node holds the same value as a JSON text. Numbers are
+// compared exactly, so integers too large for a float64 are still told apart.
+func (d *Document) Equal(n *Node, valueJSON []byte) (bool, error) {
+	text := d.Text(n)
+	if d.Syntax == Lenient {
+		standard, err := Standard(text)
+		if err != nil {
+			return false, err
+		}
+		text = standard
+	}
+
+	decode := func(data []byte) (any, error) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var value any
+		err := decoder.Decode(&value)
+		return value, err
+	}
+	current, err := decode([]byte(text))
+	if err != nil {
+		return false, err
+	}
+	value, err := decode(valueJSON)
+	if err != nil {
+		return false, err
+	}
+	return equalValues(current, value), nil
+}
+
+// equalValues compares decoded JSON values whose numbers are json.Number
+func equalValues(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, found := b[key]
+			if !found || !equalValues(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalValues(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, xOK := new(big.Rat).SetString(string(a))
+		y, yOK := new(big.Rat).SetString(string(b))
+		return xOK && yOK && x.Cmp(y) == 0
+	}
+	return a == b
+}
+
+// Position converts a byte offset into a 1-based line and column
+func (d *Document) Position(offset int) (line, column int) {
+	return position(d.Src, offset)
+}
+
+// Walk visits every node in document order together with its concrete path.
+// Returning false from fn skips the children of the visited node.
+func (d *Document) Walk(fn func(path jsonpath.Path, n *Node, m *Member) bool) {
+	walk(jsonpath.Path{}, d.Root, nil, fn)
+}
+
+func walk(path jsonpath.Path, n *Node, m *Member, fn func(jsonpath.Path, *Node, *Member) bool) {
+	if !fn(path, n, m) {
+		return
+	}
+	for _, member := range n.Members {
+		walk(path.Child(member.Key), member.Value, member, fn)
+	}
+	for i, elem := range n.Elems {
+		walk(path.Elem(i), elem, nil, fn)
+	}
+}
+
+// Select returns every value whose path matches the expression, in document order
+func (d *Document) Select(path jsonpath.Path) []Match {
+	var matches []Match
+	d.Walk(func(p jsonpath.Path, n *Node, m *Member) bool {
+		if path.Match(p) {
+			matches = append(matches, Match{Path: p, Node: n, Member: m})
+		}
+		return true
+	})
+	return matches
+}
+
+// Member returns the member with the given key, or nil if the node has none
+func (n *Node) Member(key string) *Member {
+	for _, m := range n.Members {
+		if m.Key == key {
+			return m
+		}
+	}
+	return nil
+}
+
+// MemberIndex returns the position of the member with the given key, or -1
+func (n *Node) MemberIndex(key string) int {
+	for i, m := range n.Members {
+		if m.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// parser builds nodes from the token stream
+type parser struct {
+	lex lexer
+	tok Token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) expect(kind TokenKind, what string) (Token, error) {
+	tok := p.tok
+	if tok.Kind != kind {
+		return tok, p.lex.errorf(tok.Start, "expected %s", what)
+	}
+	return tok, p.advance()
+}
+
+func (p *parser) parseValue(parent *Node) (*Node, error) {
+	tok := p.tok
+	n := &Node{Start: tok.Start, End: tok.End, Comma: -1, Parent: parent}
+
+	switch tok.Kind {
+	case TokenLBrace:
+		n.Kind = Object
+		return n, p.parseObject(n)
+	case TokenLBracket:
+		n.Kind = Array
+		return n, p.parseArray(n)
+	case TokenString:
+		n.Kind = String
+	case TokenNumber:
+		n.Kind = Number
+	case TokenTrue, TokenFalse:
+		n.Kind = Bool
+	case TokenNull:
+		n.Kind = Null
+	case TokenEOF:
+		return nil, p.lex.errorf(tok.Start, "unexpected end of input")
+	default:
+		return nil, p.lex.errorf(tok.Start, "unexpected %q", p.lex.src[tok.Start:tok.End])
+	}
+
+	return n, p.advance()
+}
+
+func (p *parser) parseObject(n *Node) error {
+	if err := p.advance(); err != nil { // '{'
+		return err
+	}
+
+	for p.tok.Kind != TokenRBrace {
+		keyTok := p.tok
+		if keyTok.Kind != TokenString && keyTok.Kind != TokenIdent {
+			return p.lex.errorf(keyTok.Start, "expected object key")
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		key, err := decodeKey(p.lex.src[keyTok.Start:keyTok.End])
+		if err != nil {
+			return p.lex.errorf(keyTok.Start, "invalid key: %v", err)
+		}
+
+		if _, err := p.expect(TokenColon, fmt.Sprintf("':' after key '%s'", key)); err != nil {
+			return err
+		}
+
+		value, err := p.parseValue(n)
+		if err != nil {
+			return err
+		}
+		n.Members = append(n.Members, &Member{Key: key, KeyStart: keyTok.Start, KeyEnd: keyTok.End, Value: value})
+
+		if p.tok.Kind != TokenComma {
+			break
+		}
+		value.Comma = p.tok.Start
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if p.tok.Kind == TokenRBrace && !p.lex.lenient {
+			return p.lex.errorf(value.Comma, "trailing comma in object")
+		}
+	}
+
+	closeTok, err := p.expect(TokenRBrace, "',' or '}' in object")
+	if err != nil {
+		return err
+	}
+	n.End = closeTok.End
+	return nil
+}
+
+func (p *parser) parseArray(n *Node) error {
+	if err := p.advance(); err != nil { // '['
+		return err
+	}
+
+	for p.tok.Kind != TokenRBracket {
+		elem, err := p.parseValue(n)
+		if err != nil {
+			return err
+		}
+		n.Elems = append(n.Elems, elem)
+
+		if p.tok.Kind != TokenComma {
+			break
+		}
+		elem.Comma = p.tok.Start
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if p.tok.Kind == TokenRBracket && !p.lex.lenient {
+			return p.lex.errorf(elem.Comma, "trailing comma in array")
+		}
+	}
+
+	closeTok, err := p.expect(TokenRBracket, "',' or ']' in array")
+	if err != nil {
+		return err
+	}
+	n.End = closeTok.End
+	return nil
+}
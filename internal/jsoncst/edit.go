@@ -0,0 +1,411 @@
+package jsoncst
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"goldenMagic/internal/jsonpath"
+)
+
+// Edit replaces the source bytes in [Start, End) with Text
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Apply applies a set of non-overlapping edits to the source text.
+// Insertions at the same offset are applied in the order given.
+func Apply(src string, edits []Edit) (string, error) {
+	sorted := make([]Edit, len(edits))
+	copy(sorted, edits)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	var sb strings.Builder
+	last := 0
+	for _, e := range sorted {
+		if e.Start < last || e.End < e.Start || e.End > len(src) {
+			return "", fmt.Errorf("overlapping or invalid edit at offset %d", e.Start)
+		}
+		sb.WriteString(src[last:e.Start])
+		sb.WriteString(e.Text)
+		last = e.End
+	}
+	sb.WriteString(src[last:])
+
+	return sb.String(), nil
+}
+
+// Quote encodes a string as a JSON string literal without HTML escaping
+func Quote(s string) string {
+	text, _ := Marshal(s, "")
+	return text
+}
+
+// Marshal encodes a value as JSON. A non-empty indent unit produces
+// multi-line output; nested lines are indented relative to column zero
+// and are shifted to the insertion point by the edit helpers.
+func Marshal(value any, indentUnit string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indentUnit)
+	if err := enc.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// InsertMember returns an edit that inserts a member into an object at the given
+// position (len(obj.Members) appends). The new member reuses the whitespace that
+// separates the existing members, so it lines up with its siblings, and is written
+// on one line when the object is inline. Comments stay with the entries they follow.
+func (d *Document) InsertMember(obj *Node, index int, key, valueText string) Edit {
+	colon := d.colon(obj)
+	entry := func(indent string) string {
+		return Quote(key) + colon + reindent(valueText, indent)
+	}
+
+	if d.Inline(obj) {
+		valueText = inlineText(valueText, colon, d.inlineGap())
+	}
+
+	if len(obj.Members) == 0 {
+		return d.insertFirst(obj, entry)
+	}
+
+	starts := make([]int, len(obj.Members))
+	values := make([]*Node, len(obj.Members))
+	for i, m := range obj.Members {
+		starts[i] = m.KeyStart
+		values[i] = m.Value
+	}
+	gap := d.memberGap(obj, min(index, len(obj.Members)-1))
+	return d.insertEntry(obj, starts, values, index, entry(d.LineIndent(starts[0])), gap)
+}
+
+// InsertElem returns an edit that inserts a value into an array at the given
+// position (len(arr.Elems) appends), on one line when the array is inline
+func (d *Document) InsertElem(arr *Node, index int, valueText string) Edit {
+	if d.Inline(arr) {
+		valueText = inlineText(valueText, d.colon(arr), d.inlineGap())
+	}
+	entry := func(indent string) string {
+		return reindent(valueText, indent)
+	}
+
+	if len(arr.Elems) == 0 {
+		return d.insertFirst(arr, entry)
+	}
+
+	starts := make([]int, len(arr.Elems))
+	for i, elem := range arr.Elems {
+		starts[i] = elem.Start
+	}
+	gap := d.elemGap(arr, min(index, len(arr.Elems)-1))
+	return d.insertEntry(arr, starts, arr.Elems, index, entry(d.LineIndent(starts[0])), gap)
+}
+
+// insertFirst returns an edit that fills an empty container with its first entry,
+// written by entry for the given indentation. Comments inside the container are
+// kept in front of the new entry.
+func (d *Document) insertFirst(container *Node, entry func(indent string) string) Edit {
+	inline := d.Inline(container)
+	if last := d.lastComment(container.Start+1, container.End-1); last != -1 {
+		if inline {
+			return Edit{Start: last, End: last, Text: " " + entry("")}
+		}
+		indent := d.ChildIndent(container)
+		return Edit{Start: last, End: last, Text: "\n" + indent + entry(indent)}
+	}
+
+	if inline {
+		return Edit{Start: container.Start + 1, End: container.End - 1, Text: entry("")}
+	}
+	indent := d.ChildIndent(container)
+	return Edit{
+		Start: container.Start + 1,
+		End:   container.End - 1,
+		Text:  "\n" + indent + entry(indent) + "\n" + d.LineIndent(container.Start),
+	}
+}
+
+// insertEntry returns an edit that inserts the text of an entry into a non-empty
+// container before the entry at index, or after the last one. Starts holds the
+// offsets the entries begin at and values their values; gap is the whitespace to
+// separate the new entry from its neighbour.
+func (d *Document) insertEntry(container *Node, starts []int, values []*Node, index int, text, gap string) Edit {
+	last := values[len(values)-1]
+	if index == len(starts) && last.Comma == -1 {
+		// Append after the last value, past a comment trailing it on its line
+		end := d.lineEnd(last.End)
+		return Edit{Start: last.End, End: end, Text: "," + d.Src[last.End:end] + gap + text}
+	}
+
+	// The entry follows the opening bracket or the comma of the entry before it
+	anchor := container.Start + 1
+	if index > 0 {
+		anchor = values[index-1].Comma + 1
+	}
+	next := container.End - 1
+	if index < len(starts) {
+		next = starts[index]
+	}
+
+	switch {
+	case strings.Contains(d.Src[anchor:next], "\n"):
+		// A new line of its own, below any comment trailing the previous line
+		end := d.lineEnd(anchor)
+		return Edit{Start: end, End: end, Text: gap + text + ","}
+	case index == len(starts):
+		// Before the closing bracket of an inline container with a trailing comma
+		return Edit{Start: anchor, End: anchor, Text: gap + text + ","}
+	}
+	return Edit{Start: next, End: next, Text: text + "," + gap}
+}
+
+// ReplaceValue returns an edit that replaces a value, keeping its position and
+// indentation, and keeping it on one line inside an inline container
+func (d *Document) ReplaceValue(n *Node, valueText string) Edit {
+	if n.Parent != nil && d.Inline(n.Parent) {
+		valueText = inlineText(valueText, d.colon(n.Parent), d.inlineGap())
+	}
+	return Edit{Start: n.Start, End: n.End, Text: reindent(valueText, d.LineIndent(n.Start))}
+}
+
+// RenameKey returns an edit that replaces the key of a member
+func (d *Document) RenameKey(m *Member, newKey string) Edit {
+	return Edit{Start: m.KeyStart, End: m.KeyEnd, Text: Quote(newKey)}
+}
+
+// memberGap returns the whitespace that separates the member at index from
+// the one before it
+func (d *Document) memberGap(obj *Node, index int) string {
+	if index == 0 {
+		gap := d.Src[obj.Start+1 : obj.Members[0].KeyStart]
+		if strings.Contains(gap, "\n") {
+			return plainGap(gap)
+		}
+		if len(obj.Members) > 1 {
+			return d.memberGap(obj, 1)
+		}
+		return d.inlineGap()
+	}
+	return plainGap(d.Src[obj.Members[index-1].Value.Comma+1 : obj.Members[index].KeyStart])
+}
+
+// elemGap returns the whitespace that separates the element at index from
+// the one before it
+func (d *Document) elemGap(arr *Node, index int) string {
+	if index == 0 {
+		gap := d.Src[arr.Start+1 : arr.Elems[0].Start]
+		if strings.Contains(gap, "\n") {
+			return plainGap(gap)
+		}
+		if len(arr.Elems) > 1 {
+			return d.elemGap(arr, 1)
+		}
+		return d.inlineGap()
+	}
+	return plainGap(d.Src[arr.Elems[index-1].Comma+1 : arr.Elems[index].Start])
+}
+
+// LineIndent returns the leading whitespace of the line containing offset
+func (d *Document) LineIndent(offset int) string {
+	lineStart := strings.LastIndexByte(d.Src[:offset], '\n') + 1
+	end := lineStart
+	for end < len(d.Src) && (d.Src[end] == ' ' || d.Src[end] == '\t') {
+		end++
+	}
+	return d.Src[lineStart:end]
+}
+
+// ChildIndent returns the indentation used for the children of a container
+func (d *Document) ChildIndent(container *Node) string {
+	switch {
+	case len(container.Members) > 0:
+		return d.LineIndent(container.Members[0].KeyStart)
+	case len(container.Elems) > 0:
+		return d.LineIndent(container.Elems[0].Start)
+	}
+	return d.LineIndent(container.Start) + d.IndentUnit()
+}
+
+// IndentUnit detects the indentation step used by the document, defaulting to two spaces
+func (d *Document) IndentUnit() string {
+	unit := ""
+	d.Walk(func(_ jsonpath.Path, n *Node, _ *Member) bool {
+		if unit != "" {
+			return false
+		}
+		var first int
+		switch {
+		case len(n.Members) > 0:
+			first = n.Members[0].KeyStart
+		case len(n.Elems) > 0:
+			first = n.Elems[0].Start
+		default:
+			return true
+		}
+		outer, inner := d.LineIndent(n.Start), d.LineIndent(first)
+		if d.lineOf(first) != d.lineOf(n.Start) && len(inner) > len(outer) && strings.HasPrefix(inner, outer) {
+			unit = inner[len(outer):]
+		}
+		return true
+	})
+
+	if unit == "" {
+		return "  "
+	}
+	return unit
+}
+
+// lineOf returns the zero-based line number of an offset
+func (d *Document) lineOf(offset int) int {
+	return strings.Count(d.Src[:offset], "\n")
+}
+
+// reindent shifts every line after the first by indent, so a multi-line value
+// produced at column zero lines up with the position it is inserted at
+func reindent(text, indent string) string {
+	if !strings.Contains(text, "\n") {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RemoveMembers returns the edits that remove the members at the given indices
+// from an object, keeping the commas between the remaining members valid
+func (d *Document) RemoveMembers(obj *Node, indices []int) []Edit {
+	starts := make([]int, len(obj.Members))
+	values := make([]*Node, len(obj.Members))
+	for i, m := range obj.Members {
+		starts[i] = m.KeyStart
+		values[i] = m.Value
+	}
+	return d.removeEntries(obj, starts, values, indices)
+}
+
+// RemoveElems returns the edits that remove the elements at the given indices from an array
+func (d *Document) RemoveElems(arr *Node, indices []int) []Edit {
+	starts := make([]int, len(arr.Elems))
+	for i, elem := range arr.Elems {
+		starts[i] = elem.Start
+	}
+	return d.removeEntries(arr, starts, arr.Elems, indices)
+}
+
+// removeEntries removes entries of a container. An entry followed by a kept entry
+// is cut up to the start of its successor, taking its comma and the following
+// whitespace with it. A trailing run of removed entries is cut from the comma of
+// the last kept entry, so the new last entry is left without a comma. A comment
+// trailing a removed entry on its line goes with it; other comments between
+// entries are never cut.
+func (d *Document) removeEntries(container *Node, starts []int, values []*Node, indices []int) []Edit {
+	removed := make(map[int]bool, len(indices))
+	for _, i := range indices {
+		removed[i] = true
+	}
+
+	lastKept := -1
+	for i := range values {
+		if !removed[i] {
+			lastKept = i
+		}
+	}
+
+	if lastKept == -1 && !d.hasComment(container.Start+1, container.End-1) {
+		return []Edit{{Start: container.Start + 1, End: container.End - 1, Text: ""}}
+	}
+
+	var edits []Edit
+	for i := 0; i < lastKept; i++ {
+		if removed[i] {
+			edits = append(edits, Edit{Start: starts[i], End: d.skipSpace(d.lineEnd(values[i].Comma + 1)), Text: ""})
+		}
+	}
+
+	// A trailing comma after the last entry stays, otherwise the last kept entry's
+	// comma goes
+	last := len(values) - 1
+	if lastKept >= 0 && lastKept < last && values[last].Comma == -1 {
+		comma := values[lastKept].Comma
+		edits = append(edits, Edit{Start: comma, End: comma + 1, Text: ""})
+	}
+	for i := lastKept + 1; i <= last; i++ {
+		end := values[i].End
+		if values[i].Comma != -1 {
+			end = values[i].Comma + 1
+		}
+		if eol := d.lineEnd(end); d.hasComment(end, eol) {
+			end = eol
+		}
+		edits = append(edits, Edit{Start: d.skipSpaceBack(starts[i], container.Start+1), End: end, Text: ""})
+	}
+	return edits
+}
+
+// skipSpace returns the offset of the first character at or after offset that is
+// not whitespace
+func (d *Document) skipSpace(offset int) int {
+	for offset < len(d.Src) && strings.IndexByte(" \t\r\n", d.Src[offset]) != -1 {
+		offset++
+	}
+	return offset
+}
+
+// skipSpaceBack returns the offset just past the last character before offset that
+// is not whitespace, but not less than limit
+func (d *Document) skipSpaceBack(offset, limit int) int {
+	for offset > limit && strings.IndexByte(" \t\r\n", d.Src[offset-1]) != -1 {
+		offset--
+	}
+	return offset
+}
+
+// Detach returns the source text of a node with its continuation lines shifted back
+// to column zero, ready to be inserted elsewhere with the edit helpers
+func (d *Document) Detach(n *Node) string {
+	text := d.Text(n)
+	indent := d.LineIndent(n.Start)
+	if indent == "" || !strings.Contains(text, "\n") {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = strings.TrimPrefix(lines[i], indent)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatRaw formats a JSON value supplied by the user, keeping its member order.
+// A non-empty indent unit spreads non-empty objects and arrays over several lines.
+func FormatRaw(raw []byte, indentUnit string) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", err
+	}
+	compact := buf.Bytes()
+	if indentUnit == "" || len(compact) < 3 || (compact[0] != '{' && compact[0] != '[') {
+		return string(compact), nil
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact, "", indentUnit); err != nil {
+		return "", err
+	}
+	return indented.String(), nil
+}
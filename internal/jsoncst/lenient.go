@@ -0,0 +1,143 @@
+package jsoncst
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Standard converts a document in lenient syntax into standard JSON by dropping
+// comments and trailing commas, quoting unquoted keys and rewriting single-quoted
+// strings. Whitespace is dropped as well, so the result is meant for decoding and
+// validation rather than for writing back.
+func Standard(src string) (string, error) {
+	if _, err := ParseAs(src, Lenient); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	l := lexer{src: src, lenient: true}
+	comma := false
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return "", err
+		}
+		if tok.Kind == TokenEOF {
+			return sb.String(), nil
+		}
+
+		// A comma is only written once it is known not to be trailing
+		if comma && tok.Kind != TokenRBrace && tok.Kind != TokenRBracket {
+			sb.WriteByte(',')
+		}
+		comma = tok.Kind == TokenComma
+
+		text := src[tok.Start:tok.End]
+		switch tok.Kind {
+		case TokenComma:
+		case TokenIdent:
+			sb.WriteString(Quote(text))
+		case TokenString:
+			sb.WriteString(doubleQuoted(text))
+		default:
+			sb.WriteString(text)
+		}
+	}
+}
+
+// decodeKey decodes an object key, which is a quoted string or an unquoted identifier
+func decodeKey(text string) (string, error) {
+	if text[0] != '"' && text[0] != '\'' {
+		return text, nil
+	}
+	var key string
+	err := json.Unmarshal([]byte(doubleQuoted(text)), &key)
+	return key, err
+}
+
+// doubleQuoted rewrites a single-quoted string literal as a JSON string literal
+func doubleQuoted(text string) string {
+	if text[0] != '\'' {
+		return text
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	inner := text[1 : len(text)-1]
+	for i := 0; i < len(inner); i++ {
+		switch c := inner[i]; {
+		case c == '\\' && i+1 < len(inner) && inner[i+1] == '\'':
+			sb.WriteByte('\'')
+			i++
+		case c == '\\' && i+1 < len(inner):
+			sb.WriteString(inner[i : i+2])
+			i++
+		case c == '"':
+			sb.WriteString(`\"`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// hasComment reports whether a comment lies within [start, end)
+func (d *Document) hasComment(start, end int) bool {
+	i := sort.Search(len(d.comments), func(i int) bool {
+		return d.comments[i].Start >= start
+	})
+	return i < len(d.comments) && d.comments[i].End <= end
+}
+
+// lastComment returns the end of the last comment within [start, end), or -1
+func (d *Document) lastComment(start, end int) int {
+	last := -1
+	for _, c := range d.comments {
+		if c.Start >= start && c.End <= end {
+			last = c.End
+		}
+	}
+	return last
+}
+
+// lineEnd returns the offset of the line break after offset when only whitespace
+// and comments lie in between, so text inserted there stays clear of a comment
+// that trails the previous entry. Otherwise offset is returned unchanged.
+func (d *Document) lineEnd(offset int) int {
+	pos := offset
+	for pos < len(d.Src) {
+		switch d.Src[pos] {
+		case ' ', '\t':
+			pos++
+		case '\r', '\n':
+			return pos
+		case '/':
+			i := sort.Search(len(d.comments), func(i int) bool {
+				return d.comments[i].Start >= pos
+			})
+			if i == len(d.comments) || d.comments[i].Start != pos {
+				return offset
+			}
+			pos = d.comments[i].End
+		default:
+			return offset
+		}
+	}
+	return pos
+}
+
+// plainGap strips comments from whitespace copied between entries, keeping the
+// line break and the indentation of the line the next entry starts on
+func plainGap(gap string) string {
+	if !strings.Contains(gap, "/") {
+		return gap
+	}
+	newline := strings.LastIndexByte(gap, '\n')
+	if newline == -1 {
+		return " "
+	}
+	indent := gap[newline+1:]
+	return "\n" + indent[:len(indent)-len(strings.TrimLeft(indent, " \t"))]
+}
@@ -0,0 +1,293 @@
+package jsoncst
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind identifies the type of a lexical token
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenLBrace
+	TokenRBrace
+	TokenLBracket
+	TokenRBracket
+	TokenColon
+	TokenComma
+	TokenString
+	TokenNumber
+	TokenTrue
+	TokenFalse
+	TokenNull
+	TokenIdent   // unquoted object key, lenient syntax only
+	TokenComment // line or block comment, lenient syntax only
+)
+
+// Token is a lexical token together with its byte span in the source.
+// Whitespace between tokens is not represented by tokens; it is kept
+// untouched in the source and recovered from the gaps between spans.
+type Token struct {
+	Kind  TokenKind
+	Start int
+	End   int
+}
+
+// SyntaxError describes a malformed document
+type SyntaxError struct {
+	Offset int
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// punctuation maps single-character tokens to their kinds
+var punctuation = map[byte]TokenKind{
+	'{': TokenLBrace,
+	'}': TokenRBrace,
+	'[': TokenLBracket,
+	']': TokenRBracket,
+	':': TokenColon,
+	',': TokenComma,
+}
+
+// lexer splits a JSON document into tokens. In lenient mode comments are skipped
+// like whitespace and recorded, strings may use single quotes and object keys may
+// be unquoted identifiers.
+type lexer struct {
+	src      string
+	pos      int
+	lenient  bool
+	comments []Token
+}
+
+func (l *lexer) errorf(offset int, format string, args ...any) error {
+	line, col := position(l.src, offset)
+	return &SyntaxError{Offset: offset, Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next returns the next token, skipping whitespace
+func (l *lexer) next() (Token, error) {
+	if err := l.skipSpace(); err != nil {
+		return Token{}, err
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return Token{Kind: TokenEOF, Start: start, End: start}, nil
+	}
+
+	c := l.src[l.pos]
+	if kind, ok := punctuation[c]; ok {
+		l.pos++
+		return Token{Kind: kind, Start: start, End: l.pos}, nil
+	}
+
+	switch {
+	case c == '"' || (c == '\'' && l.lenient):
+		if err := l.scanString(); err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TokenString, Start: start, End: l.pos}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		if err := l.scanNumber(); err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TokenNumber, Start: start, End: l.pos}, nil
+	case l.lenient && isIdentStart(c):
+		return l.scanIdent(), nil
+	case strings.HasPrefix(l.src[l.pos:], "true"):
+		l.pos += 4
+		return Token{Kind: TokenTrue, Start: start, End: l.pos}, nil
+	case strings.HasPrefix(l.src[l.pos:], "false"):
+		l.pos += 5
+		return Token{Kind: TokenFalse, Start: start, End: l.pos}, nil
+	case strings.HasPrefix(l.src[l.pos:], "null"):
+		l.pos += 4
+		return Token{Kind: TokenNull, Start: start, End: l.pos}, nil
+	}
+
+	return Token{}, l.errorf(start, "unexpected character %q", c)
+}
+
+// skipSpace skips whitespace and, in lenient mode, comments
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case ' ', '\t', '\r', '\n':
+			l.pos++
+		case '/':
+			if !l.lenient {
+				return nil
+			}
+			if err := l.scanComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// scanComment consumes a "//" comment up to the end of its line or a "/* */" comment
+func (l *lexer) scanComment() error {
+	start := l.pos
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "//"):
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		if end == -1 {
+			end = len(l.src) - l.pos
+		}
+		l.pos += end
+		if l.pos > start && l.src[l.pos-1] == '\r' {
+			l.pos--
+		}
+	case strings.HasPrefix(l.src[l.pos:], "/*"):
+		end := strings.Index(l.src[l.pos+2:], "*/")
+		if end == -1 {
+			return l.errorf(start, "unterminated comment")
+		}
+		l.pos += end + 4
+	default:
+		return l.errorf(start, "unexpected character '/'")
+	}
+	l.comments = append(l.comments, Token{Kind: TokenComment, Start: start, End: l.pos})
+	return nil
+}
+
+// scanIdent consumes an unquoted word, which is a literal or an object key
+func (l *lexer) scanIdent() Token {
+	start := l.pos
+	for l.pos < len(l.src) && (isIdentStart(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+		l.pos++
+	}
+	kind := TokenIdent
+	switch l.src[start:l.pos] {
+	case "true":
+		kind = TokenTrue
+	case "false":
+		kind = TokenFalse
+	case "null":
+		kind = TokenNull
+	}
+	return Token{Kind: kind, Start: start, End: l.pos}
+}
+
+// scanString consumes a string literal including its quotes
+func (l *lexer) scanString() error {
+	start := l.pos
+	quote := l.src[l.pos]
+	l.pos++ // opening quote
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == quote:
+			l.pos++
+			return nil
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return l.errorf(l.pos, "unterminated escape sequence")
+			}
+			if l.src[l.pos+1] == 'u' {
+				if l.pos+6 > len(l.src) || !isHex(l.src[l.pos+2:l.pos+6]) {
+					return l.errorf(l.pos, "invalid unicode escape")
+				}
+				l.pos += 6
+				continue
+			}
+			if !strings.ContainsRune(`"\/bfnrt`, rune(l.src[l.pos+1])) && !(l.lenient && l.src[l.pos+1] == '\'') {
+				return l.errorf(l.pos, "invalid escape sequence '\\%c'", l.src[l.pos+1])
+			}
+			l.pos += 2
+		case c < 0x20:
+			return l.errorf(l.pos, "invalid control character in string")
+		default:
+			l.pos++
+		}
+	}
+	return l.errorf(start, "unterminated string")
+}
+
+// scanNumber consumes a number according to the JSON grammar
+func (l *lexer) scanNumber() error {
+	start := l.pos
+	if l.peek() == '-' {
+		l.pos++
+	}
+
+	switch {
+	case l.peek() == '0':
+		l.pos++
+	case isDigit(l.peek()):
+		l.skipDigits()
+	default:
+		return l.errorf(start, "invalid number")
+	}
+
+	if l.peek() == '.' {
+		l.pos++
+		if !isDigit(l.peek()) {
+			return l.errorf(start, "invalid number")
+		}
+		l.skipDigits()
+	}
+
+	if c := l.peek(); c == 'e' || c == 'E' {
+		l.pos++
+		if c := l.peek(); c == '+' || c == '-' {
+			l.pos++
+		}
+		if !isDigit(l.peek()) {
+			return l.errorf(start, "invalid number")
+		}
+		l.skipDigits()
+	}
+
+	return nil
+}
+
+func (l *lexer) peek() byte {
+	if l.pos < len(l.src) {
+		return l.src[l.pos]
+	}
+	return 0
+}
+
+func (l *lexer) skipDigits() {
+	for isDigit(l.peek()) {
+		l.pos++
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !isDigit(c) && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(src string, offset int) (int, int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	line := 1 + strings.Count(src[:offset], "\n")
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	return line, offset - lineStart + 1
+}
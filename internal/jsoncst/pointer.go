@@ -0,0 +1,81 @@
+package jsoncst
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer '%s' must start with '/'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// Resolve follows reference tokens from the root and returns the node they point to
+func (d *Document) Resolve(tokens []string) (*Node, error) {
+	node := d.Root
+	for i, token := range tokens {
+		switch node.Kind {
+		case Object:
+			m := node.Member(token)
+			if m == nil {
+				return nil, fmt.Errorf("member '%s' not found at '%s'", token, FormatPointer(tokens[:i]))
+			}
+			node = m.Value
+		case Array:
+			index, err := ArrayIndex(token, len(node.Elems))
+			if err != nil {
+				return nil, err
+			}
+			if index >= len(node.Elems) {
+				return nil, fmt.Errorf("index %s out of range at '%s'", token, FormatPointer(tokens[:i]))
+			}
+			node = node.Elems[index]
+		default:
+			return nil, fmt.Errorf("cannot descend into %s at '%s'", node.Kind, FormatPointer(tokens[:i]))
+		}
+	}
+	return node, nil
+}
+
+// arrayIndexToken matches the array indices RFC 6901 allows: "0" or digits without
+// a leading zero, so "+1", "-0" and "01" are rejected
+var arrayIndexToken = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+
+// ArrayIndex converts a reference token into an array index. The "-" token refers
+// to the position just past the last element.
+func ArrayIndex(token string, length int) (int, error) {
+	if token == "-" {
+		return length, nil
+	}
+	if !arrayIndexToken.MatchString(token) {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+	return index, nil
+}
+
+// FormatPointer joins reference tokens back into a JSON Pointer
+func FormatPointer(tokens []string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return sb.String()
+}
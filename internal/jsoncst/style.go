@@ -0,0 +1,113 @@
+package jsoncst
+
+import (
+	"strings"
+
+	"goldenMagic/internal/jsonpath"
+)
+
+// Inline reports whether the entries of a container share the line of its opening
+// bracket, as in minified documents or short objects such as {"x": 1, "y": 2}.
+// An empty container follows its parent, so "{}" in a minified document is inline
+// while "{}" in a pretty-printed one opens onto new lines.
+func (d *Document) Inline(container *Node) bool {
+	switch {
+	case len(container.Members) > 0:
+		return !strings.Contains(d.Src[container.Start:container.Members[0].KeyStart], "\n")
+	case len(container.Elems) > 0:
+		return !strings.Contains(d.Src[container.Start:container.Elems[0].Start], "\n")
+	case strings.Contains(d.Text(container), "\n"):
+		return false
+	case container.Parent != nil:
+		return d.Inline(container.Parent)
+	}
+	return !strings.Contains(d.Src, "\n")
+}
+
+// colon returns the text between the keys and values of an object, e.g. ": " or
+// ":", taken from the object itself or else from the first object in the document
+func (d *Document) colon(obj *Node) string {
+	if len(obj.Members) > 0 {
+		m := obj.Members[0]
+		return plainColon(d.Src[m.KeyEnd:m.Value.Start])
+	}
+
+	colon, found := ": ", false
+	d.Walk(func(_ jsonpath.Path, n *Node, m *Member) bool {
+		if !found && m != nil {
+			colon, found = plainColon(d.Src[m.KeyEnd:n.Start]), true
+		}
+		return !found
+	})
+	return colon
+}
+
+// plainColon returns the colon between a key and its value, or ": " when it is
+// surrounded by a comment or a line break that should not be copied
+func plainColon(colon string) string {
+	if strings.ContainsAny(colon, "/\n") {
+		return ": "
+	}
+	return colon
+}
+
+// inlineGap returns the whitespace after the commas of inline containers, e.g. " "
+// or "" in a minified document, for containers with no sibling gap to copy
+func (d *Document) inlineGap() string {
+	gap, found := " ", false
+	d.Walk(func(_ jsonpath.Path, n *Node, _ *Member) bool {
+		if found {
+			return false
+		}
+		var next int
+		switch {
+		case len(n.Members) > 1:
+			next = n.Members[1].KeyStart
+		case len(n.Elems) > 1:
+			next = n.Elems[1].Start
+		default:
+			return true
+		}
+		if between := d.Src[d.entryComma(n)+1 : next]; !strings.ContainsAny(between, "\n/") {
+			gap, found = between, true
+		}
+		return true
+	})
+	return gap
+}
+
+// entryComma returns the offset of the comma after the first entry of a container
+func (d *Document) entryComma(container *Node) int {
+	if len(container.Members) > 0 {
+		return container.Members[0].Value.Comma
+	}
+	return container.Elems[0].Comma
+}
+
+// inlineText writes a JSON value on a single line, separating keys from values with
+// colon and entries with a comma followed by gap. The value is expected to be valid
+// JSON, as produced by Marshal or FormatRaw; anything else is returned unchanged.
+func inlineText(text, colon, gap string) string {
+	if !strings.ContainsAny(text, "\n:,") {
+		return text
+	}
+
+	var sb strings.Builder
+	l := lexer{src: text}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return text
+		}
+		switch tok.Kind {
+		case TokenEOF:
+			return sb.String()
+		case TokenColon:
+			sb.WriteString(colon)
+		case TokenComma:
+			sb.WriteString("," + gap)
+		default:
+			sb.WriteString(text[tok.Start:tok.End])
+		}
+	}
+}
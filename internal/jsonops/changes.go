@@ -0,0 +1,142 @@
+package jsonops
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"goldenMagic/internal/fileops"
+)
+
+// FileChange is the modification an operation makes to a single file. In dry-run
+// mode it is returned without touching disk and can later be passed to CommitChanges.
+type FileChange struct {
+	FilePath        string `json:"filePath"`
+	BaseHash        string `json:"baseHash"`
+	ModifiedContent string `json:"modifiedContent"`
+	Diff            string `json:"diff"`
+	LinesAdded      int    `json:"linesAdded"`
+	LinesRemoved    int    `json:"linesRemoved"`
+
+	// Original is the content the change was made against, kept for the undo journal
+	Original string `json:"-"`
+}
+
+// CommitResult represents the result of committing a previewed change
+type CommitResult struct {
+	FilePath string      `json:"filePath"`
+	Success  bool        `json:"success"`
+	Error    string      `json:"error,omitempty"`
+	Warning  string      `json:"warning,omitempty"`
+	Change   *FileChange `json:"-"`
+}
+
+// NewFileChange describes the change from the original to the modified content,
+// including a unified diff and the number of lines added and removed
+func NewFileChange(filePath string, original []byte, modified string) FileChange {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(original)),
+		B:        difflib.SplitLines(modified),
+		FromFile: "a/" + strings.TrimPrefix(filepath.ToSlash(filePath), "/"),
+		ToFile:   "b/" + strings.TrimPrefix(filepath.ToSlash(filePath), "/"),
+		Context:  3,
+	})
+
+	change := FileChange{
+		FilePath:        filePath,
+		BaseHash:        fileops.ContentHash(original),
+		ModifiedContent: modified,
+		Original:        string(original),
+		Diff:            diff,
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			change.LinesAdded++
+		case strings.HasPrefix(line, "-"):
+			change.LinesRemoved++
+		}
+	}
+	return change
+}
+
+// CommitChanges writes previously previewed changes. A file whose content no longer
+// matches the hash it was previewed against is refused and left untouched. The
+// changes that pass these checks, holding the content they replace, are passed to
+// record, when it is set, before any file is written; if it fails nothing is
+// written and its error is returned.
+func CommitChanges(changes []FileChange, syntaxes fileops.Syntaxes, record func(changes []FileChange) error) ([]CommitResult, error) {
+	results := make([]CommitResult, len(changes))
+	var checked []FileChange
+	var indices []int
+
+	for i, change := range changes {
+		results[i] = CommitResult{
+			FilePath: change.FilePath,
+			Success:  false,
+		}
+
+		content, err := fileops.ReadFile(change.FilePath)
+		if err != nil {
+			results[i].Error = fmt.Sprintf("failed to read file: %v", err)
+			continue
+		}
+
+		if fileops.ContentHash(content) != change.BaseHash {
+			results[i].Error = "file changed since the preview was made; preview again before committing"
+			continue
+		}
+
+		if err := validateJSON(change.FilePath, change.ModifiedContent, syntaxes); err != nil {
+			results[i].Error = fmt.Sprintf("result is not valid %s, file left unchanged: %v", fileops.FormatName(change.FilePath), err)
+			continue
+		}
+
+		change.Original = string(content)
+		checked = append(checked, change)
+		indices = append(indices, i)
+	}
+
+	if record != nil && len(checked) > 0 {
+		if err := record(checked); err != nil {
+			return nil, err
+		}
+	}
+
+	for k, change := range checked {
+		result := &results[indices[k]]
+		if err := fileops.WriteFile(change.FilePath, []byte(change.ModifiedContent)); err != nil {
+			result.Error = fmt.Sprintf("failed to write file: %v", err)
+			continue
+		}
+
+		committed := change
+		result.Success = true
+		result.Change = &committed
+	}
+
+	return results, nil
+}
+
+// writeChange records the change made to a file and writes it unless dryRun is set.
+// The original and the result must be valid in the syntax of the file's extension,
+// so comments are only accepted where allowed and a faulty edit never reaches disk.
+func writeChange(filePath string, original []byte, modified string, dryRun bool, syntaxes fileops.Syntaxes) (*FileChange, error) {
+	if err := validateJSON(filePath, string(original), syntaxes); err != nil {
+		return nil, fmt.Errorf("invalid %s content: %v", fileops.FormatName(filePath), err)
+	}
+	if err := validateJSON(filePath, modified, syntaxes); err != nil {
+		return nil, fmt.Errorf("result is not valid %s, file left unchanged: %v", fileops.FormatName(filePath), err)
+	}
+
+	change := NewFileChange(filePath, original, modified)
+	if !dryRun {
+		if err := fileops.WriteFile(filePath, []byte(modified)); err != nil {
+			return nil, fmt.Errorf("failed to write file: %v", err)
+		}
+	}
+	return &change, nil
+}
@@ -0,0 +1,109 @@
+package jsonops
+
+import (
+	"fmt"
+	"strings"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsonpath"
+	"goldenMagic/internal/yamldoc"
+)
+
+// RecordError is the error an operation failed with on one record of a JSON Lines
+// file
+type RecordError struct {
+	Record int    `json:"record"`
+	Error  string `json:"error"`
+}
+
+// editFile applies an edit of JSON content to the content of a file. The records of
+// a JSON Lines file are edited one by one: records the edit fails on are left
+// unchanged and returned with their errors, and the file only fails, with the error
+// of its first record, when no record changed. A YAML document is edited through
+// its JSON form, keeping the comments and anchors of everything the edit leaves
+// alone. Every other file is edited as a whole.
+func editFile(filePath, content string, edit func(record string) (string, error)) (string, []RecordError, error) {
+	modified, failed, _, err := editFileChanges(filePath, content, edit)
+	return modified, failed, err
+}
+
+// editFileChanges is editFile that also returns what the edit changed in the node
+// tree of a YAML document, where an anchored value appears in the JSON form once
+// for every alias of it. It is nil for other files.
+func editFileChanges(filePath, content string, edit func(record string) (string, error)) (string, []RecordError, *yamldoc.Changes, error) {
+	if fileops.IsYAMLFile(filePath) {
+		modified, changes, err := yamldoc.Edit(content, edit)
+		return modified, nil, &changes, err
+	}
+	if !fileops.IsRecordFile(filePath) {
+		modified, err := edit(content)
+		return modified, nil, nil, err
+	}
+
+	var sb strings.Builder
+	var firstErr error
+	var failed []RecordError
+	changed, last := false, 0
+	for _, record := range fileops.SplitRecords(content) {
+		text := content[record.Start:record.End]
+		modified, err := edit(text)
+		if err == nil && strings.ContainsAny(modified, "\r\n") {
+			err = fmt.Errorf("the edited record would span several lines")
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("record %d: %w", record.Number, err)
+			}
+			failed = append(failed, RecordError{Record: record.Number, Error: err.Error()})
+			continue
+		}
+		if modified == text {
+			continue
+		}
+
+		sb.WriteString(content[last:record.Start])
+		sb.WriteString(modified)
+		last = record.End
+		changed = true
+	}
+
+	if !changed && firstErr != nil {
+		return content, failed, nil, firstErr
+	}
+	sb.WriteString(content[last:])
+	return sb.String(), failed, nil, nil
+}
+
+// eachRecord calls fn with the JSON content of every record of a JSON Lines file,
+// with the JSON form of a YAML document, or with the whole content of any other file
+// and record 0. The locate function moves a position within that JSON, found at a
+// path, to the position in the file.
+func eachRecord(filePath, content string, fn func(record int, text string, locate func(path string, line, column int) (int, int))) error {
+	switch {
+	case fileops.IsYAMLFile(filePath):
+		doc, err := yamldoc.Parse(content)
+		if err != nil {
+			return fmt.Errorf("error parsing YAML: %v", err)
+		}
+		fn(0, doc.JSON(), func(path string, line, column int) (int, int) {
+			if p, err := jsonpath.Parse(path); err == nil {
+				if yamlLine, yamlColumn, _, _, ok := doc.Locate(p); ok {
+					return yamlLine, yamlColumn
+				}
+			}
+			return line, column
+		})
+	case fileops.IsRecordFile(filePath):
+		for _, record := range fileops.SplitRecords(content) {
+			offset := record.Line - 1
+			fn(record.Number, content[record.Start:record.End], func(_ string, line, column int) (int, int) {
+				return line + offset, column
+			})
+		}
+	default:
+		fn(0, content, func(_ string, line, column int) (int, int) {
+			return line, column
+		})
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package jsonops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
+)
+
+// OccurrenceMode selects which candidate locations an operation applies to
+type OccurrenceMode string
+
+const (
+	OccurrenceAll   OccurrenceMode = "all"   // every candidate (the default)
+	OccurrenceFirst OccurrenceMode = "first" // the first candidate in document order
+	OccurrenceLast  OccurrenceMode = "last"  // the last candidate in document order
+	OccurrenceNth   OccurrenceMode = "nth"   // the N-th candidate, counting from 1
+	OccurrencePaths OccurrenceMode = "paths" // the candidates at the given full paths
+)
+
+// Occurrence selects among the locations an operation could apply to when a context
+// path or target key occurs several times in a file. The zero value selects all.
+type Occurrence struct {
+	Mode  OccurrenceMode `json:"mode,omitempty"`
+	N     int            `json:"n,omitempty"`
+	Paths []string       `json:"paths,omitempty"`
+}
+
+// ParseOccurrence parses "all", "first", "last" or a 1-based position such as "3"
+func ParseOccurrence(text string) (Occurrence, error) {
+	switch mode := OccurrenceMode(strings.ToLower(strings.TrimSpace(text))); mode {
+	case "", OccurrenceAll:
+		return Occurrence{}, nil
+	case OccurrenceFirst, OccurrenceLast:
+		return Occurrence{Mode: mode}, nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || n < 1 {
+		return Occurrence{}, fmt.Errorf("invalid occurrence %q: use all, first, last or a position from 1", text)
+	}
+	return Occurrence{Mode: OccurrenceNth, N: n}, nil
+}
+
+// Validate checks the selector without looking at any document
+func (o Occurrence) Validate() error {
+	switch o.Mode {
+	case "", OccurrenceAll, OccurrenceFirst, OccurrenceLast:
+		return nil
+	case OccurrenceNth:
+		if o.N < 1 {
+			return fmt.Errorf("occurrence position must be 1 or more, got %d", o.N)
+		}
+		return nil
+	case OccurrencePaths:
+		if len(o.Paths) == 0 {
+			return fmt.Errorf("occurrence paths cannot be empty")
+		}
+		for _, p := range o.Paths {
+			path, err := jsonpath.Parse(p)
+			if err != nil {
+				return fmt.Errorf("invalid occurrence path '%s': %v", p, err)
+			}
+			if !path.IsConcrete() {
+				return fmt.Errorf("occurrence path '%s' must be a full path without wildcards", p)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid occurrence mode %q: use all, first, last, nth or paths", o.Mode)
+}
+
+// String describes the selector, e.g. "all" or "nth 2"
+func (o Occurrence) String() string {
+	switch o.Mode {
+	case "":
+		return string(OccurrenceAll)
+	case OccurrenceNth:
+		return fmt.Sprintf("nth %d", o.N)
+	case OccurrencePaths:
+		return "paths " + strings.Join(o.Paths, ", ")
+	}
+	return string(o.Mode)
+}
+
+// pick returns the indices of the selected candidates, given by their concrete
+// paths in document order. Selecting a position or path that does not exist is an
+// error so that a file is never changed at some other location instead.
+func (o Occurrence) pick(candidates []jsonpath.Path) ([]int, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch o.Mode {
+	case OccurrenceFirst:
+		return []int{0}, nil
+	case OccurrenceLast:
+		return []int{len(candidates) - 1}, nil
+	case OccurrenceNth:
+		if o.N > len(candidates) {
+			return nil, fmt.Errorf("occurrence %d requested but only %d found", o.N, len(candidates))
+		}
+		return []int{o.N - 1}, nil
+	case OccurrencePaths:
+		var picked []int
+		for _, p := range o.Paths {
+			want := jsonpath.MustParse(p).String()
+			found := false
+			for i, candidate := range candidates {
+				if candidate.String() == want {
+					picked = append(picked, i)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("path '%s' is not one of the %d candidate locations", p, len(candidates))
+			}
+		}
+		return picked, nil
+	}
+
+	all := make([]int, len(candidates))
+	for i := range all {
+		all[i] = i
+	}
+	return all, nil
+}
+
+// Candidate is a location an operation could apply to
+type Candidate struct {
+	Path     string `json:"path"`
+	Record   int    `json:"record,omitempty"` // record of a JSON Lines file the location is in
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Kind     string `json:"kind"` // kind of the value at the location, e.g. object or array
+	Selected bool   `json:"selected"`
+}
+
+// FileCandidates lists the candidate locations of an operation in a file
+type FileCandidates struct {
+	FilePath   string      `json:"filePath"`
+	Candidates []Candidate `json:"candidates"`
+	Ambiguous  bool        `json:"ambiguous"` // more than one candidate, in one record for JSON Lines files
+	Error      string      `json:"error,omitempty"`
+}
+
+// ObjectPathCandidates lists the objects and arrays the object path selects in the
+// JSON string, i.e. where InsertJSONKeyValue would add the key, marking the ones
+// the occurrence selects
+func ObjectPathCandidates(jsonStr, objectPath string, occurrence Occurrence) ([]Candidate, error) {
+	path, err := jsonpath.Parse(objectPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object path: %v", err)
+	}
+	doc, err := parseDocument(jsonStr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	targets := contextTargets(doc, path)
+	paths := make([]jsonpath.Path, len(targets))
+	nodes := make([]*jsoncst.Node, len(targets))
+	for i, target := range targets {
+		paths[i], nodes[i] = target.Path, target.Node
+	}
+	return candidates(doc, paths, nodes, occurrence)
+}
+
+// TargetKeyCandidates lists every member with the target key in the JSON string,
+// i.e. where InsertItemAfter would insert, marking the ones the occurrence selects
+func TargetKeyCandidates(jsonStr, targetKey string, occurrence Occurrence) ([]Candidate, error) {
+	doc, err := parseDocument(jsonStr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	targets := findMembers(doc, targetKey)
+	paths := make([]jsonpath.Path, len(targets))
+	nodes := make([]*jsoncst.Node, len(targets))
+	for i, target := range targets {
+		paths[i], nodes[i] = target.path, target.object.Members[target.index].Value
+	}
+	return candidates(doc, paths, nodes, occurrence)
+}
+
+// candidates describes the locations and marks the selected ones
+func candidates(doc *jsoncst.Document, paths []jsonpath.Path, nodes []*jsoncst.Node, occurrence Occurrence) ([]Candidate, error) {
+	list := make([]Candidate, len(paths))
+	for i, path := range paths {
+		list[i] = Candidate{Path: displayPath(path), Kind: nodes[i].Kind.String()}
+		list[i].Line, list[i].Column = doc.Position(nodes[i].Start)
+	}
+	if len(list) == 0 {
+		return list, nil
+	}
+
+	picked, err := occurrence.pick(paths)
+	if err != nil {
+		return list, err
+	}
+	for _, i := range picked {
+		list[i].Selected = true
+	}
+	return list, nil
+}
+
+// FindCandidatesInFiles runs a candidate analysis on every file, e.g. with
+// ObjectPathCandidates, so ambiguous targets can be reviewed before an operation
+func FindCandidatesInFiles(filePaths []string, analyze func(jsonStr string) ([]Candidate, error)) []FileCandidates {
+	results := make([]FileCandidates, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		result := FileCandidates{FilePath: filePath, Candidates: []Candidate{}}
+
+		content, err := fileops.ReadFile(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			results = append(results, result)
+			continue
+		}
+
+		// The records of a JSON Lines file are analyzed, and selected, one by one. As
+		// with editFile, their errors only count when no record has a candidate.
+		perRecord := make(map[int]int)
+		var firstErr string
+		err = eachRecord(filePath, string(content), func(record int, text string, locate func(path string, line, column int) (int, int)) {
+			list, err := analyze(text)
+			for _, candidate := range list {
+				candidate.Record = record
+				candidate.Line, candidate.Column = locate(candidate.Path, candidate.Line, candidate.Column)
+				result.Candidates = append(result.Candidates, candidate)
+				perRecord[record]++
+				result.Ambiguous = result.Ambiguous || perRecord[record] > 1
+			}
+			if err != nil && firstErr == "" {
+				firstErr = err.Error()
+				if record > 0 {
+					firstErr = fmt.Sprintf("record %d: %v", record, err)
+				}
+			}
+		})
+		if err != nil {
+			firstErr = err.Error()
+		}
+		if firstErr != "" && (!fileops.IsRecordFile(filePath) || len(result.Candidates) == 0) {
+			result.Error = firstErr
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// contextTargets returns the values selected by a context path in document order,
+// without the same node twice
+func contextTargets(doc *jsoncst.Document, path jsonpath.Path) []jsoncst.Match {
+	var targets []jsoncst.Match
+	seen := make(map[*jsoncst.Node]bool)
+	for _, target := range doc.Select(path) {
+		if !seen[target.Node] {
+			seen[target.Node] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// displayPath formats a concrete path, writing the root as "$"
+func displayPath(path jsonpath.Path) string {
+	if path.IsRoot() {
+		return "$"
+	}
+	return path.String()
+}
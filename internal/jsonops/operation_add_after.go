@@ -2,103 +2,128 @@ package jsonops
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"regexp"
-	"strings"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
 )
 
-// checkIfKeyExists checks if a key already exists in the JSON at the same level as the target key
-func checkIfKeyExists(lines []string, targetKey, newObjectKey string) bool {
-	// Find all occurrences of the target key
-	for i, line := range lines {
-		// Look for the target key (accounting for quotes and colon)
-		// Use regex to match the target key pattern more precisely
-		targetKeyPattern := `"` + regexp.QuoteMeta(targetKey) + `"\s*:`
-		targetKeyRegex := regexp.MustCompile(targetKeyPattern)
-
-		if targetKeyRegex.MatchString(line) {
-			// Get the indentation level of the target key
-			targetIndentation := getIndentation(line)
-
-			// Find the object that contains this target key
-			objectStartLine := findContainingObjectStart(lines, i)
-			if objectStartLine == -1 {
-				continue
-			}
-
-			// Search within the same object for the new key
-			objectEndLine := findMatchingBracket(lines, objectStartLine, '{', '}')
-			if objectEndLine == -1 {
-				continue
-			}
-
-			// Check all lines within this object for the new key at the same indentation level
-			for j := objectStartLine + 1; j < objectEndLine; j++ {
-				objLine := lines[j]
-				objIndentation := getIndentation(objLine)
-
-				// Skip lines that are not at the same indentation level (nested objects/arrays)
-				if objIndentation != targetIndentation {
-					continue
-				}
-
-				// Use regex to check if this line contains the new key
-				newKeyPattern := `"` + regexp.QuoteMeta(newObjectKey) + `"\s*:`
-				newKeyRegex := regexp.MustCompile(newKeyPattern)
-				if newKeyRegex.MatchString(objLine) {
-					return true
-				}
-			}
-		}
-	}
-	return false
+// InsertAfterRequest represents a request to add an object after a target key in JSON files
+type InsertAfterRequest struct {
+	TargetKey     string           `json:"targetKey"`
+	NewObjectKey  string           `json:"newObjectKey"`
+	NewObjectJSON string           `json:"newObjectJSON"`
+	Occurrence    Occurrence       `json:"occurrence"` // which occurrences of the target key to insert after
+	SelectedFiles []string         `json:"selectedFiles"`
+	DryRun        bool             `json:"dryRun"`
+	Syntaxes      fileops.Syntaxes `json:"-"`
 }
 
-// findContainingObjectStart finds the line index of the opening brace of the object containing the target line
-func findContainingObjectStart(lines []string, targetLineIndex int) int {
-	braceDepth := 0
+// InsertAfterInFiles adds an object after the selected occurrences of the target key
+// in each file, all of them by default. Files that already contain the new key next
+// to a target are skipped.
+func InsertAfterInFiles(request InsertAfterRequest) ([]AddItemResult, error) {
+	if request.TargetKey == "" || request.NewObjectKey == "" {
+		return nil, fmt.Errorf("target key and new object key cannot be empty")
+	}
+	if err := request.Occurrence.Validate(); err != nil {
+		return nil, err
+	}
+
+	var results []AddItemResult
 
-	// Go backwards from the target line to find the opening brace of the containing object
-	for i := targetLineIndex - 1; i >= 0; i-- {
-		line := strings.TrimSpace(lines[i])
+	for _, filePath := range request.SelectedFiles {
+		result := AddItemResult{
+			FilePath: filePath,
+			Success:  false,
+		}
+
+		// Read the file content
+		content, err := fileops.ReadFile(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			results = append(results, result)
+			continue
+		}
 
-		// Count braces
-		openBraces := strings.Count(line, "{")
-		closeBraces := strings.Count(line, "}")
-		braceDepth += openBraces - closeBraces
+		// Insert the new object after the target
+		modifiedContent, recordErrors, err := editFile(filePath, string(content), func(record string) (string, error) {
+			return InsertItemAfterAt(record, request.TargetKey, request.NewObjectKey, request.NewObjectJSON, request.Occurrence)
+		})
+		result.RecordErrors = recordErrors
+		if err != nil {
+			result.Skipped = errors.Is(err, ErrKeyExists)
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
 
-		// When we find an opening brace and our depth becomes positive,
-		// we've found the start of the containing object
-		if openBraces > 0 && braceDepth > 0 {
-			return i
+		// Write the modified content back to the file unless this is a dry run
+		change, err := writeChange(filePath, content, modifiedContent, request.DryRun, request.Syntaxes)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
 		}
+
+		result.Success = true
+		result.ModifiedContent = modifiedContent
+		result.Change = change
+		results = append(results, result)
 	}
 
-	// If no containing object found, return -1
-	return -1
+	return results, nil
+}
+
+// checkIfKeyExists checks if a key already exists in any object that contains the target key
+func checkIfKeyExists(targets []memberRef, newObjectKey string) bool {
+	for _, target := range targets {
+		if target.object.Member(newObjectKey) != nil {
+			return true
+		}
+	}
+	return false
 }
 
 // InsertItemAfter adds a JSON object after all occurrences of a target key in the JSON string
 // It checks if the object already exists and skips adding duplicates
 func InsertItemAfter(jsonStr, targetKey, newObjectKey, newObjectJSON string) (string, error) {
-	lines := strings.Split(jsonStr, "\n")
+	return InsertItemAfterAt(jsonStr, targetKey, newObjectKey, newObjectJSON, Occurrence{})
+}
 
-	// Check if the new object key already exists
-	if checkIfKeyExists(lines, targetKey, newObjectKey) {
-		return "", fmt.Errorf("object with key '%s' already exists", newObjectKey)
+// InsertItemAfterAt is InsertItemAfter limited to the occurrences of the target key
+// the occurrence selects, e.g. only the last one. TargetKeyCandidates lists the choices.
+func InsertItemAfterAt(jsonStr, targetKey, newObjectKey, newObjectJSON string, occurrence Occurrence) (string, error) {
+	doc, err := parseDocument(jsonStr)
+	if err != nil {
+		return "", fmt.Errorf("error parsing JSON: %v", err)
 	}
 
 	// Find all occurrences of the target key
-	var targetLineIndices []int
-	for i, line := range lines {
-		// Look for the target key (accounting for quotes and colon)
-		if strings.Contains(line, `"`+targetKey+`"`) && strings.Contains(line, ":") {
-			targetLineIndices = append(targetLineIndices, i)
-		}
+	candidates := findMembers(doc, targetKey)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("target key '%s' not found", targetKey)
 	}
 
-	if len(targetLineIndices) == 0 {
-		return "", fmt.Errorf("target key '%s' not found", targetKey)
+	// Keep the selected ones
+	paths := make([]jsonpath.Path, len(candidates))
+	for i, candidate := range candidates {
+		paths[i] = candidate.path
+	}
+	picked, err := occurrence.pick(paths)
+	if err != nil {
+		return "", err
+	}
+	targets := make([]memberRef, len(picked))
+	for i, index := range picked {
+		targets[i] = candidates[index]
+	}
+
+	// Check if the new object key already exists
+	if checkIfKeyExists(targets, newObjectKey) {
+		return "", fmt.Errorf("object with key '%s' %w", newObjectKey, ErrKeyExists)
 	}
 
 	// Validate the new object JSON once
@@ -107,72 +132,17 @@ func InsertItemAfter(jsonStr, targetKey, newObjectKey, newObjectJSON string) (st
 		return "", fmt.Errorf("invalid JSON for new object: %v", err)
 	}
 
-	// Convert to properly indented JSON template
-	formattedJSON, err := json.MarshalIndent(newObj, "", "  ")
+	// Convert to a template indented with the document's own indentation step
+	formattedJSON, err := jsoncst.Marshal(newObj, doc.IndentUnit())
 	if err != nil {
 		return "", fmt.Errorf("error formatting new object: %v", err)
 	}
 
-	// Process each target occurrence from last to first to avoid index shifting
-	result := lines
-	for i := len(targetLineIndices) - 1; i >= 0; i-- {
-		targetLineIndex := targetLineIndices[i]
-
-		// Find the end of this target object/value
-		insertIndex := findEndOfValue(result, targetLineIndex)
-		if insertIndex == -1 {
-			continue // Skip this occurrence if we can't find the end
-		}
-
-		// Detect indentation from the target line
-		targetLine := result[targetLineIndex]
-		indentation := ""
-		for _, char := range targetLine {
-			if char == ' ' || char == '\t' {
-				indentation += string(char)
-			} else {
-				break
-			}
-		}
-
-		// Split the formatted JSON into lines and apply base indentation
-		newObjLines := strings.Split(string(formattedJSON), "\n")
-		for j, line := range newObjLines {
-			if j == 0 {
-				// First line gets the key and value
-				newObjLines[j] = indentation + `"` + newObjectKey + `": ` + line
-			} else {
-				// Subsequent lines get additional indentation
-				newObjLines[j] = indentation + "  " + line
-			}
-		}
-
-		// Add comma to the target line if it doesn't have one and we're not at the end
-		if insertIndex < len(result)-1 && !strings.HasSuffix(strings.TrimSpace(result[insertIndex]), ",") {
-			result[insertIndex] = strings.TrimRight(result[insertIndex], " \t") + ","
-		}
-
-		// Add comma to our new object if needed (not the last item)
-		lastNewLine := newObjLines[len(newObjLines)-1]
-		if insertIndex < len(result)-1 && !strings.HasSuffix(strings.TrimSpace(lastNewLine), ",") {
-			// Check if the next non-empty line after insert position contains closing brace
-			nextNonEmptyIndex := insertIndex + 1
-			for nextNonEmptyIndex < len(result) && strings.TrimSpace(result[nextNonEmptyIndex]) == "" {
-				nextNonEmptyIndex++
-			}
-
-			if nextNonEmptyIndex < len(result) && !strings.Contains(strings.TrimSpace(result[nextNonEmptyIndex]), "}") {
-				newObjLines[len(newObjLines)-1] = lastNewLine + ","
-			}
-		}
-
-		// Insert the new object after the target
-		newResult := make([]string, 0, len(result)+len(newObjLines))
-		newResult = append(newResult, result[:insertIndex+1]...)
-		newResult = append(newResult, newObjLines...)
-		newResult = append(newResult, result[insertIndex+1:]...)
-		result = newResult
+	// Insert the new member right after each target member
+	edits := make([]jsoncst.Edit, 0, len(targets))
+	for _, target := range targets {
+		edits = append(edits, doc.InsertMember(target.object, target.index+1, newObjectKey, formattedJSON))
 	}
 
-	return strings.Join(result, "\n"), nil
+	return jsoncst.Apply(doc.Src, edits)
 }
@@ -1,16 +1,103 @@
 package jsonops
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
 )
 
+// AddItemRequest represents a request to add a key-value pair to JSON files
+type AddItemRequest struct {
+	ObjectPath    string           `json:"objectPath"`
+	Key           string           `json:"key"`
+	Value         any              `json:"value"`
+	Occurrence    Occurrence       `json:"occurrence"` // which objects to add to when the path selects several
+	SelectedFiles []string         `json:"selectedFiles"`
+	DryRun        bool             `json:"dryRun"`
+	Syntaxes      fileops.Syntaxes `json:"-"`
+}
+
+// AddItemResult represents the result of adding an item to a file
+type AddItemResult struct {
+	FilePath        string        `json:"filePath"`
+	Success         bool          `json:"success"`
+	Skipped         bool          `json:"skipped,omitempty"`
+	Error           string        `json:"error,omitempty"`
+	Warning         string        `json:"warning,omitempty"`
+	RecordErrors    []RecordError `json:"recordErrors,omitempty"` // records of a JSON Lines file the operation failed on
+	ModifiedContent string        `json:"modifiedContent"`
+	Change          *FileChange   `json:"change,omitempty"`
+}
+
+// ErrKeyExists is returned when the key to add is already present in a target
+// object; the file is then skipped rather than failed
+var ErrKeyExists = errors.New("already exists")
+
+// AddItemInFiles adds a key-value pair to the objects selected by a path in each file.
+// Files that already contain the key are skipped.
+func AddItemInFiles(request AddItemRequest) ([]AddItemResult, error) {
+	if request.Key == "" {
+		return nil, fmt.Errorf("key cannot be empty")
+	}
+	if err := request.Occurrence.Validate(); err != nil {
+		return nil, err
+	}
+
+	var results []AddItemResult
+
+	for _, filePath := range request.SelectedFiles {
+		result := AddItemResult{
+			FilePath: filePath,
+			Success:  false,
+		}
+
+		// Read the file content
+		content, err := fileops.ReadFile(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			results = append(results, result)
+			continue
+		}
+
+		// Insert the JSON key-value pair while preserving structure
+		modifiedContent, recordErrors, err := editFile(filePath, string(content), func(record string) (string, error) {
+			return InsertJSONKeyValueAt(record, request.ObjectPath, request.Key, request.Value, request.Occurrence)
+		})
+		result.RecordErrors = recordErrors
+		if err != nil {
+			result.Skipped = errors.Is(err, ErrKeyExists)
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		// Write the modified content back to the file unless this is a dry run
+		change, err := writeChange(filePath, content, modifiedContent, request.DryRun, request.Syntaxes)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Success = true
+		result.ModifiedContent = modifiedContent
+		result.Change = change
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
 // InsertJSONKeyValue inserts a key-value pair into JSON string while preserving structure.
 //
 // Parameters:
 //   - jsonStr: The JSON string to modify
-//   - objectPath: The path to the target object (empty string for root level)
+//   - objectPath: A path expression selecting the target objects (empty string for root level).
+//     Every object or array matched by the expression is updated, e.g. "user.profile.address",
+//     "items[0]", "items[*].meta" or "..address" for an address object at any depth.
 //   - key: The key name to insert
 //   - value: The value to associate with the key
 //
@@ -23,212 +110,140 @@ import (
 //	result, err := InsertJSONKeyValue(`{"name": "test"}`, "", "id", 123)
 //	// Result: `{"id": 123, "name": "test"}`
 func InsertJSONKeyValue(jsonStr, objectPath, key string, value any) (string, error) {
+	return InsertJSONKeyValueAt(jsonStr, objectPath, key, value, Occurrence{})
+}
+
+// InsertJSONKeyValueAt is InsertJSONKeyValue limited to the objects the occurrence
+// selects when the object path matches several, e.g. only the first or the one at
+// "response.items[2]". ObjectPathCandidates lists the choices.
+func InsertJSONKeyValueAt(jsonStr, objectPath, key string, value any, occurrence Occurrence) (string, error) {
 	// Convert value to JSON string
-	valueJSON, err := json.Marshal(value)
+	valueJSON, err := jsoncst.Marshal(value, "")
 	if err != nil {
 		return "", fmt.Errorf("error marshaling value: %v", err)
 	}
 
+	path, err := jsonpath.Parse(objectPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid object path: %v", err)
+	}
+
+	doc, err := parseDocument(jsonStr)
+	if err != nil {
+		return "", fmt.Errorf("error parsing JSON: %v", err)
+	}
+
 	// Choose insertion method based on object path
-	if objectPath == "" {
-		return insertAtRoot(jsonStr, key, string(valueJSON))
+	if path.IsRoot() {
+		if _, err := occurrence.pick([]jsonpath.Path{path}); err != nil {
+			return "", err
+		}
+		return insertAtRoot(doc, key, valueJSON)
 	} else {
-		return insertAtContextPath(jsonStr, objectPath, key, string(valueJSON))
+		return insertAtContextPath(doc, path, key, valueJSON, occurrence)
 	}
 }
 
 // insertAtRoot inserts a key-value pair at the root level of the JSON object.
 // This function handles duplicate key checking and proper indentation.
-func insertAtRoot(jsonStr, key, valueJSON string) (string, error) {
-	lines := strings.Split(jsonStr, "\n")
-
-	// Check if key already exists at root level
-	if keyExistsInObject(lines, key, 0, 1) {
-		return jsonStr, fmt.Errorf("key '%s' already exists at root level", key)
+func insertAtRoot(doc *jsoncst.Document, key, valueJSON string) (string, error) {
+	if doc.Root.Kind != jsoncst.Object {
+		return doc.Src, fmt.Errorf("root value is not an object")
 	}
 
-	// Find the first line with opening brace
-	openBraceIndex := -1
-	for i, line := range lines {
-		if strings.Contains(strings.TrimSpace(line), "{") {
-			openBraceIndex = i
-			break
-		}
-	}
-
-	if openBraceIndex == -1 {
-		return jsonStr, fmt.Errorf("no opening brace found")
+	// Check if key already exists at root level
+	if doc.Root.Member(key) != nil {
+		return doc.Src, fmt.Errorf("key '%s' %w at root level", key, ErrKeyExists)
 	}
 
-	// Get proper indentation for root level properties
-	indent := detectIndentationForObject(lines, openBraceIndex)
-
-	// Create the new property line
-	newPropertyLine := indent + `"` + key + `": ` + valueJSON + ","
-
-	// Insert right after the opening brace
-	result := make([]string, 0, len(lines)+1)
-	result = append(result, lines[:openBraceIndex+1]...)
-	result = append(result, newPropertyLine)
-	result = append(result, lines[openBraceIndex+1:]...)
-
-	return strings.Join(result, "\n"), nil
+	return jsoncst.Apply(doc.Src, insertIntoObject(doc, doc.Root, key, valueJSON))
 }
 
-// insertAtContextPath inserts a key-value pair at a specific context path.
-// This function determines whether the target is an object or array and delegates accordingly.
-func insertAtContextPath(jsonStr, objectPath, key, valueJSON string) (string, error) {
-	lines := strings.Split(jsonStr, "\n")
-
-	// Find the target path
-	targetLineIndex := -1
-	for i, line := range lines {
-		if strings.Contains(line, `"`+objectPath+`"`) && strings.Contains(line, ":") {
-			targetLineIndex = i
-			break
+// insertAtContextPath inserts a key-value pair into the values selected by the path
+// and the occurrence. All targets are validated before any of them is modified, so
+// a document is either updated everywhere or not at all.
+func insertAtContextPath(doc *jsoncst.Document, path jsonpath.Path, key, valueJSON string, occurrence Occurrence) (string, error) {
+	candidates := contextTargets(doc, path)
+	if len(candidates) == 0 {
+		// A bare key used to match the key anywhere in the file; it now selects the
+		// member of the root only, so point at the recursive form when that matches
+		if len(path) == 1 && path[0].Kind == jsonpath.KeySegment {
+			descent := jsonpath.Path{{Kind: jsonpath.DescendSegment}, path[0]}
+			if len(contextTargets(doc, descent)) > 0 {
+				return doc.Src, fmt.Errorf("path '%s' not found at the root; use '%s' to match it at any depth", path, descent)
+			}
 		}
+		return doc.Src, fmt.Errorf("path '%s' not found", path)
 	}
 
-	if targetLineIndex == -1 {
-		return jsonStr, fmt.Errorf("path '%s' not found", objectPath)
+	paths := make([]jsonpath.Path, len(candidates))
+	for i, candidate := range candidates {
+		paths[i] = candidate.Path
 	}
-
-	// Determine if the target is an array or object
-	targetLine := strings.TrimSpace(lines[targetLineIndex])
-	colonIndex := strings.Index(targetLine, ":")
-	if colonIndex == -1 {
-		return jsonStr, fmt.Errorf("invalid target line format")
+	picked, err := occurrence.pick(paths)
+	if err != nil {
+		return doc.Src, err
 	}
-
-	valueStart := strings.TrimSpace(targetLine[colonIndex+1:])
-
-	if strings.HasPrefix(valueStart, "[") {
-		// Handle array
-		if isArrayOfObjects(lines, targetLineIndex) {
-			return insertIntoArrayObjects(lines, targetLineIndex, key, valueJSON)
-		} else {
-			return insertIntoArrayValues(lines, targetLineIndex, valueJSON)
-		}
-	} else if strings.HasPrefix(valueStart, "{") {
-		// Handle object - check for duplicate key first
-		if keyExistsInObject(lines, key, targetLineIndex, 2) {
-			return jsonStr, fmt.Errorf("key '%s' already exists in target object", key)
-		}
-		return insertIntoObject(lines, targetLineIndex, key, valueJSON)
+	targets := make([]jsoncst.Match, len(picked))
+	for i, index := range picked {
+		targets[i] = candidates[index]
 	}
 
-	return jsonStr, fmt.Errorf("target path '%s' is not an object or array", objectPath)
-}
-
-// insertIntoObject inserts a key-value pair into an object
-func insertIntoObject(lines []string, objectLineIndex int, key, valueJSON string) (string, error) {
-	// Find the opening brace for this object
-	objectLine := lines[objectLineIndex]
-	if !strings.Contains(objectLine, "{") {
-		// Object starts on next line, find it
-		for i := objectLineIndex + 1; i < len(lines); i++ {
-			if strings.Contains(strings.TrimSpace(lines[i]), "{") {
-				objectLineIndex = i
-				break
+	for _, target := range targets {
+		switch target.Node.Kind {
+		case jsoncst.Object:
+			if target.Node.Member(key) != nil {
+				return doc.Src, fmt.Errorf("key '%s' %w in object '%s'", key, ErrKeyExists, target.Path)
+			}
+		case jsoncst.Array:
+			if keyExistsInArrayObjects(target.Node, key) {
+				return doc.Src, fmt.Errorf("key '%s' %w in one or more objects within array '%s'", key, ErrKeyExists, target.Path)
 			}
+		default:
+			return doc.Src, fmt.Errorf("target path '%s' is not an object or array", target.Path)
 		}
 	}
 
-	// Get proper indentation
-	indent := detectIndentationForObject(lines, objectLineIndex)
-
-	// Create the new property line
-	newPropertyLine := indent + `"` + key + `": ` + valueJSON + ","
-
-	// Insert right after the opening brace
-	result := make([]string, 0, len(lines)+1)
-	result = append(result, lines[:objectLineIndex+1]...)
-	result = append(result, newPropertyLine)
-	result = append(result, lines[objectLineIndex+1:]...)
-
-	return strings.Join(result, "\n"), nil
-}
-
-// insertIntoArrayObjects inserts a key-value pair at the beginning of each array element
-func insertIntoArrayObjects(lines []string, arrayLineIndex int, key, valueJSON string) (string, error) {
-	// Check if key already exists in any array object
-	if keyExistsInArrayObjects(lines, key, arrayLineIndex) {
-		return strings.Join(lines, "\n"), fmt.Errorf("key '%s' already exists in one or more array objects", key)
-	}
-
-	result := make([]string, 0, len(lines))
-	bracketDepth := 0
-	braceDepth := 0
-	inTargetArray := false
-	i := 0
-
-	// Copy lines until we reach the target array
-	for i < len(lines) {
-		line := lines[i]
-		result = append(result, line)
-
-		if i == arrayLineIndex {
-			inTargetArray = true
+	var edits []jsoncst.Edit
+	seen := make(map[*jsoncst.Node]bool)
+	for _, target := range targets {
+		node := target.Node
+		if seen[node] {
+			continue
 		}
+		seen[node] = true
 
-		if inTargetArray {
-			trimmed := strings.TrimSpace(line)
-			openBrackets := strings.Count(trimmed, "[")
-			closeBrackets := strings.Count(trimmed, "]")
-			openBraces := strings.Count(trimmed, "{")
-			closeBraces := strings.Count(trimmed, "}")
-
-			bracketDepth += openBrackets - closeBrackets
-			braceDepth += openBraces - closeBraces
-
-			// If we just opened an object inside the array, insert our property
-			if bracketDepth > 0 && openBraces > 0 && strings.Contains(trimmed, "{") {
-				indent := detectIndentationForObject(lines, i)
-				newPropertyLine := indent + `"` + key + `": ` + valueJSON + ","
-				result = append(result, newPropertyLine)
-			}
-
-			// If we've closed the target array, we're done
-			if bracketDepth == 0 && i > arrayLineIndex {
-				break
-			}
+		if node.Kind == jsoncst.Object {
+			edits = append(edits, insertIntoObject(doc, node, key, valueJSON)...)
+		} else if isArrayOfObjects(node) {
+			edits = append(edits, insertIntoArrayObjects(doc, node, key, valueJSON, seen)...)
+		} else {
+			edits = append(edits, insertIntoArrayValues(doc, node, valueJSON)...)
 		}
-
-		i++
 	}
 
-	// Append remaining lines
-	result = append(result, lines[i+1:]...)
+	return jsoncst.Apply(doc.Src, edits)
+}
 
-	return strings.Join(result, "\n"), nil
+// insertIntoObject inserts a key-value pair as the first member of an object
+func insertIntoObject(doc *jsoncst.Document, obj *jsoncst.Node, key, valueJSON string) []jsoncst.Edit {
+	return []jsoncst.Edit{doc.InsertMember(obj, 0, key, valueJSON)}
 }
 
-// insertIntoArrayValues inserts a value at the beginning of an array
-func insertIntoArrayValues(lines []string, arrayLineIndex int, valueJSON string) (string, error) {
-	// Find the opening bracket
-	bracketLineIndex := arrayLineIndex
-	arrayLine := lines[arrayLineIndex]
-	if !strings.Contains(arrayLine, "[") {
-		// Array starts on next line, find it
-		for i := arrayLineIndex + 1; i < len(lines); i++ {
-			if strings.Contains(strings.TrimSpace(lines[i]), "[") {
-				bracketLineIndex = i
-				break
-			}
+// insertIntoArrayObjects inserts a key-value pair at the beginning of each object element
+func insertIntoArrayObjects(doc *jsoncst.Document, array *jsoncst.Node, key, valueJSON string, seen map[*jsoncst.Node]bool) []jsoncst.Edit {
+	var edits []jsoncst.Edit
+	for _, elem := range array.Elems {
+		if elem.Kind != jsoncst.Object || seen[elem] {
+			continue
 		}
+		seen[elem] = true
+		edits = append(edits, doc.InsertMember(elem, 0, key, valueJSON))
 	}
+	return edits
+}
 
-	// Get proper indentation
-	indent := detectIndentationForArray(lines, bracketLineIndex)
-
-	// Create the new value line
-	newValueLine := indent + valueJSON + ","
-
-	// Insert right after the opening bracket
-	result := make([]string, 0, len(lines)+1)
-	result = append(result, lines[:bracketLineIndex+1]...)
-	result = append(result, newValueLine)
-	result = append(result, lines[bracketLineIndex+1:]...)
-
-	return strings.Join(result, "\n"), nil
+// insertIntoArrayValues inserts a value at the beginning of an array
+func insertIntoArrayValues(doc *jsoncst.Document, array *jsoncst.Node, valueJSON string) []jsoncst.Edit {
+	return []jsoncst.Edit{doc.InsertElem(array, 0, valueJSON)}
 }
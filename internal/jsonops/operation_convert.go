@@ -0,0 +1,345 @@
+package jsonops
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
+)
+
+// KeyCase is a key naming convention
+type KeyCase string
+
+const (
+	CamelCase  KeyCase = "camel"  // userId
+	SnakeCase  KeyCase = "snake"  // user_id
+	KebabCase  KeyCase = "kebab"  // user-id
+	PascalCase KeyCase = "pascal" // UserId
+)
+
+// convertibleKey matches the keys a naming convention applies to. Keys such as
+// "$ref", "_id" or "first name" are left alone.
+var convertibleKey = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// KeyCaseOptions selects the naming convention and the keys to convert
+type KeyCaseOptions struct {
+	Case  KeyCase  `json:"case"`
+	Path  string   `json:"path,omitempty"`  // only convert keys inside the values at this path, empty for the whole file
+	Allow []string `json:"allow,omitempty"` // only convert these keys, by their current name
+	Deny  []string `json:"deny,omitempty"`  // never convert these keys
+}
+
+// Validate checks the options without looking at any document
+func (o KeyCaseOptions) Validate() error {
+	switch o.Case {
+	case CamelCase, SnakeCase, KebabCase, PascalCase:
+	default:
+		return fmt.Errorf("invalid key case %q: use camel, snake, kebab or pascal", o.Case)
+	}
+	if _, err := jsonpath.Parse(o.Path); err != nil {
+		return fmt.Errorf("invalid path: %v", err)
+	}
+	return nil
+}
+
+// Convert returns the key in the naming convention, or the key itself when it is
+// not convertible or excluded by the allow and deny lists
+func (o KeyCaseOptions) Convert(key string) string {
+	if !convertibleKey.MatchString(key) {
+		return key
+	}
+	if len(o.Allow) > 0 && !containsString(o.Allow, key) {
+		return key
+	}
+	if containsString(o.Deny, key) {
+		return key
+	}
+	return convertCase(key, o.Case)
+}
+
+// ConvertKeysRequest represents a request to convert key naming conventions in JSON files
+type ConvertKeysRequest struct {
+	KeyCaseOptions
+	SelectedFiles []string         `json:"selectedFiles"`
+	DryRun        bool             `json:"dryRun"`
+	Syntaxes      fileops.Syntaxes `json:"-"`
+}
+
+// KeyRename counts how often a key was renamed to its new name
+type KeyRename struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// KeyCollision describes keys of one object that would end up with the same name
+type KeyCollision struct {
+	Path string   `json:"path"` // path of the object
+	Name string   `json:"name"` // the name they would share
+	Keys []string `json:"keys"` // their current names
+}
+
+// KeyCollisionError reports objects in which converting keys would create duplicate keys
+type KeyCollisionError struct {
+	Collisions []KeyCollision
+}
+
+func (e *KeyCollisionError) Error() string {
+	parts := make([]string, len(e.Collisions))
+	for i, c := range e.Collisions {
+		parts[i] = fmt.Sprintf("%s: %s -> %s", c.Path, strings.Join(c.Keys, ", "), c.Name)
+	}
+	return "converted keys would collide in " + strings.Join(parts, "; ")
+}
+
+// ConvertKeysResult represents the result of converting the keys of a file
+type ConvertKeysResult struct {
+	FilePath         string         `json:"filePath"`
+	Success          bool           `json:"success"`
+	Error            string         `json:"error,omitempty"`
+	Warning          string         `json:"warning,omitempty"`
+	RecordErrors     []RecordError  `json:"recordErrors,omitempty"` // records of a JSON Lines file the operation failed on
+	Collisions       []KeyCollision `json:"collisions,omitempty"`
+	Renames          []KeyRename    `json:"renames,omitempty"`
+	ReplacementCount int            `json:"replacementCount"`
+	ModifiedContent  string         `json:"modifiedContent"`
+	Change           *FileChange    `json:"change,omitempty"`
+}
+
+// ConvertKeysInFiles converts the key names of the selected files to a naming
+// convention. Files where converted keys would collide are left unchanged.
+func ConvertKeysInFiles(request ConvertKeysRequest) ([]ConvertKeysResult, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	var results []ConvertKeysResult
+
+	for _, filePath := range request.SelectedFiles {
+		result := ConvertKeysResult{
+			FilePath: filePath,
+			Success:  false,
+		}
+
+		// Read the file content
+		content, err := fileops.ReadFile(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			results = append(results, result)
+			continue
+		}
+
+		var renames []KeyRename
+		modifiedContent, recordErrors, yamlChanges, err := editFileChanges(filePath, string(content), func(record string) (string, error) {
+			result, recordRenames, err := ConvertJSONKeys(record, request.KeyCaseOptions)
+			renames = mergeRenames(renames, recordRenames)
+			return result, err
+		})
+		result.RecordErrors = recordErrors
+		if yamlChanges != nil && err == nil {
+			// Count the keys of the YAML document, not their copies under aliases
+			renames = nil
+			for _, rename := range yamlChanges.Renames {
+				renames = mergeRenames(renames, []KeyRename{{From: rename.From, To: rename.To, Count: 1}})
+			}
+		}
+		var collision *KeyCollisionError
+		if errors.As(err, &collision) {
+			result.Error = err.Error()
+			result.Collisions = collision.Collisions
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if len(renames) == 0 {
+			result.Error = fmt.Sprintf("no keys to convert to %s case", request.Case)
+			results = append(results, result)
+			continue
+		}
+
+		// Write the modified content back to the file unless this is a dry run
+		change, err := writeChange(filePath, content, modifiedContent, request.DryRun, request.Syntaxes)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Success = true
+		result.Renames = renames
+		for _, rename := range renames {
+			result.ReplacementCount += rename.Count
+		}
+		result.ModifiedContent = modifiedContent
+		result.Change = change
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ConvertJSONKeys renames the keys of every object inside the values at the path to
+// the naming convention and returns the distinct renames. If two keys of an object
+// would end up with the same name, nothing is renamed and a *KeyCollisionError
+// lists every such object.
+func ConvertJSONKeys(content string, options KeyCaseOptions) (string, []KeyRename, error) {
+	if err := options.Validate(); err != nil {
+		return content, nil, err
+	}
+	doc, err := parseDocument(content)
+	if err != nil {
+		return content, nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	// Objects at or below a selected value are converted
+	var roots map[*jsoncst.Node]bool
+	if options.Path != "" {
+		path := jsonpath.MustParse(options.Path)
+		roots = make(map[*jsoncst.Node]bool)
+		for _, target := range doc.Select(path) {
+			roots[target.Node] = true
+		}
+		if len(roots) == 0 {
+			return content, nil, fmt.Errorf("path '%s' not found", options.Path)
+		}
+	}
+
+	var edits []jsoncst.Edit
+	var collisions []KeyCollision
+	counts := make(map[KeyRename]int)
+	doc.Walk(func(path jsonpath.Path, n *jsoncst.Node, _ *jsoncst.Member) bool {
+		if n.Kind != jsoncst.Object || (roots != nil && !hasMarkedAncestor(n, roots)) {
+			return true
+		}
+
+		// Group the members by their final name to find collisions
+		byName := make(map[string][]string)
+		var names []string
+		for _, m := range n.Members {
+			name := options.Convert(m.Key)
+			if _, ok := byName[name]; !ok {
+				names = append(names, name)
+			}
+			byName[name] = append(byName[name], m.Key)
+			if name != m.Key {
+				edits = append(edits, doc.RenameKey(m, name))
+				counts[KeyRename{From: m.Key, To: name}]++
+			}
+		}
+		for _, name := range names {
+			if keys := byName[name]; len(keys) > 1 {
+				collisions = append(collisions, KeyCollision{Path: displayPath(path), Name: name, Keys: keys})
+			}
+		}
+		return true
+	})
+
+	if len(collisions) > 0 {
+		return content, nil, &KeyCollisionError{Collisions: collisions}
+	}
+
+	result, err := jsoncst.Apply(content, edits)
+	if err != nil {
+		return content, nil, err
+	}
+
+	renames := make([]KeyRename, 0, len(counts))
+	for rename, count := range counts {
+		rename.Count = count
+		renames = append(renames, rename)
+	}
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].From < renames[j].From
+	})
+	return result, renames, nil
+}
+
+// mergeRenames adds the counts of more renames, such as those of another record, to
+// a list of renames
+func mergeRenames(renames, more []KeyRename) []KeyRename {
+	for _, rename := range more {
+		found := false
+		for i := range renames {
+			if renames[i].From == rename.From && renames[i].To == rename.To {
+				renames[i].Count += rename.Count
+				found = true
+			}
+		}
+		if !found {
+			renames = append(renames, rename)
+		}
+	}
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].From < renames[j].From
+	})
+	return renames
+}
+
+// convertCase writes the words of a key in a naming convention
+func convertCase(key string, keyCase KeyCase) string {
+	words := splitWords(key)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if keyCase == PascalCase || (keyCase == CamelCase && i > 0) {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		words[i] = word
+	}
+
+	switch keyCase {
+	case SnakeCase:
+		return strings.Join(words, "_")
+	case KebabCase:
+		return strings.Join(words, "-")
+	}
+	return strings.Join(words, "")
+}
+
+// splitWords splits a key at underscores, hyphens and case changes. A run of
+// capitals is one word, so "HTTPStatus" splits into "HTTP" and "Status".
+func splitWords(key string) []string {
+	var words []string
+	runes := []rune(key)
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			words = append(words, string(runes[start:end]))
+		}
+	}
+
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-':
+			flush(i)
+			start = i + 1
+		case unicode.IsUpper(r) && i > start:
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(runes[i-1]) || nextLower {
+				flush(i)
+				start = i
+			}
+		}
+	}
+	flush(len(runes))
+	return words
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package jsonops
+
+import (
+	"fmt"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
+)
+
+// DeleteKeyRequest represents a request to delete keys from JSON files
+type DeleteKeyRequest struct {
+	KeyPath       string           `json:"keyPath"`
+	SelectedFiles []string         `json:"selectedFiles"`
+	DryRun        bool             `json:"dryRun"`
+	Syntaxes      fileops.Syntaxes `json:"-"`
+}
+
+// DeleteKeyResult represents the result of a key deletion operation
+type DeleteKeyResult struct {
+	FilePath        string        `json:"filePath"`
+	Success         bool          `json:"success"`
+	Error           string        `json:"error,omitempty"`
+	Warning         string        `json:"warning,omitempty"`
+	RecordErrors    []RecordError `json:"recordErrors,omitempty"` // records of a JSON Lines file the operation failed on
+	DeletedCount    int           `json:"deletedCount"`
+	ModifiedContent string        `json:"modifiedContent"`
+	Change          *FileChange   `json:"change,omitempty"`
+}
+
+// DeleteKeysInFiles removes the members selected by a path expression from the selected files
+func DeleteKeysInFiles(request DeleteKeyRequest) ([]DeleteKeyResult, error) {
+	if request.KeyPath == "" {
+		return nil, fmt.Errorf("key path cannot be empty")
+	}
+
+	if _, err := parseDeletePath(request.KeyPath); err != nil {
+		return nil, err
+	}
+
+	var results []DeleteKeyResult
+
+	for _, filePath := range request.SelectedFiles {
+		result := DeleteKeyResult{
+			FilePath: filePath,
+			Success:  false,
+		}
+
+		// Read the file content
+		content, err := fileops.ReadFile(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			results = append(results, result)
+			continue
+		}
+
+		deletedCount := 0
+		modifiedContent, recordErrors, err := editFile(filePath, string(content), func(record string) (string, error) {
+			result, count, err := DeleteJSONKeys(record, request.KeyPath)
+			deletedCount += count
+			return result, err
+		})
+		result.RecordErrors = recordErrors
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if deletedCount == 0 {
+			result.Error = fmt.Sprintf("no keys found matching '%s'", request.KeyPath)
+			results = append(results, result)
+			continue
+		}
+
+		// Write the modified content back to the file unless this is a dry run
+		change, err := writeChange(filePath, content, modifiedContent, request.DryRun, request.Syntaxes)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Success = true
+		result.DeletedCount = deletedCount
+		result.ModifiedContent = modifiedContent
+		result.Change = change
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// DeleteJSONKeys removes every member matched by the path expression and returns
+// the number of members removed.
+//
+// The last segment of the path selects what is removed from the containers matched
+// by the rest of it: a key removes that member (from the object itself, or from every
+// object element when the container is an array), "*" removes all members or elements,
+// and an index removes a single array element.
+//
+// Example:
+//
+//	result, n, err := DeleteJSONKeys(`{"a": 1, "items": [{"b": 2, "c": 3}]}`, "items.c")
+//	// Result: `{"a": 1, "items": [{"b": 2}]}`, n == 1
+func DeleteJSONKeys(jsonStr, keyPath string) (string, int, error) {
+	path, err := parseDeletePath(keyPath)
+	if err != nil {
+		return jsonStr, 0, err
+	}
+
+	doc, err := parseDocument(jsonStr)
+	if err != nil {
+		return jsonStr, 0, fmt.Errorf("failed to parse JSON: %v", err)
+	}
+
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+
+	// Collect the doomed entries per container
+	removed := make(map[*jsoncst.Node]bool)
+	var containers []*jsoncst.Node
+	mark := func(container, value *jsoncst.Node) {
+		if len(containers) == 0 || containers[len(containers)-1] != container {
+			containers = append(containers, container)
+		}
+		removed[value] = true
+	}
+
+	for _, match := range doc.Select(parentPath) {
+		node := match.Node
+		switch {
+		case node.Kind == jsoncst.Object && last.Kind == jsonpath.KeySegment:
+			if m := node.Member(last.Key); m != nil {
+				mark(node, m.Value)
+			}
+		case node.Kind == jsoncst.Object && last.Kind == jsonpath.WildcardSegment:
+			for _, m := range node.Members {
+				mark(node, m.Value)
+			}
+		case node.Kind == jsoncst.Array && last.Kind == jsonpath.KeySegment:
+			for _, elem := range node.Elems {
+				if elem.Kind != jsoncst.Object {
+					continue
+				}
+				if m := elem.Member(last.Key); m != nil {
+					mark(elem, m.Value)
+				}
+			}
+		case node.Kind == jsoncst.Array && last.Kind == jsonpath.WildcardSegment:
+			for _, elem := range node.Elems {
+				mark(node, elem)
+			}
+		case node.Kind == jsoncst.Array && last.Kind == jsonpath.IndexSegment:
+			if last.Index < len(node.Elems) {
+				mark(node, node.Elems[last.Index])
+			}
+		}
+	}
+
+	// Build the edits, skipping entries that disappear with a removed ancestor
+	var edits []jsoncst.Edit
+	count := 0
+	seen := make(map[*jsoncst.Node]bool)
+	for _, container := range containers {
+		if seen[container] || hasMarkedAncestor(container, removed) {
+			continue
+		}
+		seen[container] = true
+
+		var indices []int
+		if container.Kind == jsoncst.Object {
+			for i, m := range container.Members {
+				if removed[m.Value] {
+					indices = append(indices, i)
+				}
+			}
+			edits = append(edits, doc.RemoveMembers(container, indices)...)
+		} else {
+			for i, elem := range container.Elems {
+				if removed[elem] {
+					indices = append(indices, i)
+				}
+			}
+			edits = append(edits, doc.RemoveElems(container, indices)...)
+		}
+		count += len(indices)
+	}
+
+	result, err := jsoncst.Apply(doc.Src, edits)
+	if err != nil {
+		return jsonStr, 0, err
+	}
+	return result, count, nil
+}
+
+// parseDeletePath parses a delete path and checks that it ends in a removable segment
+func parseDeletePath(keyPath string) (jsonpath.Path, error) {
+	path, err := jsonpath.Parse(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key path: %v", err)
+	}
+	if path.IsRoot() {
+		return nil, fmt.Errorf("key path must not refer to the root")
+	}
+	return path, nil
+}
@@ -0,0 +1,219 @@
+package jsonops
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+)
+
+// MergePatchRequest represents a request to merge a patch object into JSON files
+type MergePatchRequest struct {
+	Patch         string           `json:"patch"`
+	SelectedFiles []string         `json:"selectedFiles"`
+	DryRun        bool             `json:"dryRun"`
+	Syntaxes      fileops.Syntaxes `json:"-"`
+}
+
+// MergePatchResult represents the result of a merge-patch operation
+type MergePatchResult struct {
+	FilePath        string        `json:"filePath"`
+	Success         bool          `json:"success"`
+	Skipped         bool          `json:"skipped,omitempty"`
+	Error           string        `json:"error,omitempty"`
+	Warning         string        `json:"warning,omitempty"`
+	RecordErrors    []RecordError `json:"recordErrors,omitempty"` // records of a JSON Lines file the operation failed on
+	AddedCount      int           `json:"addedCount"`
+	UpdatedCount    int           `json:"updatedCount"`
+	RemovedCount    int           `json:"removedCount"`
+	ModifiedContent string        `json:"modifiedContent"`
+	Change          *FileChange   `json:"change,omitempty"`
+}
+
+// MergePatchInFiles deep-merges an RFC 7386 merge patch into each of the selected files
+func MergePatchInFiles(request MergePatchRequest) ([]MergePatchResult, error) {
+	if _, err := jsoncst.Parse(request.Patch); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %v", err)
+	}
+
+	var results []MergePatchResult
+
+	for _, filePath := range request.SelectedFiles {
+		result := MergePatchResult{
+			FilePath: filePath,
+			Success:  false,
+		}
+
+		// Read the file content
+		content, err := fileops.ReadFile(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			results = append(results, result)
+			continue
+		}
+
+		added, updated, removed := 0, 0, 0
+		modifiedContent, recordErrors, err := editFile(filePath, string(content), func(record string) (string, error) {
+			result, a, u, r, err := MergeJSONPatch(record, request.Patch)
+			added, updated, removed = added+a, updated+u, removed+r
+			return result, err
+		})
+		result.RecordErrors = recordErrors
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if added+updated+removed == 0 {
+			result.Skipped = true
+			result.Error = "file already matches the merge patch"
+			results = append(results, result)
+			continue
+		}
+
+		// Write the modified content back to the file unless this is a dry run
+		change, err := writeChange(filePath, content, modifiedContent, request.DryRun, request.Syntaxes)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Success = true
+		result.AddedCount = added
+		result.UpdatedCount = updated
+		result.RemovedCount = removed
+		result.ModifiedContent = modifiedContent
+		result.Change = change
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// MergeJSONPatch applies an RFC 7386 merge patch: object members are merged
+// recursively, null members delete their key and any other value replaces the
+// target. Only the members the patch touches are edited; new members are appended
+// to their object in the order they appear in the patch. It returns the number of
+// members added, updated and removed. Members that already hold the patched value
+// are left alone and not counted.
+//
+// Example:
+//
+//	result, added, updated, removed, err := MergeJSONPatch(`{"meta": {"legacy": true}}`, `{"meta": {"region": "eu", "legacy": null}}`)
+//	// Result: `{"meta": {"region": "eu"}}`, added == 1, updated == 0, removed == 1
+func MergeJSONPatch(jsonStr, patchJSON string) (string, int, int, int, error) {
+	patch, err := jsoncst.Parse(patchJSON)
+	if err != nil {
+		return jsonStr, 0, 0, 0, fmt.Errorf("invalid merge patch: %v", err)
+	}
+
+	doc, err := parseDocument(jsonStr)
+	if err != nil {
+		return jsonStr, 0, 0, 0, fmt.Errorf("failed to parse JSON: %v", err)
+	}
+
+	m := &merger{doc: doc, patch: patch}
+	if err := m.merge(nil, doc.Root, patch.Root); err != nil {
+		return jsonStr, 0, 0, 0, err
+	}
+	if len(m.ops) == 0 {
+		return jsonStr, 0, 0, 0, nil
+	}
+
+	result, err := PatchJSON(jsonStr, m.ops)
+	if err != nil {
+		return jsonStr, 0, 0, 0, err
+	}
+	return result, m.added, m.updated, m.removed, nil
+}
+
+// merger translates a merge patch into the equivalent JSON Patch operations
+type merger struct {
+	doc     *jsoncst.Document
+	patch   *jsoncst.Document
+	ops     []PatchOperation
+	added   int
+	updated int
+	removed int
+}
+
+// merge records the operations that merge patch into target, which lives at tokens
+func (m *merger) merge(tokens []string, target, patch *jsoncst.Node) error {
+	if patch.Kind != jsoncst.Object || target.Kind != jsoncst.Object {
+		return m.replace(tokens, target, patch)
+	}
+
+	for _, pm := range patch.Members {
+		memberTokens := append(append([]string{}, tokens...), pm.Key)
+		existing := target.Member(pm.Key)
+
+		switch {
+		case pm.Value.Kind == jsoncst.Null:
+			if existing != nil {
+				m.ops = append(m.ops, PatchOperation{Op: "remove", Path: jsoncst.FormatPointer(memberTokens)})
+				m.removed++
+			}
+		case existing != nil:
+			if err := m.merge(memberTokens, existing.Value, pm.Value); err != nil {
+				return err
+			}
+		default:
+			m.ops = append(m.ops, PatchOperation{
+				Op:    "add",
+				Path:  jsoncst.FormatPointer(memberTokens),
+				Value: json.RawMessage(m.stripNulls(pm.Value)),
+			})
+			m.added++
+		}
+	}
+	return nil
+}
+
+// replace records a replacement of target with the patch value, unless it already holds it
+func (m *merger) replace(tokens []string, target, patch *jsoncst.Node) error {
+	value := m.stripNulls(patch)
+
+	same, err := m.doc.Equal(target, []byte(value))
+	if err != nil {
+		return fmt.Errorf("invalid merge patch: %v", err)
+	}
+	if same {
+		return nil
+	}
+
+	m.ops = append(m.ops, PatchOperation{
+		Op:    "replace",
+		Path:  jsoncst.FormatPointer(tokens),
+		Value: json.RawMessage(value),
+	})
+	m.updated++
+	return nil
+}
+
+// stripNulls renders a patch value as compact JSON with its null members removed,
+// which is what merging it into a missing or non-object target produces
+func (m *merger) stripNulls(n *jsoncst.Node) string {
+	switch n.Kind {
+	case jsoncst.Object:
+		var parts []string
+		for _, pm := range n.Members {
+			if pm.Value.Kind == jsoncst.Null {
+				continue
+			}
+			parts = append(parts, jsoncst.Quote(pm.Key)+":"+m.stripNulls(pm.Value))
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	case jsoncst.Array:
+		parts := make([]string, len(n.Elems))
+		for i, elem := range n.Elems {
+			parts[i] = m.patch.Text(elem)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	default:
+		return m.patch.Text(n)
+	}
+}
@@ -0,0 +1,337 @@
+package jsonops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+)
+
+// PatchOperation is a single RFC 6902 JSON Patch operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchError reports which operation of a patch failed
+type PatchError struct {
+	Index int
+	Op    string
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("operation %d (%s) failed: %v", e.Index, e.Op, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// PatchRequest represents a request to apply a JSON Patch to JSON files
+type PatchRequest struct {
+	Patch         string           `json:"patch"`
+	SelectedFiles []string         `json:"selectedFiles"`
+	DryRun        bool             `json:"dryRun"`
+	Syntaxes      fileops.Syntaxes `json:"-"`
+}
+
+// PatchResult represents the result of applying a JSON Patch to a file
+type PatchResult struct {
+	FilePath        string        `json:"filePath"`
+	Success         bool          `json:"success"`
+	Error           string        `json:"error,omitempty"`
+	Warning         string        `json:"warning,omitempty"`
+	RecordErrors    []RecordError `json:"recordErrors,omitempty"` // records of a JSON Lines file the operation failed on
+	FailedOp        *int          `json:"failedOp,omitempty"`
+	AppliedOps      int           `json:"appliedOps"`
+	ModifiedContent string        `json:"modifiedContent"`
+	Change          *FileChange   `json:"change,omitempty"`
+}
+
+// ApplyJSONPatch applies a JSON Patch document to each of the selected files.
+// A file is only written when every operation of the patch succeeds.
+func ApplyJSONPatch(request PatchRequest) ([]PatchResult, error) {
+	ops, err := ParseJSONPatch(request.Patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []PatchResult
+
+	for _, filePath := range request.SelectedFiles {
+		result := PatchResult{
+			FilePath: filePath,
+			Success:  false,
+		}
+
+		// Read the file content
+		content, err := fileops.ReadFile(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			results = append(results, result)
+			continue
+		}
+
+		modifiedContent, recordErrors, err := editFile(filePath, string(content), func(record string) (string, error) {
+			return PatchJSON(record, ops)
+		})
+		result.RecordErrors = recordErrors
+		if err != nil {
+			var patchErr *PatchError
+			if errors.As(err, &patchErr) {
+				failedOp := patchErr.Index
+				result.FailedOp = &failedOp
+				result.AppliedOps = patchErr.Index
+			}
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		// Write the modified content back to the file unless this is a dry run
+		change, err := writeChange(filePath, content, modifiedContent, request.DryRun, request.Syntaxes)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Success = true
+		result.AppliedOps = len(ops)
+		result.ModifiedContent = modifiedContent
+		result.Change = change
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ParseJSONPatch decodes a JSON Patch document and checks that every operation
+// carries the members its type requires
+func ParseJSONPatch(patchJSON string) ([]PatchOperation, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(patchJSON), &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON Patch: %v", err)
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("JSON Patch contains no operations")
+	}
+
+	ops := make([]PatchOperation, len(raw))
+	for i, fields := range raw {
+		op := &ops[i]
+		for _, name := range []string{"op", "path", "from"} {
+			field, ok := fields[name]
+			if !ok {
+				continue
+			}
+			var text string
+			if err := json.Unmarshal(field, &text); err != nil {
+				return nil, fmt.Errorf("operation %d: member '%s' must be a string", i, name)
+			}
+			switch name {
+			case "op":
+				op.Op = text
+			case "path":
+				op.Path = text
+			case "from":
+				op.From = text
+			}
+		}
+		op.Value = fields["value"]
+
+		if _, ok := fields["path"]; !ok {
+			return nil, fmt.Errorf("operation %d: missing 'path'", i)
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d (%s): missing 'value'", i, op.Op)
+			}
+		case "move", "copy":
+			if _, ok := fields["from"]; !ok {
+				return nil, fmt.Errorf("operation %d (%s): missing 'from'", i, op.Op)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d: unknown op '%s'", i, op.Op)
+		}
+	}
+	return ops, nil
+}
+
+// PatchJSON applies JSON Patch operations in order. Each operation edits only the
+// spans it touches, so the formatting of the rest of the document is preserved.
+// The first failing operation aborts the patch with a *PatchError.
+//
+// Example:
+//
+//	ops, _ := ParseJSONPatch(`[{"op": "replace", "path": "/version", "value": "2.0"}]`)
+//	result, err := PatchJSON(`{"version": "1.0"}`, ops)
+//	// Result: `{"version": "2.0"}`
+func PatchJSON(jsonStr string, ops []PatchOperation) (string, error) {
+	if _, err := parseDocument(jsonStr); err != nil {
+		return jsonStr, fmt.Errorf("failed to parse JSON: %v", err)
+	}
+
+	current := jsonStr
+	for i, op := range ops {
+		next, err := applyPatchOperation(current, op)
+		if err != nil {
+			return jsonStr, &PatchError{Index: i, Op: op.Op, Err: err}
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// applyPatchOperation applies a single operation to the document text
+func applyPatchOperation(jsonStr string, op PatchOperation) (string, error) {
+	doc, err := parseDocument(jsonStr)
+	if err != nil {
+		return jsonStr, fmt.Errorf("failed to parse JSON: %v", err)
+	}
+
+	tokens, err := jsoncst.ParsePointer(op.Path)
+	if err != nil {
+		return jsonStr, err
+	}
+
+	switch op.Op {
+	case "add":
+		valueJSON, err := jsoncst.FormatRaw(op.Value, doc.IndentUnit())
+		if err != nil {
+			return jsonStr, fmt.Errorf("invalid value: %v", err)
+		}
+		return patchAdd(doc, tokens, valueJSON)
+
+	case "remove":
+		return patchRemove(doc, tokens)
+
+	case "replace":
+		target, err := doc.Resolve(tokens)
+		if err != nil {
+			return jsonStr, err
+		}
+		valueJSON, err := jsoncst.FormatRaw(op.Value, doc.IndentUnit())
+		if err != nil {
+			return jsonStr, fmt.Errorf("invalid value: %v", err)
+		}
+		return jsoncst.Apply(doc.Src, []jsoncst.Edit{doc.ReplaceValue(target, valueJSON)})
+
+	case "move", "copy":
+		fromTokens, err := jsoncst.ParsePointer(op.From)
+		if err != nil {
+			return jsonStr, err
+		}
+		source, err := doc.Resolve(fromTokens)
+		if err != nil {
+			return jsonStr, err
+		}
+		valueJSON := doc.Detach(source)
+		if op.Op == "copy" {
+			return patchAdd(doc, tokens, valueJSON)
+		}
+
+		if op.From == op.Path {
+			return jsonStr, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return jsonStr, fmt.Errorf("cannot move '%s' into one of its own children", op.From)
+		}
+		removed, err := patchRemove(doc, fromTokens)
+		if err != nil {
+			return jsonStr, err
+		}
+		doc, err = parseDocument(removed)
+		if err != nil {
+			return jsonStr, err
+		}
+		return patchAdd(doc, tokens, valueJSON)
+
+	case "test":
+		target, err := doc.Resolve(tokens)
+		if err != nil {
+			return jsonStr, err
+		}
+		same, err := doc.Equal(target, op.Value)
+		if err != nil {
+			return jsonStr, fmt.Errorf("invalid value: %v", err)
+		}
+		if !same {
+			return jsonStr, fmt.Errorf("test failed: value at '%s' is %s", op.Path, doc.Text(target))
+		}
+		return jsonStr, nil
+	}
+
+	return jsonStr, fmt.Errorf("unknown op '%s'", op.Op)
+}
+
+// patchAdd adds a value at the pointer. An existing object member is replaced,
+// array elements from the index on are shifted, and "-" appends to an array.
+func patchAdd(doc *jsoncst.Document, tokens []string, valueJSON string) (string, error) {
+	if len(tokens) == 0 {
+		return jsoncst.Apply(doc.Src, []jsoncst.Edit{doc.ReplaceValue(doc.Root, valueJSON)})
+	}
+
+	parent, err := doc.Resolve(tokens[:len(tokens)-1])
+	if err != nil {
+		return doc.Src, err
+	}
+	last := tokens[len(tokens)-1]
+
+	var edit jsoncst.Edit
+	switch parent.Kind {
+	case jsoncst.Object:
+		if m := parent.Member(last); m != nil {
+			edit = doc.ReplaceValue(m.Value, valueJSON)
+		} else {
+			edit = doc.InsertMember(parent, len(parent.Members), last, valueJSON)
+		}
+	case jsoncst.Array:
+		index, err := jsoncst.ArrayIndex(last, len(parent.Elems))
+		if err != nil {
+			return doc.Src, err
+		}
+		if index > len(parent.Elems) {
+			return doc.Src, fmt.Errorf("index %s out of range at '%s'", last, jsoncst.FormatPointer(tokens[:len(tokens)-1]))
+		}
+		edit = doc.InsertElem(parent, index, valueJSON)
+	default:
+		return doc.Src, fmt.Errorf("cannot add to %s at '%s'", parent.Kind, jsoncst.FormatPointer(tokens[:len(tokens)-1]))
+	}
+
+	return jsoncst.Apply(doc.Src, []jsoncst.Edit{edit})
+}
+
+// patchRemove removes the value at the pointer, which must exist
+func patchRemove(doc *jsoncst.Document, tokens []string) (string, error) {
+	if len(tokens) == 0 {
+		return doc.Src, fmt.Errorf("cannot remove the root")
+	}
+
+	target, err := doc.Resolve(tokens)
+	if err != nil {
+		return doc.Src, err
+	}
+
+	parent := target.Parent
+	var edits []jsoncst.Edit
+	if parent.Kind == jsoncst.Object {
+		edits = doc.RemoveMembers(parent, []int{parent.MemberIndex(tokens[len(tokens)-1])})
+	} else {
+		for i, elem := range parent.Elems {
+			if elem == target {
+				edits = doc.RemoveElems(parent, []int{i})
+				break
+			}
+		}
+	}
+	return jsoncst.Apply(doc.Src, edits)
+}
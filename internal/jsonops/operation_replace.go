@@ -1,30 +1,127 @@
 package jsonops
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 
 	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
 )
 
 // ReplaceKeyRequest represents a request to replace keys in JSON files
 type ReplaceKeyRequest struct {
-	OldKey        string   `json:"oldKey"`
-	NewKey        string   `json:"newKey"`
-	SelectedFiles []string `json:"selectedFiles"`
+	OldKey        string           `json:"oldKey"`
+	NewKey        string           `json:"newKey"`
+	Scope         KeyScope         `json:"scope"`
+	SelectedFiles []string         `json:"selectedFiles"`
+	DryRun        bool             `json:"dryRun"`
+	Syntaxes      fileops.Syntaxes `json:"-"`
 }
 
 // ReplaceKeyResult represents the result of a key replacement operation
 type ReplaceKeyResult struct {
-	FilePath         string `json:"filePath"`
-	Success          bool   `json:"success"`
-	Error            string `json:"error,omitempty"`
-	ReplacementCount int    `json:"replacementCount"`
-	ModifiedContent  string `json:"modifiedContent"`
+	FilePath         string        `json:"filePath"`
+	Success          bool          `json:"success"`
+	Error            string        `json:"error,omitempty"`
+	Warning          string        `json:"warning,omitempty"`
+	RecordErrors     []RecordError `json:"recordErrors,omitempty"` // records of a JSON Lines file the operation failed on
+	Conflicts        []string      `json:"conflicts,omitempty"`    // paths of the objects that already have the new key
+	ReplacementCount int           `json:"replacementCount"`
+	ModifiedContent  string        `json:"modifiedContent"`
+	Change           *FileChange   `json:"change,omitempty"`
 }
 
-// ReplaceKeyInFiles replaces old keys with new keys in selected files using string replacement
+// KeyScope limits which members a key operation touches. The zero value allows
+// every member of the document.
+type KeyScope struct {
+	// ParentPath only allows the members of the objects it selects, e.g.
+	// "response.body.items[*]". A selected array stands for its object elements.
+	ParentPath string `json:"parentPath,omitempty"`
+	// Depth only allows members at that depth, counting every key and index of
+	// their path: "id" is at depth 1, "user.id" at 2 and "items[0].id" at 3.
+	Depth int `json:"depth,omitempty"`
+}
+
+// Validate checks the scope without looking at any document
+func (s KeyScope) Validate() error {
+	if s.Depth < 0 {
+		return fmt.Errorf("depth cannot be negative")
+	}
+	if _, err := jsonpath.Parse(s.ParentPath); err != nil {
+		return fmt.Errorf("invalid parent path: %v", err)
+	}
+	return nil
+}
+
+// String describes the scope for logs, e.g. "under items[*] at depth 3"
+func (s KeyScope) String() string {
+	var parts []string
+	if s.ParentPath != "" {
+		parts = append(parts, "under "+s.ParentPath)
+	}
+	if s.Depth > 0 {
+		parts = append(parts, fmt.Sprintf("at depth %d", s.Depth))
+	}
+	if len(parts) == 0 {
+		return "anywhere"
+	}
+	return strings.Join(parts, " ")
+}
+
+// parents returns the objects whose members are in scope, or nil when every
+// object is
+func (s KeyScope) parents(doc *jsoncst.Document) map[*jsoncst.Node]bool {
+	if s.ParentPath == "" {
+		return nil
+	}
+
+	parents := make(map[*jsoncst.Node]bool)
+	for _, target := range doc.Select(jsonpath.MustParse(s.ParentPath)) {
+		switch target.Node.Kind {
+		case jsoncst.Object:
+			parents[target.Node] = true
+		case jsoncst.Array:
+			for _, elem := range target.Node.Elems {
+				if elem.Kind == jsoncst.Object {
+					parents[elem] = true
+				}
+			}
+		}
+	}
+	return parents
+}
+
+// members returns the members with the key that are in scope, in document order
+func (s KeyScope) members(doc *jsoncst.Document, key string) []memberRef {
+	parents := s.parents(doc)
+
+	var refs []memberRef
+	for _, ref := range findMembers(doc, key) {
+		if parents != nil && !parents[ref.object] {
+			continue
+		}
+		if s.Depth > 0 && len(ref.path) != s.Depth {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// KeyConflictError reports objects that already have a member with the new name of
+// a renamed key, so renaming would create duplicate keys
+type KeyConflictError struct {
+	Key   string
+	Paths []string // paths of the conflicting objects
+}
+
+func (e *KeyConflictError) Error() string {
+	return fmt.Sprintf("key '%s' already exists next to the renamed key in %s", e.Key, strings.Join(e.Paths, ", "))
+}
+
+// ReplaceKeyInFiles renames keys in the selected files, leaving all other text untouched
 func ReplaceKeyInFiles(request ReplaceKeyRequest) ([]ReplaceKeyResult, error) {
 	if request.OldKey == "" {
 		return nil, fmt.Errorf("old key cannot be empty")
@@ -38,6 +135,10 @@ func ReplaceKeyInFiles(request ReplaceKeyRequest) ([]ReplaceKeyResult, error) {
 		return nil, fmt.Errorf("old key and new key cannot be the same")
 	}
 
+	if err := request.Scope.Validate(); err != nil {
+		return nil, err
+	}
+
 	var results []ReplaceKeyResult
 
 	for _, filePath := range request.SelectedFiles {
@@ -54,18 +155,44 @@ func ReplaceKeyInFiles(request ReplaceKeyRequest) ([]ReplaceKeyResult, error) {
 			continue
 		}
 
-		// Perform the key replacement using string replacement
-		modifiedContent, replacementCount := replaceKeysInText(string(content), request.OldKey, request.NewKey)
+		// Rename the matching keys in the document
+		replacementCount := 0
+		modifiedContent, recordErrors, yamlChanges, err := editFileChanges(filePath, string(content), func(record string) (string, error) {
+			result, count, err := RenameJSONKeys(record, request.OldKey, request.NewKey, request.Scope)
+			replacementCount += count
+			return result, err
+		})
+		result.RecordErrors = recordErrors
+		if yamlChanges != nil {
+			// Count the keys of the YAML document, not their copies under aliases
+			replacementCount = len(yamlChanges.Renames)
+		}
+		var conflict *KeyConflictError
+		if errors.As(err, &conflict) {
+			result.Error = err.Error()
+			result.Conflicts = conflict.Paths
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to parse JSON: %v", err)
+			results = append(results, result)
+			continue
+		}
 
 		if replacementCount == 0 {
 			result.Error = fmt.Sprintf("no keys found with name '%s'", request.OldKey)
+			if request.Scope != (KeyScope{}) {
+				result.Error += " " + request.Scope.String()
+			}
 			results = append(results, result)
 			continue
 		}
 
-		// Write the modified content back to the file
-		if err := fileops.WriteFile(filePath, []byte(modifiedContent)); err != nil {
-			result.Error = fmt.Sprintf("failed to write file: %v", err)
+		// Write the modified content back to the file unless this is a dry run
+		change, err := writeChange(filePath, content, modifiedContent, request.DryRun, request.Syntaxes)
+		if err != nil {
+			result.Error = err.Error()
 			results = append(results, result)
 			continue
 		}
@@ -73,26 +200,45 @@ func ReplaceKeyInFiles(request ReplaceKeyRequest) ([]ReplaceKeyResult, error) {
 		result.Success = true
 		result.ReplacementCount = replacementCount
 		result.ModifiedContent = modifiedContent
+		result.Change = change
 		results = append(results, result)
 	}
 
 	return results, nil
 }
 
-// replaceKeysInText replaces JSON keys in text using regex pattern matching
-func replaceKeysInText(content, oldKey, newKey string) (string, int) {
-	// Create a regex pattern to match JSON keys
-	// This pattern matches: "oldKey" followed by optional whitespace and a colon
-	pattern := fmt.Sprintf(`"(%s)"\s*:`, regexp.QuoteMeta(oldKey))
-	regex := regexp.MustCompile(pattern)
-
-	// Replace all occurrences
-	replacementCount := 0
-	result := regex.ReplaceAllStringFunc(content, func(match string) string {
-		replacementCount++
-		// Replace the old key with the new key while preserving the formatting
-		return strings.Replace(match, fmt.Sprintf(`"%s"`, oldKey), fmt.Sprintf(`"%s"`, newKey), 1)
-	})
-
-	return result, replacementCount
+// RenameJSONKeys renames the object members named oldKey within the scope and returns
+// the number renamed. Only real keys are touched; text that merely looks like a key
+// inside a string value is left alone. If any object in scope already has newKey,
+// nothing is renamed and a *KeyConflictError lists those objects.
+func RenameJSONKeys(content, oldKey, newKey string, scope KeyScope) (string, int, error) {
+	if err := scope.Validate(); err != nil {
+		return content, 0, err
+	}
+	doc, err := parseDocument(content)
+	if err != nil {
+		return content, 0, err
+	}
+
+	refs := scope.members(doc, oldKey)
+	var conflicts []string
+	for _, ref := range refs {
+		if ref.object.Member(newKey) != nil {
+			conflicts = append(conflicts, displayPath(ref.path[:len(ref.path)-1]))
+		}
+	}
+	if len(conflicts) > 0 {
+		return content, 0, &KeyConflictError{Key: newKey, Paths: conflicts}
+	}
+
+	edits := make([]jsoncst.Edit, 0, len(refs))
+	for _, ref := range refs {
+		edits = append(edits, doc.RenameKey(ref.object.Members[ref.index], newKey))
+	}
+
+	result, err := jsoncst.Apply(content, edits)
+	if err != nil {
+		return content, 0, err
+	}
+	return result, len(edits), nil
 }
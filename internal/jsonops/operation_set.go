@@ -0,0 +1,241 @@
+package jsonops
+
+import (
+	"fmt"
+
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
+)
+
+// SetMode controls which keys a set-value operation is allowed to touch
+type SetMode string
+
+const (
+	// SetIfMissing only adds the key where it does not exist yet
+	SetIfMissing SetMode = "missing"
+	// SetIfPresent only updates the key where it already exists
+	SetIfPresent SetMode = "update"
+	// SetUpsert updates existing keys and adds missing ones
+	SetUpsert SetMode = "upsert"
+)
+
+// SetValueRequest represents a request to set a value in JSON files
+type SetValueRequest struct {
+	KeyPath       string           `json:"keyPath"`
+	Value         any              `json:"value"`
+	Mode          SetMode          `json:"mode"`
+	SelectedFiles []string         `json:"selectedFiles"`
+	DryRun        bool             `json:"dryRun"`
+	Syntaxes      fileops.Syntaxes `json:"-"`
+}
+
+// SetValueResult represents the result of a set-value operation
+type SetValueResult struct {
+	FilePath        string        `json:"filePath"`
+	Success         bool          `json:"success"`
+	Skipped         bool          `json:"skipped,omitempty"`
+	Error           string        `json:"error,omitempty"`
+	Warning         string        `json:"warning,omitempty"`
+	RecordErrors    []RecordError `json:"recordErrors,omitempty"` // records of a JSON Lines file the operation failed on
+	UpdatedCount    int           `json:"updatedCount"`
+	InsertedCount   int           `json:"insertedCount"`
+	ModifiedContent string        `json:"modifiedContent"`
+	Change          *FileChange   `json:"change,omitempty"`
+}
+
+// SetValuesInFiles sets the value at a path in the selected files
+func SetValuesInFiles(request SetValueRequest) ([]SetValueResult, error) {
+	if request.KeyPath == "" {
+		return nil, fmt.Errorf("key path cannot be empty")
+	}
+
+	if _, err := parseSetPath(request.KeyPath, request.Mode); err != nil {
+		return nil, err
+	}
+
+	var results []SetValueResult
+
+	for _, filePath := range request.SelectedFiles {
+		result := SetValueResult{
+			FilePath: filePath,
+			Success:  false,
+		}
+
+		// Read the file content
+		content, err := fileops.ReadFile(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read file: %v", err)
+			results = append(results, result)
+			continue
+		}
+
+		updated, inserted := 0, 0
+		modifiedContent, recordErrors, err := editFile(filePath, string(content), func(record string) (string, error) {
+			result, u, i, err := SetJSONValue(record, request.KeyPath, request.Value, request.Mode)
+			updated, inserted = updated+u, inserted+i
+			return result, err
+		})
+		result.RecordErrors = recordErrors
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if updated+inserted == 0 {
+			result.Skipped = true
+			result.Error = skipReason(request.Mode, request.KeyPath)
+			results = append(results, result)
+			continue
+		}
+
+		// Write the modified content back to the file unless this is a dry run
+		change, err := writeChange(filePath, content, modifiedContent, request.DryRun, request.Syntaxes)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Success = true
+		result.UpdatedCount = updated
+		result.InsertedCount = inserted
+		result.ModifiedContent = modifiedContent
+		result.Change = change
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// SetJSONValue sets the value at a path expression, replacing only the text span of
+// existing values and inserting missing keys after the last member of their object.
+// It returns the number of values updated and inserted. Values that already hold the
+// requested value are left alone and not counted.
+//
+// The mode decides what happens per target: SetIfMissing only inserts, SetIfPresent
+// only updates, and SetUpsert does both. Inserting requires the path to end in a key.
+//
+// Example:
+//
+//	result, updated, inserted, err := SetJSONValue(`{"version": "1.0"}`, "version", "2.0", SetIfPresent)
+//	// Result: `{"version": "2.0"}`, updated == 1, inserted == 0
+func SetJSONValue(jsonStr, keyPath string, value any, mode SetMode) (string, int, int, error) {
+	path, err := parseSetPath(keyPath, mode)
+	if err != nil {
+		return jsonStr, 0, 0, err
+	}
+
+	doc, err := parseDocument(jsonStr)
+	if err != nil {
+		return jsonStr, 0, 0, fmt.Errorf("failed to parse JSON: %v", err)
+	}
+
+	valueJSON, err := jsoncst.Marshal(value, doc.IndentUnit())
+	if err != nil {
+		return jsonStr, 0, 0, fmt.Errorf("error marshaling value: %v", err)
+	}
+
+	var edits []jsoncst.Edit
+	var missing []*jsoncst.Node
+	updated, inserted := 0, 0
+	replaced := make(map[*jsoncst.Node]bool)
+
+	update := func(node *jsoncst.Node) {
+		if same, err := doc.Equal(node, []byte(valueJSON)); err == nil && same {
+			return
+		}
+		replaced[node] = true
+	}
+
+	last := path[len(path)-1]
+	if last.Kind != jsonpath.KeySegment {
+		// Indices and wildcards can only address values that already exist
+		for _, match := range doc.Select(path) {
+			update(match.Node)
+		}
+	} else {
+		parents := doc.Select(path[:len(path)-1])
+		if len(parents) == 0 {
+			return jsonStr, 0, 0, fmt.Errorf("path '%s' not found", path[:len(path)-1])
+		}
+
+		visited := make(map[*jsoncst.Node]bool)
+		for _, parent := range parents {
+			objects := []*jsoncst.Node{parent.Node}
+			if parent.Node.Kind == jsoncst.Array {
+				objects = parent.Node.Elems
+			}
+
+			for _, obj := range objects {
+				if obj.Kind != jsoncst.Object || visited[obj] {
+					continue
+				}
+				visited[obj] = true
+				member := obj.Member(last.Key)
+				switch {
+				case member != nil && mode != SetIfMissing:
+					update(member.Value)
+				case member == nil && mode != SetIfPresent:
+					missing = append(missing, obj)
+				}
+			}
+		}
+	}
+
+	// Replace values and insert members, skipping anything nested inside a replaced value
+	for node := range replaced {
+		if hasMarkedAncestor(node.Parent, replaced) {
+			continue
+		}
+		edits = append(edits, doc.ReplaceValue(node, valueJSON))
+		updated++
+	}
+	for _, obj := range missing {
+		if hasMarkedAncestor(obj, replaced) {
+			continue
+		}
+		edits = append(edits, doc.InsertMember(obj, len(obj.Members), path[len(path)-1].Key, valueJSON))
+		inserted++
+	}
+
+	result, err := jsoncst.Apply(doc.Src, edits)
+	if err != nil {
+		return jsonStr, 0, 0, err
+	}
+	return result, updated, inserted, nil
+}
+
+// parseSetPath parses a set path and checks it against the mode
+func parseSetPath(keyPath string, mode SetMode) (jsonpath.Path, error) {
+	switch mode {
+	case SetIfMissing, SetIfPresent, SetUpsert:
+	default:
+		return nil, fmt.Errorf("invalid set mode '%s' (expected %s, %s or %s)", mode, SetIfMissing, SetIfPresent, SetUpsert)
+	}
+
+	path, err := jsonpath.Parse(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key path: %v", err)
+	}
+	if path.IsRoot() {
+		return nil, fmt.Errorf("key path must not refer to the root")
+	}
+	if mode != SetIfPresent && path[len(path)-1].Kind != jsonpath.KeySegment {
+		return nil, fmt.Errorf("mode '%s' requires the key path to end in a key", mode)
+	}
+	return path, nil
+}
+
+// skipReason explains why a file was left unchanged
+func skipReason(mode SetMode, keyPath string) string {
+	switch mode {
+	case SetIfMissing:
+		return fmt.Sprintf("key '%s' already exists", keyPath)
+	case SetIfPresent:
+		return fmt.Sprintf("key '%s' not found or already up to date", keyPath)
+	default:
+		return fmt.Sprintf("key '%s' is already up to date", keyPath)
+	}
+}
@@ -0,0 +1,118 @@
+package jsonops
+
+// Outcome is what the per-file result of every operation reports
+type Outcome struct {
+	FilePath     string
+	Success      bool
+	Skipped      bool
+	Error        string
+	Warning      string
+	RecordErrors []RecordError
+	Change       *FileChange
+}
+
+// FileResult is the per-file result of an operation
+type FileResult interface {
+	Outcome() Outcome
+	// Fail turns the result into a failure, e.g. when its change could not be written
+	Fail(err string)
+	// Warn notes a problem that did not stop the change, e.g. when it was written
+	// but its undo journal entry could not be completed
+	Warn(warning string)
+}
+
+func (r *AddItemResult) Outcome() Outcome {
+	return Outcome{FilePath: r.FilePath, Success: r.Success, Skipped: r.Skipped, Error: r.Error, Warning: r.Warning, RecordErrors: r.RecordErrors, Change: r.Change}
+}
+
+func (r *AddItemResult) Fail(err string) {
+	r.Success, r.Error = false, err
+}
+
+func (r *AddItemResult) Warn(warning string) {
+	r.Warning = warning
+}
+
+func (r *ReplaceKeyResult) Outcome() Outcome {
+	return Outcome{FilePath: r.FilePath, Success: r.Success, Error: r.Error, Warning: r.Warning, RecordErrors: r.RecordErrors, Change: r.Change}
+}
+
+func (r *ReplaceKeyResult) Fail(err string) {
+	r.Success, r.Error = false, err
+}
+
+func (r *ReplaceKeyResult) Warn(warning string) {
+	r.Warning = warning
+}
+
+func (r *ConvertKeysResult) Outcome() Outcome {
+	return Outcome{FilePath: r.FilePath, Success: r.Success, Error: r.Error, Warning: r.Warning, RecordErrors: r.RecordErrors, Change: r.Change}
+}
+
+func (r *ConvertKeysResult) Fail(err string) {
+	r.Success, r.Error = false, err
+}
+
+func (r *ConvertKeysResult) Warn(warning string) {
+	r.Warning = warning
+}
+
+func (r *DeleteKeyResult) Outcome() Outcome {
+	return Outcome{FilePath: r.FilePath, Success: r.Success, Error: r.Error, Warning: r.Warning, RecordErrors: r.RecordErrors, Change: r.Change}
+}
+
+func (r *DeleteKeyResult) Fail(err string) {
+	r.Success, r.Error = false, err
+}
+
+func (r *DeleteKeyResult) Warn(warning string) {
+	r.Warning = warning
+}
+
+func (r *SetValueResult) Outcome() Outcome {
+	return Outcome{FilePath: r.FilePath, Success: r.Success, Skipped: r.Skipped, Error: r.Error, Warning: r.Warning, RecordErrors: r.RecordErrors, Change: r.Change}
+}
+
+func (r *SetValueResult) Fail(err string) {
+	r.Success, r.Error = false, err
+}
+
+func (r *SetValueResult) Warn(warning string) {
+	r.Warning = warning
+}
+
+func (r *PatchResult) Outcome() Outcome {
+	return Outcome{FilePath: r.FilePath, Success: r.Success, Error: r.Error, Warning: r.Warning, RecordErrors: r.RecordErrors, Change: r.Change}
+}
+
+func (r *PatchResult) Fail(err string) {
+	r.Success, r.Error = false, err
+}
+
+func (r *PatchResult) Warn(warning string) {
+	r.Warning = warning
+}
+
+func (r *MergePatchResult) Outcome() Outcome {
+	return Outcome{FilePath: r.FilePath, Success: r.Success, Skipped: r.Skipped, Error: r.Error, Warning: r.Warning, RecordErrors: r.RecordErrors, Change: r.Change}
+}
+
+func (r *MergePatchResult) Fail(err string) {
+	r.Success, r.Error = false, err
+}
+
+func (r *MergePatchResult) Warn(warning string) {
+	r.Warning = warning
+}
+
+func (r *CommitResult) Outcome() Outcome {
+	return Outcome{FilePath: r.FilePath, Success: r.Success, Error: r.Error, Warning: r.Warning, Change: r.Change}
+}
+
+func (r *CommitResult) Fail(err string) {
+	r.Success, r.Error = false, err
+}
+
+func (r *CommitResult) Warn(warning string) {
+	r.Warning = warning
+}
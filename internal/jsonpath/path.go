@@ -0,0 +1,268 @@
+package jsonpath
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SegmentKind identifies the type of a single path segment
+type SegmentKind int
+
+const (
+	// KeySegment selects an object member by name
+	KeySegment SegmentKind = iota
+	// IndexSegment selects an array element by position
+	IndexSegment
+	// WildcardSegment selects every member of an object or element of an array
+	WildcardSegment
+	// DescendSegment matches zero or more levels of nesting (the ".." operator)
+	DescendSegment
+)
+
+// Segment is a single step of a path expression
+type Segment struct {
+	Kind  SegmentKind
+	Key   string
+	Index int
+}
+
+// Path is a parsed path expression.
+//
+// Supported syntax:
+//   - user.profile.address   dotted object members
+//   - items[0].id            array indices
+//   - items[*].id, user.*    wildcards
+//   - ..id                   recursive descent (id at any depth)
+//   - ["odd.key"]            bracket-quoted member names
+//
+// A leading "$" denotes the document root and is optional. The empty
+// expression refers to the root itself.
+type Path []Segment
+
+// Parse parses a path expression
+func Parse(expr string) (Path, error) {
+	expr = strings.TrimSpace(expr)
+	expr = strings.TrimPrefix(expr, "$")
+
+	var path Path
+	i := 0
+	for i < len(expr) {
+		switch {
+		case strings.HasPrefix(expr[i:], ".."):
+			i += 2
+			path = append(path, Segment{Kind: DescendSegment})
+			if i >= len(expr) {
+				return nil, fmt.Errorf("path '%s' ends with '..'", expr)
+			}
+			if expr[i] == '.' {
+				return nil, fmt.Errorf("unexpected '.' at position %d in path '%s'", i, expr)
+			}
+			if expr[i] == '[' {
+				continue
+			}
+			seg, next := parseName(expr, i)
+			path = append(path, seg)
+			i = next
+
+		case expr[i] == '.':
+			i++
+			if i >= len(expr) || expr[i] == '.' || expr[i] == '[' {
+				return nil, fmt.Errorf("missing key after '.' at position %d in path '%s'", i, expr)
+			}
+			seg, next := parseName(expr, i)
+			path = append(path, seg)
+			i = next
+
+		case expr[i] == '[':
+			seg, next, err := parseBracket(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, seg)
+			i = next
+
+		default:
+			if len(path) > 0 {
+				return nil, fmt.Errorf("unexpected character '%c' at position %d in path '%s'", expr[i], i, expr)
+			}
+			seg, next := parseName(expr, i)
+			path = append(path, seg)
+			i = next
+		}
+	}
+
+	return path, nil
+}
+
+// MustParse is like Parse but panics if the expression cannot be parsed
+func MustParse(expr string) Path {
+	path, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// parseName reads an unquoted member name (or "*") starting at i
+func parseName(expr string, i int) (Segment, int) {
+	end := i
+	for end < len(expr) && expr[end] != '.' && expr[end] != '[' {
+		end++
+	}
+
+	name := expr[i:end]
+	if name == "*" {
+		return Segment{Kind: WildcardSegment}, end
+	}
+	return Segment{Kind: KeySegment, Key: name}, end
+}
+
+// indexToken matches an array index written in canonical form, as JSON Pointer
+// requires: digits only, without a sign or a leading zero
+var indexToken = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+
+// parseBracket reads a bracketed segment: [n], [*], ["name"] or ['name']
+func parseBracket(expr string, i int) (Segment, int, error) {
+	i++ // skip '['
+	if i >= len(expr) {
+		return Segment{}, 0, fmt.Errorf("unterminated '[' in path '%s'", expr)
+	}
+
+	if quote := expr[i]; quote == '"' || quote == '\'' {
+		var name strings.Builder
+		j := i + 1
+		for j < len(expr) && expr[j] != quote {
+			if expr[j] == '\\' && j+1 < len(expr) {
+				j++
+			}
+			name.WriteByte(expr[j])
+			j++
+		}
+		if j+1 >= len(expr) || expr[j+1] != ']' {
+			return Segment{}, 0, fmt.Errorf("unterminated quoted key in path '%s'", expr)
+		}
+		return Segment{Kind: KeySegment, Key: name.String()}, j + 2, nil
+	}
+
+	end := strings.IndexByte(expr[i:], ']')
+	if end == -1 {
+		return Segment{}, 0, fmt.Errorf("unterminated '[' in path '%s'", expr)
+	}
+	content := strings.TrimSpace(expr[i : i+end])
+	next := i + end + 1
+
+	if content == "*" {
+		return Segment{Kind: WildcardSegment}, next, nil
+	}
+
+	if !indexToken.MatchString(content) {
+		return Segment{}, 0, fmt.Errorf("invalid array index '%s' in path '%s'", content, expr)
+	}
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return Segment{}, 0, fmt.Errorf("invalid array index '%s' in path '%s'", content, expr)
+	}
+	return Segment{Kind: IndexSegment, Index: index}, next, nil
+}
+
+// String formats the path back into its canonical expression form
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "$"
+	}
+
+	var sb strings.Builder
+	for i, seg := range p {
+		switch seg.Kind {
+		case KeySegment:
+			if !isPlainName(seg.Key) {
+				sb.WriteString(`["` + strings.ReplaceAll(seg.Key, `"`, `\"`) + `"]`)
+				continue
+			}
+			if i > 0 && p[i-1].Kind != DescendSegment {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(seg.Key)
+		case IndexSegment:
+			sb.WriteString("[" + strconv.Itoa(seg.Index) + "]")
+		case WildcardSegment:
+			if i > 0 && p[i-1].Kind != DescendSegment {
+				sb.WriteByte('.')
+			}
+			sb.WriteByte('*')
+		case DescendSegment:
+			sb.WriteString("..")
+		}
+	}
+	return sb.String()
+}
+
+// isPlainName reports whether a key can be written without bracket quoting
+func isPlainName(key string) bool {
+	return key != "" && key != "*" && !strings.ContainsAny(key, `.[]"'`) && strings.TrimSpace(key) == key
+}
+
+// IsRoot reports whether the path refers to the document root
+func (p Path) IsRoot() bool {
+	return len(p) == 0
+}
+
+// IsConcrete reports whether the path contains only keys and indices
+func (p Path) IsConcrete() bool {
+	for _, seg := range p {
+		if seg.Kind == WildcardSegment || seg.Kind == DescendSegment {
+			return false
+		}
+	}
+	return true
+}
+
+// Child returns a copy of the path extended by an object member
+func (p Path) Child(key string) Path {
+	return p.append(Segment{Kind: KeySegment, Key: key})
+}
+
+// Elem returns a copy of the path extended by an array index
+func (p Path) Elem(index int) Path {
+	return p.append(Segment{Kind: IndexSegment, Index: index})
+}
+
+func (p Path) append(seg Segment) Path {
+	result := make(Path, len(p), len(p)+1)
+	copy(result, p)
+	return append(result, seg)
+}
+
+// Match reports whether the concrete path (keys and indices only) is selected by the expression
+func (p Path) Match(concrete Path) bool {
+	if len(p) == 0 {
+		return len(concrete) == 0
+	}
+
+	seg := p[0]
+	switch seg.Kind {
+	case DescendSegment:
+		for skip := 0; skip <= len(concrete); skip++ {
+			if p[1:].Match(concrete[skip:]) {
+				return true
+			}
+		}
+		return false
+	case KeySegment:
+		if len(concrete) == 0 || concrete[0].Kind != KeySegment || concrete[0].Key != seg.Key {
+			return false
+		}
+	case IndexSegment:
+		if len(concrete) == 0 || concrete[0].Kind != IndexSegment || concrete[0].Index != seg.Index {
+			return false
+		}
+	case WildcardSegment:
+		if len(concrete) == 0 {
+			return false
+		}
+	}
+
+	return p[1:].Match(concrete[1:])
+}
@@ -16,6 +16,9 @@ type FileTreeNode struct {
 	Children []*FileTreeNode    `json:"children,omitempty"`
 	Count    int                `json:"count"`
 	BasePath string             `json:"basePath,omitempty"` // Which base path this node belongs to
+
+	// MatchCount totals the query matches of the files below this node
+	MatchCount int `json:"matchCount,omitempty"`
 }
 
 // BuildFileTreeFromMultiplePaths creates a unified tree structure from files across multiple base paths
@@ -45,6 +48,7 @@ func BuildFileTreeFromMultiplePaths(files []fileops.JSONFile, basePaths []string
 			basePathTree := BuildFileTree(pathFiles, basePath)
 			if basePathTree != nil {
 				root.Children = append(root.Children, basePathTree)
+				root.MatchCount += basePathTree.MatchCount
 			}
 		}
 	}
@@ -131,20 +135,27 @@ func createDirNodes(dirMap map[string]*FileTreeNode, targetDir, basePath string,
 	dirMap[targetDir] = dirNode
 }
 
-// calculateCounts recursively calculates file counts for each directory node
-func calculateCounts(node *FileTreeNode) int {
+// calculateCounts recursively calculates file and match counts for each directory node
+func calculateCounts(node *FileTreeNode) (int, int) {
 	if !node.IsDir {
-		return 0
+		return 0, 0
 	}
 
 	count := len(node.Files)
+	matchCount := 0
+	for _, file := range node.Files {
+		matchCount += file.MatchCount
+	}
 
 	for _, child := range node.Children {
-		count += calculateCounts(child)
+		childCount, childMatches := calculateCounts(child)
+		count += childCount
+		matchCount += childMatches
 	}
 
 	node.Count = count
-	return count
+	node.MatchCount = matchCount
+	return count, matchCount
 }
 
 // FlattenTree converts a tree structure back to a flat list of files
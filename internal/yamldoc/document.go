@@ -0,0 +1,377 @@
+// Package yamldoc edits YAML documents through their JSON form. A document is
+// exposed as standard JSON, so searches and the JSON operations run on it as they
+// are, and the changes an operation makes to that JSON are applied back to the
+// YAML node tree and spliced into the source at the positions of the nodes they
+// change. The text of everything an edit does not touch, with its comments,
+// anchors, aliases and blank lines, stays as it was.
+package yamldoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
+)
+
+// maxAliasNodes bounds the number of nodes aliases may add to the JSON form of a
+// document, so that aliases of aliases cannot expand it without limit
+const maxAliasNodes = 1000000
+
+// Document is a parsed YAML document
+type Document struct {
+	Src    string
+	Root   *yaml.Node // the document node
+	indent int        // indentation step of the source, in spaces
+
+	edits   []jsoncst.Edit // edits of Src made by Update
+	rewrite bool           // an update could not be spliced into Src, so the node tree is encoded
+}
+
+// Parse parses a single YAML document
+func Parse(src string) (*Document, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(src))
+	var root yaml.Node
+	if err := decoder.Decode(&root); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("empty YAML document")
+	}
+	var next yaml.Node
+	if err := decoder.Decode(&next); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("multi-document YAML is not supported")
+	}
+	if err := checkAliases(&root); err != nil {
+		return nil, err
+	}
+	return &Document{Src: src, Root: &root, indent: detectIndent(src)}, nil
+}
+
+// checkAliases rejects an alias inside the value of its own anchor, whose JSON form
+// would never end, and aliases that expand to more than maxAliasNodes nodes
+func checkAliases(root *yaml.Node) error {
+	open := make(map[*yaml.Node]bool)
+	var check func(n *yaml.Node) error
+	check = func(n *yaml.Node) error {
+		if n.Kind == yaml.AliasNode {
+			if open[n.Alias] {
+				return fmt.Errorf("line %d: alias *%s refers to a value that contains it", n.Line, n.Value)
+			}
+			return nil
+		}
+		open[n] = true
+		defer delete(open, n)
+		for _, child := range n.Content {
+			if err := check(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(root); err != nil {
+		return err
+	}
+
+	// The number of nodes of every value once its aliases are expanded, counting
+	// no higher than the limit
+	sizes := make(map[*yaml.Node]int)
+	var size func(n *yaml.Node) int
+	size = func(n *yaml.Node) int {
+		n = resolve(n)
+		if total, ok := sizes[n]; ok {
+			return total
+		}
+		total := 1
+		for _, child := range n.Content {
+			total = min(total+size(child), maxAliasNodes+1)
+		}
+		sizes[n] = total
+		return total
+	}
+	aliased := 0
+	var count func(n *yaml.Node) error
+	count = func(n *yaml.Node) error {
+		if n.Kind == yaml.AliasNode {
+			if aliased = min(aliased+size(n), maxAliasNodes+1); aliased > maxAliasNodes {
+				return fmt.Errorf("line %d: aliases expand to more than %d nodes", n.Line, maxAliasNodes)
+			}
+			return nil
+		}
+		for _, child := range n.Content {
+			if err := count(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return count(root)
+}
+
+// Edit applies an edit of the JSON form of a YAML document, such as a call to one
+// of the JSON operations, and returns the document with the same changes. The
+// source is returned unchanged when the edit changes nothing.
+func Edit(src string, edit func(jsonStr string) (string, error)) (string, Changes, error) {
+	doc, err := Parse(src)
+	if err != nil {
+		return src, Changes{}, fmt.Errorf("error parsing YAML: %v", err)
+	}
+	modified, err := edit(doc.JSON())
+	if err != nil {
+		return src, Changes{}, err
+	}
+	changes, err := doc.Update(modified)
+	if err != nil || !changes.Changed() {
+		return src, changes, err
+	}
+	out, err := doc.String()
+	return out, changes, err
+}
+
+// value returns the top-level node of the document
+func (d *Document) value() *yaml.Node {
+	return d.Root.Content[0]
+}
+
+// JSON returns the document as standard JSON indented with two spaces. Aliases are
+// expanded and merge keys appear as a "<<" member. Keys are written as text, and
+// scalars JSON has no type for, such as timestamps or .inf, as strings.
+func (d *Document) JSON() string {
+	var sb strings.Builder
+	writeJSON(&sb, d.value(), "")
+	return sb.String()
+}
+
+// Value decodes the document into the values encoding/json would produce for its
+// JSON form
+func (d *Document) Value() any {
+	return plain(d.value(), false)
+}
+
+// String writes the document back as YAML: the source with the edits of every
+// update, or, when an update could not be located in the source, the node tree
+// encoded with the indentation of the source
+func (d *Document) String() (string, error) {
+	if !d.rewrite {
+		return jsoncst.Apply(d.Src, d.edits)
+	}
+	plainMergeKeys(d.Root)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(d.indent)
+	if err := encoder.Encode(d.Root); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	out := buf.String()
+	if strings.HasPrefix(d.Src, "---") && !strings.HasPrefix(out, "---") {
+		out = "---\n" + out
+	}
+	return out, nil
+}
+
+// Locate returns the 1-based position of the value at a concrete path, starting at
+// its key when it has one, and the position just past its last scalar
+func (d *Document) Locate(path jsonpath.Path) (line, column, endLine, endColumn int, ok bool) {
+	n, start := d.value(), d.value()
+	for _, seg := range path {
+		n = resolve(n)
+		switch {
+		case seg.Kind == jsonpath.KeySegment && n.Kind == yaml.MappingNode:
+			found := false
+			for i := len(n.Content) - 2; i >= 0 && !found; i -= 2 {
+				if n.Content[i].Value == seg.Key {
+					start, n, found = n.Content[i], n.Content[i+1], true
+				}
+			}
+			if !found {
+				return 0, 0, 0, 0, false
+			}
+		case seg.Kind == jsonpath.IndexSegment && n.Kind == yaml.SequenceNode && seg.Index < len(n.Content):
+			n = n.Content[seg.Index]
+			start = n
+		default:
+			return 0, 0, 0, 0, false
+		}
+	}
+
+	end := n
+	for len(end.Content) > 0 {
+		end = end.Content[len(end.Content)-1]
+	}
+	endColumn = end.Column + len(end.Value)
+	if end.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		endColumn += 2
+	}
+	return start.Line, start.Column, end.Line, endColumn, true
+}
+
+// plainMergeKeys clears the tag of merge keys, which the encoder would otherwise
+// write out as "!!merge <<"
+func plainMergeKeys(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if key := n.Content[i]; key.Tag == "!!merge" {
+				key.Tag = ""
+			}
+		}
+	}
+	for _, child := range n.Content {
+		plainMergeKeys(child)
+	}
+}
+
+// detectIndent returns the indentation of the first indented line, or 2
+func detectIndent(src string) int {
+	for _, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent > 0 && strings.TrimSpace(trimmed) != "" && !strings.HasPrefix(trimmed, "#") {
+			return max(indent, 2)
+		}
+	}
+	return 2
+}
+
+// resolve follows an alias to the node of its anchor
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// writeJSON writes the JSON form of a node
+func writeJSON(sb *strings.Builder, n *yaml.Node, indent string) {
+	n = resolve(n)
+	switch n.Kind {
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			sb.WriteString("{}")
+			return
+		}
+		sb.WriteString("{")
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n" + indent + "  " + jsoncst.Quote(n.Content[i].Value) + ": ")
+			writeJSON(sb, n.Content[i+1], indent+"  ")
+		}
+		sb.WriteString("\n" + indent + "}")
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			sb.WriteString("[]")
+			return
+		}
+		sb.WriteString("[")
+		for i, elem := range n.Content {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n" + indent + "  ")
+			writeJSON(sb, elem, indent+"  ")
+		}
+		sb.WriteString("\n" + indent + "]")
+	default:
+		text, err := jsoncst.Marshal(scalar(n), "")
+		if err != nil {
+			text = jsoncst.Quote(n.Value)
+		}
+		sb.WriteString(text)
+	}
+}
+
+// plain decodes a node into the values of its JSON form. Numbers are float64, as
+// encoding/json decodes them, or with exact set json.Number holding their JSON text.
+func plain(n *yaml.Node, exact bool) any {
+	n = resolve(n)
+	switch n.Kind {
+	case yaml.MappingNode:
+		obj := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			obj[n.Content[i].Value] = plain(n.Content[i+1], exact)
+		}
+		return obj
+	case yaml.SequenceNode:
+		arr := make([]any, len(n.Content))
+		for i, elem := range n.Content {
+			arr[i] = plain(elem, exact)
+		}
+		return arr
+	}
+
+	value := scalar(n)
+	switch v := value.(type) {
+	case int64, float64, json.Number:
+		text, _ := jsoncst.Marshal(v, "")
+		if exact {
+			return json.Number(text)
+		}
+		f, _ := json.Number(text).Float64()
+		return f
+	}
+	return value
+}
+
+// scalar decodes a scalar into a JSON value, falling back to its text. Integers
+// are int64, or json.Number when they do not fit, so they keep every digit.
+func scalar(n *yaml.Node) any {
+	switch n.ShortTag() {
+	case "!!null":
+		return nil
+	case "!!bool":
+		var b bool
+		if n.Decode(&b) == nil {
+			return b
+		}
+	case "!!int":
+		var i int64
+		if n.Decode(&i) == nil {
+			return i
+		}
+		if decimal := strings.TrimPrefix(n.Value, "+"); isDecimal(decimal) {
+			return json.Number(decimal)
+		}
+		fallthrough
+	case "!!float":
+		var f float64
+		if n.Decode(&f) == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f
+		}
+	}
+	return n.Value
+}
+
+// isDecimal reports whether a text is an integer as JSON writes it
+func isDecimal(s string) bool {
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || (len(digits) > 1 && digits[0] == '0') {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// equal reports whether a node has the value of a node of the edited JSON, decoded
+// with its numbers as json.Number
+func equal(n *yaml.Node, value any) bool {
+	return reflect.DeepEqual(plain(n, true), value)
+}
@@ -0,0 +1,308 @@
+package yamldoc
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"goldenMagic/internal/jsoncst"
+)
+
+// source finds the text of nodes in the YAML source from the line and column the
+// parser recorded for them, so that an update rewrites only what it changes
+type source struct {
+	src   string
+	lines []int // offset of the start of every line
+}
+
+func newSource(src string) *source {
+	s := &source{src: src, lines: []int{0}}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+	return s
+}
+
+// offset converts a 1-based line and column, counted in characters, to an offset
+func (s *source) offset(line, column int) (int, bool) {
+	if line < 1 || line > len(s.lines) || column < 1 {
+		return 0, false
+	}
+	i := s.lines[line-1]
+	for c := 1; c < column; c++ {
+		if i >= len(s.src) || s.src[i] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRuneInString(s.src[i:])
+		i += size
+	}
+	return i, true
+}
+
+// line returns the 0-based line an offset is on
+func (s *source) line(offset int) int {
+	return sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > offset }) - 1
+}
+
+// lineStart returns the offset of the start of the line an offset is on
+func (s *source) lineStart(offset int) int {
+	return s.lines[s.line(offset)]
+}
+
+// nextLine returns the offset of the start of the line after the one an offset is
+// on, or the end of the source
+func (s *source) nextLine(offset int) int {
+	if next := s.line(offset) + 1; next < len(s.lines) {
+		return s.lines[next]
+	}
+	return len(s.src)
+}
+
+// text returns the 0-based line without its line break
+func (s *source) text(line int) string {
+	end := len(s.src)
+	if line+1 < len(s.lines) {
+		end = s.lines[line+1] - 1
+	}
+	return strings.TrimSuffix(s.src[s.lines[line]:end], "\r")
+}
+
+// indent returns the number of spaces a 0-based line is indented by
+func (s *source) indent(line int) int {
+	text := s.text(line)
+	return len(text) - len(strings.TrimLeft(text, " "))
+}
+
+// start returns the offset where the text of a node begins, past its anchor and tag
+func (s *source) start(n *yaml.Node) (int, bool) {
+	i, ok := s.offset(n.Line, n.Column)
+	if !ok {
+		return 0, false
+	}
+	for i < len(s.src) && (s.src[i] == '&' || s.src[i] == '!') && n.Kind != yaml.AliasNode {
+		for i < len(s.src) && !strings.ContainsRune(" \t\r\n", rune(s.src[i])) {
+			i++
+		}
+		for i < len(s.src) && (s.src[i] == ' ' || s.src[i] == '\t') {
+			i++
+		}
+	}
+	return i, true
+}
+
+// end returns the offset just past the text of a node, before any comment that
+// follows it. Block containers end with their last entry.
+func (s *source) end(n *yaml.Node, flow bool) (int, bool) {
+	switch {
+	case n.Kind == yaml.AliasNode:
+		i, ok := s.offset(n.Line, n.Column)
+		return i + 1 + len(n.Value), ok
+	case n.Kind == yaml.ScalarNode:
+		return s.scalarEnd(n, flow)
+	case n.Style&yaml.FlowStyle != 0:
+		return s.flowEnd(n)
+	case len(n.Content) == 0:
+		return 0, false
+	}
+	return s.end(n.Content[len(n.Content)-1], flow)
+}
+
+// scalarEnd returns the offset just past a scalar
+func (s *source) scalarEnd(n *yaml.Node, flow bool) (int, bool) {
+	i, ok := s.start(n)
+	if !ok {
+		return 0, false
+	}
+	src := s.src
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		for i++; i < len(src); i++ {
+			switch src[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		for i++; i < len(src); i++ {
+			if src[i] == '\'' {
+				if i+1 < len(src) && src[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return s.blockEnd(i, func(string) bool { return false }), true
+	case n.Tag == "!!null" && n.Value == "":
+		// An empty value is where the parser found it, just after its key
+		return i, true
+	}
+
+	// A plain scalar ends at a comment, at a ": " separating it from its value and,
+	// in flow collections, at an indicator; in block context it may go on over
+	// further indented lines
+	end := i
+	for end < len(src) && src[end] != '\n' {
+		c := src[end]
+		if c == '#' && end > i && (src[end-1] == ' ' || src[end-1] == '\t') {
+			break
+		}
+		if c == ':' && (end+1 == len(src) || strings.ContainsRune(" \t\r\n", rune(src[end+1])) || (flow && strings.ContainsRune(",[]{}", rune(src[end+1])))) {
+			break
+		}
+		if flow && strings.ContainsRune(",[]{}", rune(c)) {
+			break
+		}
+		end++
+	}
+	end = i + len(strings.TrimRight(src[i:end], " \t\r"))
+	if flow || src[i:end] == n.Value {
+		return end, true
+	}
+	return max(end, s.blockEnd(i, func(text string) bool { return strings.HasPrefix(text, "#") })), true
+}
+
+// blockEnd returns the end of the last line below the line of an offset that is
+// indented further than it, stopping at a line the stop function rejects
+func (s *source) blockEnd(offset int, stop func(text string) bool) int {
+	line := s.line(offset)
+	base := s.indent(line)
+	end := s.lineStart(offset) + len(s.text(line))
+	for next := line + 1; next < len(s.lines); next++ {
+		text := s.text(next)
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" {
+			continue
+		}
+		if s.indent(next) <= base || stop(trimmed) {
+			break
+		}
+		end = s.lines[next] + len(text)
+	}
+	return end
+}
+
+// flowEnd returns the offset just past the bracket closing a flow collection
+func (s *source) flowEnd(n *yaml.Node) (int, bool) {
+	i, ok := s.start(n)
+	if !ok || i >= len(s.src) || (s.src[i] != '{' && s.src[i] != '[') {
+		return 0, false
+	}
+	depth := 0
+	for ; i < len(s.src); i++ {
+		switch s.src[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth--; depth == 0 {
+				return i + 1, true
+			}
+		case '"', '\'':
+			quote := s.src[i]
+			for i++; i < len(s.src) && s.src[i] != quote; i++ {
+				if quote == '"' && s.src[i] == '\\' {
+					i++
+				}
+			}
+		case '#':
+			if s.src[i-1] == ' ' || s.src[i-1] == '\t' || s.src[i-1] == '\n' {
+				for i < len(s.src) && s.src[i] != '\n' {
+					i++
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// indicator returns the offset just past the ":" that follows a mapping key, or the
+// "-" that introduces a sequence entry, where a new value for the entry is written
+func (s *source) indicator(key, value *yaml.Node, flow bool) (int, bool) {
+	if key != nil {
+		i, ok := s.end(key, flow)
+		if !ok {
+			return 0, false
+		}
+		for i < len(s.src) && (s.src[i] == ' ' || s.src[i] == '\t') {
+			i++
+		}
+		if i == len(s.src) || s.src[i] != ':' {
+			return 0, false
+		}
+		return i + 1, true
+	}
+
+	i, ok := s.offset(value.Line, value.Column)
+	if !ok {
+		return 0, false
+	}
+	for i--; i >= 0 && (s.src[i] == ' ' || s.src[i] == '\t'); i-- {
+	}
+	if i < 0 || s.src[i] != '-' {
+		return 0, false
+	}
+	return i + 1, true
+}
+
+// encode writes nodes as block YAML, indenting every line after the first by the
+// given number of spaces
+func encode(n *yaml.Node, step int, indent string) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(step)
+	if err := encoder.Encode(n); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// scalarText writes a scalar as it appears in YAML on a single line: in its quoting
+// style when it has one and can keep it, plain when that reads back as the same
+// string, and double-quoted otherwise
+func scalarText(n *yaml.Node, flow bool) string {
+	if n.Kind == yaml.AliasNode {
+		return "*" + n.Value
+	}
+	if n.ShortTag() != "!!str" {
+		return n.Value
+	}
+	switch {
+	case n.Style&yaml.SingleQuotedStyle != 0 && !strings.ContainsAny(n.Value, "\r\n"):
+		return "'" + strings.ReplaceAll(n.Value, "'", "''") + "'"
+	case n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 && plainSafe(n.Value, flow):
+		return n.Value
+	}
+	return jsoncst.Quote(n.Value)
+}
+
+// plainSafe reports whether a string can be written as a plain scalar
+func plainSafe(s string, flow bool) bool {
+	if s == "" || strings.ContainsAny(s, "\r\n") || (flow && strings.ContainsAny(s, ",[]{}")) {
+		return false
+	}
+	var n yaml.Node
+	if yaml.Unmarshal([]byte(s), &n) != nil || len(n.Content) != 1 {
+		return false
+	}
+	value := n.Content[0]
+	return value.Kind == yaml.ScalarNode && value.ShortTag() == "!!str" && value.Style == 0 && value.Value == s
+}
@@ -0,0 +1,641 @@
+package yamldoc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"goldenMagic/internal/jsoncst"
+	"goldenMagic/internal/jsonpath"
+)
+
+// Changes describes what an update changed in the node tree
+type Changes struct {
+	Nodes   int      // values replaced and entries added, removed or moved
+	Renames []Rename // keys renamed, once for every key node
+}
+
+// Rename is a mapping key that was renamed
+type Rename struct {
+	From string
+	To   string
+}
+
+// Changed reports whether the update changed anything
+func (c Changes) Changed() bool {
+	return c.Nodes > 0 || len(c.Renames) > 0
+}
+
+// Update applies the differences between the JSON form of the document and
+// modified JSON, such as the result of an operation on JSON(), to the node tree and
+// reports what changed. Members are matched by key, so a renamed key keeps its
+// comments and its value; a member that disappears is removed with its comments.
+// An alias follows its anchor: a copy of it the edit leaves as it was, or changes
+// just like the anchor, takes the new value of the anchor, while a copy changed
+// on its own is an error, as that would change the anchor and every other alias.
+//
+// Every change is also recorded as an edit of the source at the position of the
+// node it changes, so String() leaves the text around it alone.
+func (d *Document) Update(modified string) (Changes, error) {
+	after, err := jsoncst.Parse(modified)
+	if err != nil {
+		return Changes{}, fmt.Errorf("error parsing JSON: %v", err)
+	}
+	u := &updater{after: after, src: newSource(d.Src), step: d.indent, root: d.value(), anchored: anchoredValues(d.Root)}
+	if err := u.update(d.value(), after.Root, nil, place{at: -1}); err != nil {
+		return Changes{}, err
+	}
+	// Nodes added by an earlier update have no position in the source
+	d.rewrite = d.rewrite || u.rewrite || (len(d.edits) > 0 && len(u.edits) > 0)
+	d.edits = append(d.edits, u.edits...)
+	return u.changes, nil
+}
+
+// updater applies the nodes of edited JSON to a YAML node tree and records the
+// matching edits of its source
+type updater struct {
+	after    *jsoncst.Document
+	src      *source
+	root     *yaml.Node
+	anchored map[*yaml.Node]any // value of every aliased anchor before the update
+	step     int                // indentation step for new block values
+	edits    []jsoncst.Edit
+	rewrite  bool // a change could not be located in the source
+	changes  Changes
+}
+
+// anchoredValues returns the value of every anchor that has an alias
+func anchoredValues(n *yaml.Node) map[*yaml.Node]any {
+	values := make(map[*yaml.Node]any)
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.AliasNode {
+			if _, ok := values[n.Alias]; !ok && n.Alias != nil {
+				values[n.Alias] = plain(n.Alias, true)
+			}
+			return
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(n)
+	return values
+}
+
+// value decodes a node of the edited JSON, keeping its numbers as their text
+func (u *updater) value(a *jsoncst.Node) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(u.after.Text(a)))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	return value, err
+}
+
+// place is where the value of a mapping member or sequence element is written
+type place struct {
+	at     int    // offset just past the ":" or "-" before the value, or -1
+	indent string // indentation of the lines of a block value
+	member bool   // the value of a mapping member rather than a sequence element
+	flow   bool   // inside a flow collection
+}
+
+// update makes a YAML node hold the value of a node of the edited JSON
+func (u *updater) update(n *yaml.Node, a *jsoncst.Node, path jsonpath.Path, p place) error {
+	want, err := u.value(a)
+	if err != nil {
+		return err
+	}
+	if equal(n, want) {
+		return nil
+	}
+	if n.Kind == yaml.AliasNode {
+		if reflect.DeepEqual(u.anchored[n.Alias], want) {
+			return nil
+		}
+		return fmt.Errorf("cannot change '%s' on its own: it is an alias of &%s, so change the anchor, or every copy of it alike", displayPath(path), n.Value)
+	}
+
+	// A block collection left empty becomes a flow one
+	flow := n.Style&yaml.FlowStyle != 0
+	switch {
+	case n.Kind == yaml.MappingNode && a.Kind == jsoncst.Object && (flow || len(a.Members) > 0):
+		return u.updateMapping(n, a, path, p)
+	case n.Kind == yaml.SequenceNode && a.Kind == jsoncst.Array && (flow || len(a.Elems) > 0):
+		return u.updateSequence(n, a, path, p)
+	}
+	u.replace(n, a, p)
+	return nil
+}
+
+// updateMapping matches the members of a mapping with those of an edited object.
+// Runs of keys that only one side has are renames when they are equally long, and
+// removals and insertions otherwise. Renames and changed values are edited in
+// place; when members are removed, added or moved the entries of a block mapping
+// are written again from their text, with their comments and the blank lines
+// between them.
+func (u *updater) updateMapping(n *yaml.Node, a *jsoncst.Node, path jsonpath.Path, p place) error {
+	pairs := len(n.Content) / 2
+	byKey := make(map[string]int, pairs)
+	for i := 0; i < pairs; i++ {
+		byKey[n.Content[2*i].Value] = i
+	}
+	inAfter := make(map[string]bool, len(a.Members))
+	for _, m := range a.Members {
+		inAfter[m.Key] = true
+	}
+
+	flow := n.Style&yaml.FlowStyle != 0
+	var chunks []chunk
+	if !flow {
+		chunks = u.chunks(pairs, n == u.root, func(i int) (int, *yaml.Node, bool) {
+			head, ok := u.src.offset(n.Content[2*i].Line, n.Content[2*i].Column)
+			return head, n.Content[2*i+1], ok
+		})
+	}
+	mark := len(u.edits)
+	moved := false
+
+	used := make([]bool, pairs)
+	var content []*yaml.Node
+	var items []item
+	i, j := 0, 0
+	for {
+		// Pairs up to the next one that is kept, including shadowed duplicate keys
+		var removed []int
+		for ; i < pairs; i++ {
+			if used[i] {
+				continue
+			}
+			key := n.Content[2*i].Value
+			if inAfter[key] && byKey[key] == i {
+				break
+			}
+			removed = append(removed, i)
+		}
+		var added []*jsoncst.Member
+		for ; j < len(a.Members); j++ {
+			if _, ok := byKey[a.Members[j].Key]; ok {
+				break
+			}
+			added = append(added, a.Members[j])
+		}
+
+		if len(removed) > 0 && len(removed) == len(added) {
+			for k, index := range removed {
+				key, value := n.Content[2*index], n.Content[2*index+1]
+				at := u.memberPlace(key, value, flow)
+				u.renameKey(key, added[k].Key, flow)
+				if err := u.update(value, added[k].Value, path.Child(added[k].Key), at); err != nil {
+					return err
+				}
+				content = append(content, key, value)
+				items = append(items, item{index: index})
+			}
+		} else {
+			for _, m := range added {
+				key, value := keyNode(m.Key), u.node(m.Value, flow)
+				content = append(content, key, value)
+				items = append(items, item{index: -1, node: &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{key, value}}})
+			}
+			u.changes.Nodes += len(removed) + len(added)
+			moved = moved || len(removed) > 0 || len(added) > 0
+		}
+
+		if j == len(a.Members) {
+			break
+		}
+		m := a.Members[j]
+		k := byKey[m.Key]
+		if k != i {
+			u.changes.Nodes++
+			moved = true
+		}
+		used[k] = true
+		key, value := n.Content[2*k], n.Content[2*k+1]
+		if err := u.update(value, m.Value, path.Child(m.Key), u.memberPlace(key, value, flow)); err != nil {
+			return err
+		}
+		content = append(content, key, value)
+		items = append(items, item{index: k})
+		j++
+	}
+
+	n.Content = content
+	switch {
+	case !moved:
+	case flow:
+		u.rewriteFlow(n, mark)
+	default:
+		u.rebuild(chunks, items, mark)
+	}
+	return nil
+}
+
+// updateSequence matches the elements of a sequence with those of an edited array:
+// position by position when their number is unchanged, and otherwise by keeping
+// the unchanged elements at both ends and replacing the ones in between
+func (u *updater) updateSequence(n *yaml.Node, a *jsoncst.Node, path jsonpath.Path, p place) error {
+	flow := n.Style&yaml.FlowStyle != 0
+	if len(n.Content) == len(a.Elems) {
+		for i, elem := range n.Content {
+			if err := u.update(elem, a.Elems[i], path.Elem(i), u.elemPlace(elem, flow)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var chunks []chunk
+	if !flow {
+		chunks = u.chunks(len(n.Content), n == u.root, func(i int) (int, *yaml.Node, bool) {
+			at, ok := u.src.indicator(nil, n.Content[i], false)
+			return at - 1, n.Content[i], ok
+		})
+	}
+
+	same := func(i, j int) bool {
+		value, err := u.value(a.Elems[j])
+		return err == nil && equal(n.Content[i], value)
+	}
+	prefix := 0
+	for prefix < len(n.Content) && prefix < len(a.Elems) && same(prefix, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(n.Content)-prefix && suffix < len(a.Elems)-prefix && same(len(n.Content)-1-suffix, len(a.Elems)-1-suffix) {
+		suffix++
+	}
+
+	var items []item
+	content := append([]*yaml.Node{}, n.Content[:prefix]...)
+	for i := 0; i < prefix; i++ {
+		items = append(items, item{index: i})
+	}
+	for _, elem := range a.Elems[prefix : len(a.Elems)-suffix] {
+		value := u.node(elem, flow)
+		content = append(content, value)
+		items = append(items, item{index: -1, node: &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{value}}})
+	}
+	for i := len(n.Content) - suffix; i < len(n.Content); i++ {
+		content = append(content, n.Content[i])
+		items = append(items, item{index: i})
+	}
+	u.changes.Nodes += len(n.Content) + len(a.Elems) - 2*(prefix+suffix)
+	n.Content = content
+
+	if flow {
+		u.rewriteFlow(n, len(u.edits))
+	} else {
+		u.rebuild(chunks, items, len(u.edits))
+	}
+	return nil
+}
+
+// replace gives a node the value of a node of the edited JSON, keeping its comments
+// and anchor, and the quoting of a string that stays a string. A scalar or flow
+// value is replaced where it stands; a block value is written again after the ":"
+// or "-" of its entry.
+func (u *updater) replace(n *yaml.Node, a *jsoncst.Node, p place) {
+	start, startOK := u.src.start(n)
+	end, endOK := u.src.end(n, p.flow)
+	inPlace := p.flow || n.Style&yaml.FlowStyle != 0 || (n.Kind == yaml.ScalarNode && !(n.Tag == "!!null" && n.Value == ""))
+
+	replacement := u.node(a, p.flow || n.Style&yaml.FlowStyle != 0)
+	replacement.HeadComment = n.HeadComment
+	replacement.LineComment = n.LineComment
+	replacement.FootComment = n.FootComment
+	replacement.Anchor = n.Anchor
+	if n.Kind == yaml.ScalarNode && n.ShortTag() == "!!str" && replacement.Tag == "!!str" && !strings.Contains(replacement.Value, "\n") {
+		replacement.Style = n.Style
+	}
+	*n = *replacement
+	u.changes.Nodes++
+
+	inline := n.Kind == yaml.ScalarNode || n.Style&yaml.FlowStyle != 0 || len(n.Content) == 0
+	if !startOK || !endOK || (!(inPlace && inline) && p.at < 0) {
+		u.rewrite = true
+		return
+	}
+	if inPlace && inline {
+		u.edits = append(u.edits, jsoncst.Edit{Start: start, End: end, Text: flowText(n, p.flow)})
+		return
+	}
+
+	text := ""
+	if n.Anchor != "" {
+		text = " &" + n.Anchor
+	}
+	if inline {
+		text += " " + flowText(n, false)
+	} else {
+		block, err := encode(&yaml.Node{Kind: n.Kind, Tag: n.Tag, Content: n.Content}, u.step, p.indent)
+		if err != nil {
+			u.rewrite = true
+			return
+		}
+		// A comment after the old value stays on the line of the entry
+		rest := u.src.src[end : u.src.lineStart(end)+len(u.src.text(u.src.line(end)))]
+		comment := strings.TrimSpace(rest)
+		if strings.HasPrefix(comment, "#") {
+			end += len(rest)
+			text += " " + comment
+		}
+		if p.member || strings.HasPrefix(comment, "#") {
+			text += "\n" + p.indent + block
+		} else {
+			text += " " + block
+		}
+	}
+	u.edits = append(u.edits, jsoncst.Edit{Start: p.at, End: end, Text: text})
+}
+
+// renameKey renames a mapping key and records the edit of its text
+func (u *updater) renameKey(key *yaml.Node, name string, flow bool) {
+	start, startOK := u.src.start(key)
+	end, endOK := u.src.end(key, flow)
+	u.changes.Renames = append(u.changes.Renames, Rename{From: key.Value, To: name})
+	renameKey(key, name)
+	if !startOK || !endOK {
+		u.rewrite = true
+		return
+	}
+	u.edits = append(u.edits, jsoncst.Edit{Start: start, End: end, Text: scalarText(key, flow)})
+}
+
+// memberPlace returns where the value of a mapping member is written
+func (u *updater) memberPlace(key, value *yaml.Node, flow bool) place {
+	if flow {
+		return place{at: -1, member: true, flow: true}
+	}
+	at, ok := u.src.indicator(key, value, false)
+	head, headOK := u.src.offset(key.Line, key.Column)
+	if !ok || !headOK {
+		return place{at: -1, member: true}
+	}
+	return place{at: at, indent: strings.Repeat(" ", head-u.src.lineStart(head)+u.step), member: true}
+}
+
+// elemPlace returns where a sequence element is written
+func (u *updater) elemPlace(elem *yaml.Node, flow bool) place {
+	if flow {
+		return place{at: -1, flow: true}
+	}
+	at, ok := u.src.indicator(nil, elem, false)
+	if !ok {
+		return place{at: -1}
+	}
+	return place{at: at, indent: strings.Repeat(" ", at-u.src.lineStart(at)+1)}
+}
+
+// chunk is the text of an entry of a block collection: the comment lines above it
+// and every line of its key and value
+type chunk struct {
+	start, end int
+	inline     bool // the entry starts on the line of its parent, e.g. "- key: value"
+}
+
+// item is an entry of a block collection after an update: one of its entries
+// before, or a new one written as the single entry of a node
+type item struct {
+	index int
+	node  *yaml.Node
+}
+
+// chunks locates the text of the entries of a block collection from the offset of
+// their key or "-" and their value, or returns nil if any of them cannot be located.
+// The comment lines above the first entry of the root are the header of the file
+// rather than part of the entry, so entries added in front go below them.
+func (u *updater) chunks(count int, root bool, entry func(i int) (head int, value *yaml.Node, ok bool)) []chunk {
+	s := u.src
+	chunks := make([]chunk, count)
+	for i := 0; i < count; i++ {
+		head, value, ok := entry(i)
+		if !ok {
+			return nil
+		}
+		end, ok := s.end(value, false)
+		if !ok {
+			return nil
+		}
+
+		c := chunk{start: s.lineStart(head), end: s.nextLine(end)}
+		if strings.TrimSpace(s.src[c.start:head]) != "" {
+			c.start, c.inline = head, true
+		} else if !root || i > 0 {
+			// Comment lines right above an entry, indented like it, belong to it
+			for line := s.line(head) - 1; line >= 0; line-- {
+				if s.indent(line) != head-c.start || !strings.HasPrefix(strings.TrimSpace(s.text(line)), "#") {
+					break
+				}
+				c.start = s.lines[line]
+			}
+		}
+		if i > 0 && c.start < chunks[i-1].end {
+			return nil
+		}
+		chunks[i] = c
+	}
+	return chunks
+}
+
+// rebuild writes the entries of a block collection again in their new order. Kept
+// entries keep their text, with the edits recorded for them since mark, and the
+// text before them; new entries are written like the first entry is indented.
+func (u *updater) rebuild(chunks []chunk, items []item, mark int) {
+	nested := append([]jsoncst.Edit{}, u.edits[mark:]...)
+	u.edits = u.edits[:mark]
+	if len(chunks) == 0 {
+		u.rewrite = true
+		return
+	}
+
+	s := u.src
+	first, last := chunks[0], chunks[len(chunks)-1]
+	indent := strings.Repeat(" ", s.indent(s.line(first.start)))
+	if first.inline {
+		indent = strings.Repeat(" ", first.start-s.lineStart(first.start))
+	}
+
+	var sb strings.Builder
+	for k, it := range items {
+		var text string
+		if it.index < 0 {
+			block, err := encode(it.node, u.step, indent)
+			if err != nil {
+				u.rewrite = true
+				return
+			}
+			text = indent + block + "\n"
+		} else {
+			c := chunks[it.index]
+			body, err := applyWithin(s.src, c.start, c.end, nested)
+			if err != nil {
+				u.rewrite = true
+				return
+			}
+			if c.inline {
+				body = indent + body
+			}
+			if !strings.HasSuffix(body, "\n") {
+				body += "\n"
+			}
+			if it.index > 0 {
+				body = s.src[chunks[it.index-1].end:c.start] + body
+			}
+			text = body
+		}
+		if k == 0 {
+			text = trimBlankLines(text)
+		}
+		sb.WriteString(text)
+	}
+
+	out := sb.String()
+	if first.inline {
+		out = strings.TrimPrefix(out, indent)
+		if strings.HasPrefix(out, "#") || strings.HasPrefix(out, " ") {
+			// A comment cannot follow the "-" of the parent entry
+			u.rewrite = true
+			return
+		}
+	}
+	if !strings.HasSuffix(s.src[first.start:last.end], "\n") {
+		out = strings.TrimSuffix(out, "\n")
+	}
+	u.edits = append(u.edits, jsoncst.Edit{Start: first.start, End: last.end, Text: out})
+}
+
+// rewriteFlow writes a flow collection again from its nodes, replacing the edits
+// recorded inside it since mark
+func (u *updater) rewriteFlow(n *yaml.Node, mark int) {
+	u.edits = u.edits[:mark]
+	start, startOK := u.src.start(n)
+	end, endOK := u.src.flowEnd(n)
+	if !startOK || !endOK {
+		u.rewrite = true
+		return
+	}
+	u.edits = append(u.edits, jsoncst.Edit{Start: start, End: end, Text: flowText(n, true)})
+}
+
+// applyWithin returns the text between two offsets of the source with the edits
+// that fall inside it applied
+func applyWithin(src string, start, end int, edits []jsoncst.Edit) (string, error) {
+	var within []jsoncst.Edit
+	for _, e := range edits {
+		if e.Start >= start && e.End <= end {
+			within = append(within, jsoncst.Edit{Start: e.Start - start, End: e.End - start, Text: e.Text})
+		}
+	}
+	return jsoncst.Apply(src[start:end], within)
+}
+
+// trimBlankLines removes the blank lines at the start of a text
+func trimBlankLines(text string) string {
+	for {
+		line, rest, found := strings.Cut(text, "\n")
+		if !found || strings.TrimSpace(line) != "" {
+			return text
+		}
+		text = rest
+	}
+}
+
+// flowText writes the value of a node, without its anchor, as it appears in a
+// flow collection or inline after the key or "-" of a block entry
+func flowText(n *yaml.Node, flow bool) string {
+	entry := func(n *yaml.Node) string {
+		if n.Anchor != "" {
+			return "&" + n.Anchor + " " + flowText(n, true)
+		}
+		return flowText(n, true)
+	}
+
+	var sb strings.Builder
+	switch n.Kind {
+	case yaml.MappingNode:
+		sb.WriteString("{")
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(scalarText(n.Content[i], true) + ": " + entry(n.Content[i+1]))
+		}
+		sb.WriteString("}")
+	case yaml.SequenceNode:
+		sb.WriteString("[")
+		for i, elem := range n.Content {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(entry(elem))
+		}
+		sb.WriteString("]")
+	default:
+		sb.WriteString(scalarText(n, flow))
+	}
+	return sb.String()
+}
+
+// node builds a YAML node from a node of the edited JSON. Containers are written
+// in flow style inside flow containers and in block style otherwise.
+func (u *updater) node(a *jsoncst.Node, flow bool) *yaml.Node {
+	var style yaml.Style
+	if flow {
+		style = yaml.FlowStyle
+	}
+
+	switch a.Kind {
+	case jsoncst.Object:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: style}
+		for _, m := range a.Members {
+			n.Content = append(n.Content, keyNode(m.Key), u.node(m.Value, flow))
+		}
+		return n
+	case jsoncst.Array:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: style}
+		for _, elem := range a.Elems {
+			n.Content = append(n.Content, u.node(elem, flow))
+		}
+		return n
+	case jsoncst.String:
+		value, _ := u.after.Value(a)
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value.(string)}
+	case jsoncst.Number:
+		text := u.after.Text(a)
+		tag := "!!int"
+		if strings.ContainsAny(text, ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: text}
+	case jsoncst.Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: u.after.Text(a)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
+
+// keyNode builds a mapping key
+func keyNode(key string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+}
+
+// renameKey renames a mapping key node, keeping its comments
+func renameKey(key *yaml.Node, name string) {
+	if key.ShortTag() != "!!str" {
+		key.Tag = "!!str"
+		key.Style = 0
+	}
+	key.Value = name
+}
+
+// displayPath formats a concrete path, writing the root as "$"
+func displayPath(path jsonpath.Path) string {
+	if path.IsRoot() {
+		return "$"
+	}
+	return path.String()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"log"
@@ -8,11 +9,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"goldenMagic/internal/api"
 	"goldenMagic/internal/config"
 	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/index"
+	"goldenMagic/internal/journal"
 	"goldenMagic/internal/jsonops"
 	"goldenMagic/internal/tree"
 
@@ -25,16 +30,30 @@ var frontendFiles embed.FS
 // App represents the main application
 type App struct {
 	config    *config.Config
+	journal   *journal.Journal
+	index     *index.Index
 	startTime time.Time
 	stats     *AppStats
+
+	// cancelSearch cancels the search started by the frontend that is still running,
+	// results holds the files found by the last completed search and query its query
+	searchMu     sync.Mutex
+	cancelSearch context.CancelFunc
+	results      []fileops.JSONFile
+	query        *fileops.Query
+
+	// restrictPaths refuses files outside the base paths, as in serve mode the API
+	// can be called by any client holding the token
+	restrictPaths bool
 }
 
-// AppStats tracks application usage statistics
+// AppStats tracks application usage statistics. Calls that do not write files run
+// concurrently, so the counters are atomic.
 type AppStats struct {
-	SearchOperations int
-	FilesProcessed   int
-	UpdateOperations int
-	Errors           int
+	SearchOperations atomic.Int64
+	FilesProcessed   atomic.Int64
+	UpdateOperations atomic.Int64
+	Errors           atomic.Int64
 }
 
 // NewApp creates a new application instance
@@ -44,11 +63,72 @@ func NewApp() (*App, error) {
 		return nil, fmt.Errorf("failed to load config: %v", err)
 	}
 
+	return NewAppWithConfig(cfg), nil
+}
+
+// NewAppWithConfig creates a new application instance for an existing configuration
+func NewAppWithConfig(cfg *config.Config) *App {
+	// The journal is optional; without it operations still work but cannot be undone
+	j, err := journal.Open(config.GetJournalDir())
+	if err != nil {
+		log.Printf("⚠️ Undo journal disabled: %v", err)
+	}
+
+	// Without the index, key filters are answered by parsing every file
+	ix, err := index.Open(config.GetIndexDir(), fileops.Syntaxes{LenientExtensions: cfg.LenientExtensions})
+	if err != nil {
+		log.Printf("⚠️ Search index disabled: %v", err)
+	}
+
 	return &App{
 		config:    cfg,
+		journal:   j,
+		index:     ix,
 		startTime: time.Now(),
 		stats:     &AppStats{},
-	}, nil
+	}
+}
+
+// Bindings lists the App methods exposed to the frontend, both as Lorca bindings
+// and as routes of the HTTP API in serve mode. Methods that may write files are
+// marked, so that the API runs them one at a time.
+func (a *App) Bindings() []api.Binding {
+	return []api.Binding{
+		{Name: "browseFolder", Route: "search", Func: a.BrowseFolder},
+		{Name: "searchValues", Route: "search/values", Func: a.SearchValues},
+		{Name: "getJSONFileContent", Route: "files/content", Func: a.GetJSONFileContent},
+		{Name: "addJSONItemToFiles", Route: "operations/add", Func: a.AddJSONItemToFiles, Mutates: true},
+		{Name: "addJSONItemAfter", Route: "operations/insert-after", Func: a.AddJSONItemAfter, Mutates: true},
+		{Name: "analyzeObjectPath", Route: "operations/add/candidates", Func: a.AnalyzeObjectPath},
+		{Name: "analyzeTargetKey", Route: "operations/insert-after/candidates", Func: a.AnalyzeTargetKey},
+		{Name: "replaceKeys", Route: "operations/replace-key", Func: a.ReplaceKeys, Mutates: true},
+		{Name: "convertKeyCase", Route: "operations/convert-case", Func: a.ConvertKeyCase, Mutates: true},
+		{Name: "deleteJSONKeys", Route: "operations/delete-key", Func: a.DeleteJSONKeys, Mutates: true},
+		{Name: "setJSONValues", Route: "operations/set-value", Func: a.SetJSONValues, Mutates: true},
+		{Name: "applyJSONPatch", Route: "operations/patch", Func: a.ApplyJSONPatch, Mutates: true},
+		{Name: "mergePatchFiles", Route: "operations/merge-patch", Func: a.MergePatchFiles, Mutates: true},
+		{Name: "commitChanges", Route: "operations/commit", Func: a.CommitChanges, Mutates: true},
+		{Name: "undoLastOperation", Route: "journal/undo-last", Func: a.UndoLastOperation, Mutates: true},
+		{Name: "undoOperation", Route: "journal/undo", Func: a.UndoOperation, Mutates: true},
+		{Name: "getOperationHistory", Route: "journal/history", Func: a.GetOperationHistory},
+		{Name: "getKeyCatalog", Route: "search/keys", Func: a.GetKeyCatalog},
+		{Name: "completeKeyPath", Route: "search/complete", Func: a.CompleteKeyPath},
+		{Name: "getBasePaths", Route: "base-paths", Func: a.GetBasePaths},
+	}
+}
+
+// newServeMux serves the embedded frontend together with the JSON API used in serve
+// mode. The frontend is marked with a cookie, so that it asks for the token and calls
+// the API instead of the Lorca bindings; the token itself is never part of a URL.
+func newServeMux(app *App, token string) *http.ServeMux {
+	files := http.FileServer(http.FS(frontendFiles))
+	mux := http.NewServeMux()
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "goldenMagicServe", Value: "1", Path: "/", SameSite: http.SameSiteStrictMode})
+		files.ServeHTTP(w, r)
+	}))
+	mux.Handle("/api/", api.NewHandler(app.Bindings(), token))
+	return mux
 }
 
 // logOperation logs an operation with timing and context
@@ -56,7 +136,7 @@ func (a *App) logOperation(operation string, duration time.Duration, err error,
 	level := "INFO"
 	if err != nil {
 		level = "ERROR"
-		a.stats.Errors++
+		a.stats.Errors.Add(1)
 	}
 
 	log.Printf("[%s] %s completed in %v | Details: %+v | Error: %v",
@@ -64,6 +144,11 @@ func (a *App) logOperation(operation string, duration time.Duration, err error,
 }
 
 func main() {
+	// Any arguments select the headless command-line interface
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	app, err := NewApp()
 	if err != nil {
 		log.Fatal("Failed to initialize app:", err)
@@ -95,12 +180,11 @@ func main() {
 	log.Printf("🖥️  UI initialized successfully")
 
 	// Bind Go functions to JavaScript
-	ui.Bind("browseFolder", app.BrowseFolder)
-	ui.Bind("getJSONFileContent", app.GetJSONFileContent)
-	ui.Bind("addJSONItemToFiles", app.AddJSONItemToFiles)
-	ui.Bind("addJSONItemAfter", app.AddJSONItemAfter)
-	ui.Bind("replaceKeys", app.ReplaceKeys)
-	ui.Bind("getBasePaths", app.GetBasePaths)
+	for _, b := range app.Bindings() {
+		if err := ui.Bind(b.Name, b.Func); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Wait for interrupt signal
 	c := make(chan os.Signal, 1)
@@ -116,26 +200,93 @@ func main() {
 	uptime := time.Since(app.startTime)
 	log.Printf("📊 Session Statistics:")
 	log.Printf("   ⏱️  Uptime: %v", uptime)
-	log.Printf("   🔍 Search operations: %d", app.stats.SearchOperations)
-	log.Printf("   📄 Files processed: %d", app.stats.FilesProcessed)
-	log.Printf("   ✏️  Update operations: %d", app.stats.UpdateOperations)
-	log.Printf("   ❌ Errors encountered: %d", app.stats.Errors)
+	log.Printf("   🔍 Search operations: %d", app.stats.SearchOperations.Load())
+	log.Printf("   📄 Files processed: %d", app.stats.FilesProcessed.Load())
+	log.Printf("   ✏️  Update operations: %d", app.stats.UpdateOperations.Load())
+	log.Printf("   ❌ Errors encountered: %d", app.stats.Errors.Load())
 
 	log.Println("👋 Exiting goldenMagic...")
 }
 
-// BrowseFolder searches for files across all configured base paths and returns a unified tree structure
+// BrowseFolder searches for files across all configured base paths and returns a unified tree structure.
+// The JSON key filter is a search query (see fileops.Query), e.g. `errorCode && httpStatus == 200`.
+// Starting a new search cancels the previous one if it is still running.
 func (a *App) BrowseFolder(extensionFilter, jsonKeyFilter string) (*tree.FileTreeNode, error) {
+	ctx, cancel := a.startSearch()
+	defer cancel()
+
+	return a.BrowseFolderContext(ctx, extensionFilter, jsonKeyFilter)
+}
+
+// BrowseFolderContext is BrowseFolder with a context that can cancel the search
+func (a *App) BrowseFolderContext(ctx context.Context, extensionFilter, jsonKeyFilter string) (*tree.FileTreeNode, error) {
+	start := time.Now()
+	a.stats.SearchOperations.Add(1)
+
+	query, err := fileops.ParseQuery(jsonKeyFilter)
+	if err != nil {
+		err = fmt.Errorf("invalid search query: %v", err)
+		a.logOperation("BrowseFolder", time.Since(start), err, map[string]any{
+			"extensionFilter": extensionFilter,
+			"jsonKeyFilter":   jsonKeyFilter,
+		})
+		return nil, err
+	}
+
+	return a.browse(ctx, "BrowseFolder", start, extensionFilter, query)
+}
+
+// SearchValues searches for files containing a string, number or boolean value that
+// matches the search, optionally only under the given keys. Each file in the tree
+// lists the paths of its matching values.
+func (a *App) SearchValues(extensionFilter string, search fileops.ValueSearch) (*tree.FileTreeNode, error) {
+	ctx, cancel := a.startSearch()
+	defer cancel()
+
+	return a.SearchValuesContext(ctx, extensionFilter, search)
+}
+
+// SearchValuesContext is SearchValues with a context that can cancel the search
+func (a *App) SearchValuesContext(ctx context.Context, extensionFilter string, search fileops.ValueSearch) (*tree.FileTreeNode, error) {
 	start := time.Now()
-	a.stats.SearchOperations++
+	a.stats.SearchOperations.Add(1)
+
+	query, err := search.Query()
+	if err != nil {
+		a.logOperation("SearchValues", time.Since(start), err, map[string]any{
+			"extensionFilter": extensionFilter,
+			"valueSearch":     search.String(),
+		})
+		return nil, err
+	}
+
+	return a.browse(ctx, "SearchValues", start, extensionFilter, query)
+}
+
+// startSearch cancels the search started by the frontend that is still running and
+// returns the context of a new one
+func (a *App) startSearch() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	a.searchMu.Lock()
+	if a.cancelSearch != nil {
+		a.cancelSearch()
+	}
+	a.cancelSearch = cancel
+	a.searchMu.Unlock()
+
+	return ctx, cancel
+}
 
+// browse runs a search over the valid base paths and builds the result tree
+func (a *App) browse(ctx context.Context, operation string, start time.Time, extensionFilter string, query *fileops.Query) (*tree.FileTreeNode, error) {
 	// Get only valid base paths
 	validBasePaths := a.config.GetValidBasePaths()
 	if len(validBasePaths) == 0 {
 		err := fmt.Errorf("no valid base paths configured")
-		a.logOperation("BrowseFolder", time.Since(start), err, map[string]any{
+		a.logOperation(operation, time.Since(start), err, map[string]any{
 			"extensionFilter": extensionFilter,
-			"jsonKeyFilter":   jsonKeyFilter,
+			"query":           query.String(),
 		})
 		return &tree.FileTreeNode{
 			Name:  "No Valid Paths",
@@ -144,21 +295,26 @@ func (a *App) BrowseFolder(extensionFilter, jsonKeyFilter string) (*tree.FileTre
 		}, err
 	}
 
-	files, err := fileops.BrowseFolders(validBasePaths, extensionFilter, jsonKeyFilter)
+	files, err := a.searchFiles(ctx, validBasePaths, extensionFilter, query)
 	if err != nil {
-		a.logOperation("BrowseFolder", time.Since(start), err, map[string]any{
+		a.logOperation(operation, time.Since(start), err, map[string]any{
 			"extensionFilter": extensionFilter,
-			"jsonKeyFilter":   jsonKeyFilter,
+			"query":           query.String(),
 			"basePaths":       validBasePaths,
 		})
 		return nil, fmt.Errorf("error browsing folders: %v", err)
 	}
 
+	a.searchMu.Lock()
+	a.results = files
+	a.query = query
+	a.searchMu.Unlock()
+
 	result := tree.BuildFileTreeFromMultiplePaths(files, validBasePaths)
 
-	a.logOperation("BrowseFolder", time.Since(start), nil, map[string]any{
+	a.logOperation(operation, time.Since(start), nil, map[string]any{
 		"extensionFilter": extensionFilter,
-		"jsonKeyFilter":   jsonKeyFilter,
+		"query":           query.String(),
 		"filesFound":      len(files),
 		"basePaths":       len(validBasePaths),
 	})
@@ -166,13 +322,98 @@ func (a *App) BrowseFolder(extensionFilter, jsonKeyFilter string) (*tree.FileTre
 	return result, nil
 }
 
+// searchFiles lists the files matching the filters. Queries that only check for a
+// key or member path are answered from the index; all others parse every candidate.
+func (a *App) searchFiles(ctx context.Context, basePaths []string, extensionFilter string, query *fileops.Query) ([]fileops.JSONFile, error) {
+	key, indexable := query.KeyFilter()
+	if !indexable || a.index == nil {
+		return fileops.BrowseFolders(ctx, basePaths, extensionFilter, query, a.scanOptions())
+	}
+
+	files, err := fileops.BrowseFolders(ctx, basePaths, extensionFilter, nil, a.scanOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	matched, stats, err := a.index.Filter(ctx, files, key)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🗂️ Index: %d files looked up, %d re-parsed", stats.Files, stats.Reparsed)
+
+	// The index knows which files match but not where, so the matches of the
+	// narrowed set are located like those of a full scan
+	return fileops.FilterByQuery(ctx, matched, query, a.syntaxes())
+}
+
+// GetKeyCatalog returns every distinct key and member path in the results of the
+// last search, each with the number of files containing it
+func (a *App) GetKeyCatalog() (*index.Catalog, error) {
+	start := time.Now()
+
+	a.searchMu.Lock()
+	files := a.results
+	a.searchMu.Unlock()
+
+	catalog, err := a.keyCatalog(context.Background(), files)
+	a.logOperation("GetKeyCatalog", time.Since(start), err, map[string]any{
+		"files": len(files),
+	})
+	return catalog, err
+}
+
+// CompleteKeyPath suggests keys and member paths of the last search results that
+// start with the prefix, most common first. The kind is "key", "path" or "" for both.
+func (a *App) CompleteKeyPath(prefix, kind string, limit int) ([]index.Term, error) {
+	if kind != "" && kind != index.KindKey && kind != index.KindPath {
+		return nil, fmt.Errorf("invalid completion kind %q: use key, path or leave it empty", kind)
+	}
+
+	catalog, err := a.GetKeyCatalog()
+	if err != nil {
+		return nil, err
+	}
+	return catalog.Complete(prefix, kind, limit), nil
+}
+
+// keyCatalog builds the catalog of the files from the search index, or by parsing
+// them when the index is disabled
+func (a *App) keyCatalog(ctx context.Context, files []fileops.JSONFile) (*index.Catalog, error) {
+	if a.index == nil {
+		return index.BuildCatalog(ctx, files, a.syntaxes())
+	}
+	catalog, _, err := a.index.Catalog(ctx, files)
+	return catalog, err
+}
+
+// scanOptions returns the exclusions configured for scanning the base paths
+func (a *App) scanOptions() fileops.ScanOptions {
+	return fileops.ScanOptions{
+		Exclude:         a.config.Exclude,
+		BasePathExclude: a.config.BasePathExclude,
+		Gitignore:       a.config.Gitignore,
+		IncludeHidden:   a.config.IncludeHidden,
+		Syntaxes:        a.syntaxes(),
+	}
+}
+
+// syntaxes returns the syntax files are parsed in: files with the configured
+// extensions may contain comments and trailing commas
+func (a *App) syntaxes() fileops.Syntaxes {
+	return fileops.Syntaxes{LenientExtensions: a.config.LenientExtensions}
+}
+
 // GetJSONFileContent returns the content of a JSON file
 func (a *App) GetJSONFileContent(filePath string) (string, error) {
+	if err := a.checkPaths(filePath); err != nil {
+		return "", err
+	}
+
 	start := time.Now()
 
 	log.Printf("📖 Loading file content: %s", filePath)
 
-	content, err := fileops.GetJSONFileContent(filePath)
+	content, err := fileops.GetJSONFileContent(filePath, a.syntaxes())
 
 	a.logOperation("GetJSONFileContent", time.Since(start), err, map[string]any{
 		"filePath":      filePath,
@@ -188,100 +429,231 @@ func (a *App) GetJSONFileContent(filePath string) (string, error) {
 	return content, nil
 }
 
-// AddJSONItemToFiles adds a JSON item to multiple files
-func (a *App) AddJSONItemToFiles(filePaths []string, objectPath, key string, value any) map[string]string {
-	results := make(map[string]string)
+// AddJSONItemToFiles adds a JSON item to multiple files. The occurrence selects which
+// objects to add to when the object path matches several. With dryRun set the files
+// are left untouched and each result carries the change that would be made.
+func (a *App) AddJSONItemToFiles(filePaths []string, objectPath, key string, value any, occurrence jsonops.Occurrence, dryRun bool) ([]jsonops.AddItemResult, error) {
+	if err := a.checkPaths(filePaths...); err != nil {
+		return nil, err
+	}
 
-	for _, filePath := range filePaths {
-		// Read existing file
-		content, err := fileops.ReadFile(filePath)
-		if err != nil {
-			results[filePath] = "ERROR: error reading file: " + err.Error()
-			continue
-		}
+	start := time.Now()
+	a.stats.UpdateOperations.Add(1)
+
+	request := jsonops.AddItemRequest{
+		ObjectPath:    objectPath,
+		Key:           key,
+		Value:         value,
+		Occurrence:    occurrence,
+		SelectedFiles: filePaths,
+		DryRun:        true, // the changes are written by writeResults
+		Syntaxes:      a.syntaxes(),
+	}
+
+	results, err := jsonops.AddItemInFiles(request)
+	if err != nil {
+		a.logOperation("AddJSONItemToFiles", time.Since(start), err, map[string]any{
+			"objectPath": objectPath,
+			"key":        key,
+		})
+		return nil, err
+	}
 
-		// Insert the JSON key-value pair while preserving structure
-		updatedContent, err := jsonops.InsertJSONKeyValue(string(content), objectPath, key, value)
-		if err != nil {
-			results[filePath] = "ERROR: error inserting JSON: " + err.Error()
-			continue
+	if !dryRun {
+		if err := writeResults(a, "AddJSONItemToFiles", results); err != nil {
+			return nil, err
 		}
+	}
+
+	a.logOperation("AddJSONItemToFiles", time.Since(start), nil, a.addItemStats(results, map[string]any{
+		"objectPath": objectPath,
+		"key":        key,
+		"occurrence": occurrence.String(),
+		"dryRun":     dryRun,
+	}))
+
+	return results, nil
+}
+
+// AddJSONItemAfter adds a complete JSON object after a target object in specified files.
+// The occurrence selects which occurrences of the target key to insert after.
+func (a *App) AddJSONItemAfter(filePaths []string, targetKey, newObjectKey, newObjectJSON string, occurrence jsonops.Occurrence, dryRun bool) ([]jsonops.AddItemResult, error) {
+	if err := a.checkPaths(filePaths...); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	a.stats.UpdateOperations.Add(1)
+
+	request := jsonops.InsertAfterRequest{
+		TargetKey:     targetKey,
+		NewObjectKey:  newObjectKey,
+		NewObjectJSON: newObjectJSON,
+		Occurrence:    occurrence,
+		SelectedFiles: filePaths,
+		DryRun:        true, // the changes are written by writeResults
+		Syntaxes:      a.syntaxes(),
+	}
+
+	results, err := jsonops.InsertAfterInFiles(request)
+	if err != nil {
+		a.logOperation("AddJSONItemAfter", time.Since(start), err, map[string]any{
+			"targetKey":    targetKey,
+			"newObjectKey": newObjectKey,
+		})
+		return nil, err
+	}
 
-		// Write updated content back to file
-		err = fileops.WriteFile(filePath, []byte(updatedContent))
-		if err != nil {
-			results[filePath] = "ERROR: error writing file: " + err.Error()
-			continue
+	if !dryRun {
+		if err := writeResults(a, "AddJSONItemAfter", results); err != nil {
+			return nil, err
 		}
+	}
+
+	a.logOperation("AddJSONItemAfter", time.Since(start), nil, a.addItemStats(results, map[string]any{
+		"targetKey":    targetKey,
+		"newObjectKey": newObjectKey,
+		"occurrence":   occurrence.String(),
+		"dryRun":       dryRun,
+	}))
+
+	return results, nil
+}
 
-		results[filePath] = "SUCCESS"
+// AnalyzeObjectPath lists, per file, every object or array the object path selects,
+// marking the ones AddJSONItemToFiles would change with the occurrence
+func (a *App) AnalyzeObjectPath(filePaths []string, objectPath string, occurrence jsonops.Occurrence) ([]jsonops.FileCandidates, error) {
+	if err := a.checkPaths(filePaths...); err != nil {
+		return nil, err
 	}
 
-	return results
+	start := time.Now()
+
+	results := jsonops.FindCandidatesInFiles(filePaths, func(jsonStr string) ([]jsonops.Candidate, error) {
+		return jsonops.ObjectPathCandidates(jsonStr, objectPath, occurrence)
+	})
+
+	a.logOperation("AnalyzeObjectPath", time.Since(start), nil, candidateStats(results, map[string]any{
+		"objectPath": objectPath,
+		"occurrence": occurrence.String(),
+	}))
+	return results, nil
 }
 
-// AddJSONObjectAfter adds a complete JSON object after a target object in specified files
-func (a *App) AddJSONItemAfter(filePaths []string, targetKey, newObjectKey, newObjectJSON string) map[string]string {
+// AnalyzeTargetKey lists, per file, every occurrence of the target key, marking the
+// ones AddJSONItemAfter would insert after with the occurrence
+func (a *App) AnalyzeTargetKey(filePaths []string, targetKey string, occurrence jsonops.Occurrence) ([]jsonops.FileCandidates, error) {
+	if err := a.checkPaths(filePaths...); err != nil {
+		return nil, err
+	}
+
 	start := time.Now()
-	a.stats.UpdateOperations++
 
-	results := make(map[string]string)
+	if targetKey == "" {
+		err := fmt.Errorf("target key cannot be empty")
+		a.logOperation("AnalyzeTargetKey", time.Since(start), err, map[string]any{"targetKey": targetKey})
+		return nil, err
+	}
 
-	for _, filePath := range filePaths {
-		a.stats.FilesProcessed++
+	results := jsonops.FindCandidatesInFiles(filePaths, func(jsonStr string) ([]jsonops.Candidate, error) {
+		return jsonops.TargetKeyCandidates(jsonStr, targetKey, occurrence)
+	})
 
-		// Read the file
-		content, err := fileops.ReadFile(filePath)
-		if err != nil {
-			results[filePath] = fmt.Sprintf("ERROR: reading file: %v", err)
-			continue
-		}
+	a.logOperation("AnalyzeTargetKey", time.Since(start), nil, candidateStats(results, map[string]any{
+		"targetKey":  targetKey,
+		"occurrence": occurrence.String(),
+	}))
+	return results, nil
+}
 
-		// Insert the new object after the target
-		updatedContent, err := jsonops.InsertItemAfter(string(content), targetKey, newObjectKey, newObjectJSON)
-		if err != nil {
-			// Check if it's a duplicate key error
-			if strings.Contains(err.Error(), "already exists") {
-				results[filePath] = fmt.Sprintf("SKIPPED: %v", err)
-			} else {
-				results[filePath] = fmt.Sprintf("ERROR: inserting object: %v", err)
-			}
-			continue
+// candidateStats counts ambiguous and failed files of an analysis into the log details
+func candidateStats(results []jsonops.FileCandidates, details map[string]any) map[string]any {
+	ambiguous := 0
+	failed := 0
+	for _, result := range results {
+		if result.Ambiguous {
+			ambiguous++
 		}
-
-		// Write back to file
-		err = fileops.WriteFile(filePath, []byte(updatedContent))
-		if err != nil {
-			results[filePath] = fmt.Sprintf("ERROR: writing file: %v", err)
-			continue
+		if result.Error != "" {
+			failed++
 		}
-
-		results[filePath] = "SUCCESS"
 	}
+	details["files"] = len(results)
+	details["ambiguousFiles"] = ambiguous
+	details["errorFiles"] = failed
+	return details
+}
 
+// addItemStats counts the outcomes of an add operation into the log details
+func (a *App) addItemStats(results []jsonops.AddItemResult, details map[string]any) map[string]any {
 	successCount := 0
 	skippedCount := 0
 	errorCount := 0
 	for _, result := range results {
-		if strings.HasPrefix(result, "SUCCESS") {
+		a.stats.FilesProcessed.Add(1)
+		if result.Success {
 			successCount++
-		} else if strings.HasPrefix(result, "SKIPPED") {
+		} else if result.Skipped {
 			skippedCount++
 		} else {
 			errorCount++
 		}
 	}
 
-	a.logOperation("AddJSONItemAfter", time.Since(start), nil, map[string]any{
-		"targetKey":      targetKey,
-		"newObjectKey":   newObjectKey,
-		"filesProcessed": len(filePaths),
+	details["filesProcessed"] = len(results)
+	details["successCount"] = successCount
+	details["skippedCount"] = skippedCount
+	details["errorCount"] = errorCount
+	return details
+}
+
+// CommitChanges writes changes previously returned by a dry run. Files that changed
+// since the preview are refused.
+func (a *App) CommitChanges(changes []jsonops.FileChange) ([]jsonops.CommitResult, error) {
+	for _, change := range changes {
+		if err := a.checkPaths(change.FilePath); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+
+	results, err := a.commitChanges("CommitChanges", changes)
+	if err != nil {
+		a.logOperation("CommitChanges", time.Since(start), err, map[string]any{
+			"filesProcessed": len(changes),
+		})
+		return nil, err
+	}
+
+	successCount := 0
+	for _, result := range results {
+		if result.Success {
+			successCount++
+		}
+	}
+
+	a.logOperation("CommitChanges", time.Since(start), nil, map[string]any{
+		"filesProcessed": len(changes),
 		"successCount":   successCount,
-		"skippedCount":   skippedCount,
-		"errorCount":     errorCount,
+		"errorCount":     len(results) - successCount,
 	})
 
-	return results
+	return results, nil
+}
+
+// checkPaths returns an error naming the first file outside the base paths when
+// paths are restricted
+func (a *App) checkPaths(filePaths ...string) error {
+	if !a.restrictPaths {
+		return nil
+	}
+	for _, filePath := range filePaths {
+		if !a.config.Contains(filePath) {
+			return fmt.Errorf("file '%s' is outside the base paths", filePath)
+		}
+	}
+	return nil
 }
 
 // GetBasePaths returns all configured base paths
@@ -317,14 +689,23 @@ func (a *App) GetBasePathInfo() (map[string]interface{}, error) {
 	return info, nil
 }
 
-// ReplaceKeys replaces old keys with new keys in selected files using string replacement
-func (a *App) ReplaceKeys(oldKey, newKey string, selectedFiles []string) ([]jsonops.ReplaceKeyResult, error) {
-	log.Printf("🔄 Starting key replace operation: oldKey=%s, newKey=%s, files=%d", oldKey, newKey, len(selectedFiles))
+// ReplaceKeys renames old keys to new keys in selected files, only within the scope
+// when one is given. Files where the new key already exists next to an old one fail
+// with the conflicting objects listed.
+func (a *App) ReplaceKeys(oldKey, newKey string, selectedFiles []string, scope jsonops.KeyScope, dryRun bool) ([]jsonops.ReplaceKeyResult, error) {
+	if err := a.checkPaths(selectedFiles...); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔄 Starting key replace operation: oldKey=%s, newKey=%s, scope=%s, files=%d", oldKey, newKey, scope, len(selectedFiles))
 
 	request := jsonops.ReplaceKeyRequest{
 		OldKey:        oldKey,
 		NewKey:        newKey,
+		Scope:         scope,
 		SelectedFiles: selectedFiles,
+		DryRun:        true, // the changes are written by writeResults
+		Syntaxes:      a.syntaxes(),
 	}
 
 	results, err := jsonops.ReplaceKeyInFiles(request)
@@ -333,6 +714,12 @@ func (a *App) ReplaceKeys(oldKey, newKey string, selectedFiles []string) ([]json
 		return nil, err
 	}
 
+	if !dryRun {
+		if err := writeResults(a, "ReplaceKeys", results); err != nil {
+			return nil, err
+		}
+	}
+
 	successCount := 0
 	totalReplacements := 0
 	for _, result := range results {
@@ -347,3 +734,405 @@ func (a *App) ReplaceKeys(oldKey, newKey string, selectedFiles []string) ([]json
 
 	return results, nil
 }
+
+// DeleteJSONKeys removes the members selected by a path expression from the selected files
+func (a *App) DeleteJSONKeys(keyPath string, selectedFiles []string, dryRun bool) ([]jsonops.DeleteKeyResult, error) {
+	if err := a.checkPaths(selectedFiles...); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🗑️ Starting key delete operation: keyPath=%s, files=%d", keyPath, len(selectedFiles))
+
+	request := jsonops.DeleteKeyRequest{
+		KeyPath:       keyPath,
+		SelectedFiles: selectedFiles,
+		DryRun:        true, // the changes are written by writeResults
+		Syntaxes:      a.syntaxes(),
+	}
+
+	results, err := jsonops.DeleteKeysInFiles(request)
+	if err != nil {
+		log.Printf("❌ Delete operation failed: %v", err)
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := writeResults(a, "DeleteJSONKeys", results); err != nil {
+			return nil, err
+		}
+	}
+
+	successCount := 0
+	totalDeletions := 0
+	for _, result := range results {
+		if result.Success {
+			successCount++
+			totalDeletions += result.DeletedCount
+		}
+	}
+
+	log.Printf("✅ Delete operation completed: %d/%d files successful, %d total deletions",
+		successCount, len(selectedFiles), totalDeletions)
+
+	return results, nil
+}
+
+// ConvertKeyCase converts the key names in the selected files to a naming convention
+// (camel, snake, kebab or pascal), only inside the values at the path when one is
+// given. A non-empty allow list limits the conversion to those keys and the deny list
+// excludes keys. Files where converted keys would collide are left unchanged.
+func (a *App) ConvertKeyCase(keyCase, path string, allow, deny []string, selectedFiles []string, dryRun bool) ([]jsonops.ConvertKeysResult, error) {
+	if err := a.checkPaths(selectedFiles...); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	a.stats.UpdateOperations.Add(1)
+
+	request := jsonops.ConvertKeysRequest{
+		KeyCaseOptions: jsonops.KeyCaseOptions{
+			Case:  jsonops.KeyCase(keyCase),
+			Path:  path,
+			Allow: allow,
+			Deny:  deny,
+		},
+		SelectedFiles: selectedFiles,
+		DryRun:        true, // the changes are written by writeResults
+		Syntaxes:      a.syntaxes(),
+	}
+
+	results, err := jsonops.ConvertKeysInFiles(request)
+	if err != nil {
+		a.logOperation("ConvertKeyCase", time.Since(start), err, map[string]any{
+			"case": keyCase,
+			"path": path,
+		})
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := writeResults(a, "ConvertKeyCase", results); err != nil {
+			return nil, err
+		}
+	}
+
+	successCount := 0
+	collisionCount := 0
+	totalRenames := 0
+	for _, result := range results {
+		if result.Success {
+			successCount++
+			totalRenames += result.ReplacementCount
+		}
+		if len(result.Collisions) > 0 {
+			collisionCount++
+		}
+	}
+
+	a.logOperation("ConvertKeyCase", time.Since(start), nil, map[string]any{
+		"case":           keyCase,
+		"path":           path,
+		"files":          len(selectedFiles),
+		"successCount":   successCount,
+		"collisionFiles": collisionCount,
+		"renames":        totalRenames,
+		"dryRun":         dryRun,
+	})
+
+	return results, nil
+}
+
+// SetJSONValues sets the value at a path in the selected files. The mode is one of
+// "missing" (only add), "update" (only change existing values) or "upsert" (both).
+func (a *App) SetJSONValues(keyPath string, value any, mode string, selectedFiles []string, dryRun bool) ([]jsonops.SetValueResult, error) {
+	if err := a.checkPaths(selectedFiles...); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	a.stats.UpdateOperations.Add(1)
+
+	request := jsonops.SetValueRequest{
+		KeyPath:       keyPath,
+		Value:         value,
+		Mode:          jsonops.SetMode(mode),
+		SelectedFiles: selectedFiles,
+		DryRun:        true, // the changes are written by writeResults
+		Syntaxes:      a.syntaxes(),
+	}
+
+	results, err := jsonops.SetValuesInFiles(request)
+	if err != nil {
+		a.logOperation("SetJSONValues", time.Since(start), err, map[string]any{
+			"keyPath": keyPath,
+			"mode":    mode,
+		})
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := writeResults(a, "SetJSONValues", results); err != nil {
+			return nil, err
+		}
+	}
+
+	successCount := 0
+	skippedCount := 0
+	errorCount := 0
+	for _, result := range results {
+		a.stats.FilesProcessed.Add(1)
+		if result.Success {
+			successCount++
+		} else if result.Skipped {
+			skippedCount++
+		} else {
+			errorCount++
+		}
+	}
+
+	a.logOperation("SetJSONValues", time.Since(start), nil, map[string]any{
+		"keyPath":        keyPath,
+		"mode":           mode,
+		"filesProcessed": len(selectedFiles),
+		"successCount":   successCount,
+		"skippedCount":   skippedCount,
+		"errorCount":     errorCount,
+	})
+
+	return results, nil
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch document to the selected files
+func (a *App) ApplyJSONPatch(patchJSON string, selectedFiles []string, dryRun bool) ([]jsonops.PatchResult, error) {
+	if err := a.checkPaths(selectedFiles...); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	a.stats.UpdateOperations.Add(1)
+
+	request := jsonops.PatchRequest{
+		Patch:         patchJSON,
+		SelectedFiles: selectedFiles,
+		DryRun:        true, // the changes are written by writeResults
+		Syntaxes:      a.syntaxes(),
+	}
+
+	results, err := jsonops.ApplyJSONPatch(request)
+	if err != nil {
+		a.logOperation("ApplyJSONPatch", time.Since(start), err, map[string]any{
+			"files": len(selectedFiles),
+		})
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := writeResults(a, "ApplyJSONPatch", results); err != nil {
+			return nil, err
+		}
+	}
+
+	successCount := 0
+	for _, result := range results {
+		a.stats.FilesProcessed.Add(1)
+		if result.Success {
+			successCount++
+		}
+	}
+
+	a.logOperation("ApplyJSONPatch", time.Since(start), nil, map[string]any{
+		"filesProcessed": len(selectedFiles),
+		"successCount":   successCount,
+		"errorCount":     len(results) - successCount,
+	})
+
+	return results, nil
+}
+
+// MergePatchFiles deep-merges an RFC 7386 merge patch object into the selected files
+func (a *App) MergePatchFiles(patchJSON string, selectedFiles []string, dryRun bool) ([]jsonops.MergePatchResult, error) {
+	if err := a.checkPaths(selectedFiles...); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	a.stats.UpdateOperations.Add(1)
+
+	request := jsonops.MergePatchRequest{
+		Patch:         patchJSON,
+		SelectedFiles: selectedFiles,
+		DryRun:        true, // the changes are written by writeResults
+		Syntaxes:      a.syntaxes(),
+	}
+
+	results, err := jsonops.MergePatchInFiles(request)
+	if err != nil {
+		a.logOperation("MergePatchFiles", time.Since(start), err, map[string]any{
+			"files": len(selectedFiles),
+		})
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := writeResults(a, "MergePatchFiles", results); err != nil {
+			return nil, err
+		}
+	}
+
+	successCount := 0
+	skippedCount := 0
+	errorCount := 0
+	for _, result := range results {
+		a.stats.FilesProcessed.Add(1)
+		if result.Success {
+			successCount++
+		} else if result.Skipped {
+			skippedCount++
+		} else {
+			errorCount++
+		}
+	}
+
+	a.logOperation("MergePatchFiles", time.Since(start), nil, map[string]any{
+		"filesProcessed": len(selectedFiles),
+		"successCount":   successCount,
+		"skippedCount":   skippedCount,
+		"errorCount":     errorCount,
+	})
+
+	return results, nil
+}
+
+// writeResults writes the changes previewed by a batch operation. Files whose
+// change cannot be written fail in their results.
+func writeResults[T any, R interface {
+	*T
+	jsonops.FileResult
+}](a *App, operation string, results []T) error {
+	var changes []jsonops.FileChange
+	byPath := make(map[string]R)
+	for i := range results {
+		result := R(&results[i])
+		if outcome := result.Outcome(); outcome.Success && outcome.Change != nil {
+			changes = append(changes, *outcome.Change)
+			byPath[outcome.FilePath] = result
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	commits, err := a.commitChanges(operation, changes)
+	if err != nil {
+		return err
+	}
+	for _, commit := range commits {
+		if !commit.Success {
+			byPath[commit.FilePath].Fail(commit.Error)
+		} else if commit.Warning != "" {
+			byPath[commit.FilePath].Warn(commit.Warning)
+		}
+	}
+	return nil
+}
+
+// commitChanges writes previewed changes for a batch operation. The pre-image of
+// every file goes to the undo journal before any file is written, and its entry is
+// completed with the files that were, so even an interrupted batch can be undone.
+// Nothing is written when the journal cannot be written; when only completing the
+// entry fails, the written files carry a warning instead.
+func (a *App) commitChanges(operation string, changes []jsonops.FileChange) ([]jsonops.CommitResult, error) {
+	var entry *journal.Entry
+	results, err := jsonops.CommitChanges(changes, a.syntaxes(), func(checked []jsonops.FileChange) error {
+		if a.journal == nil {
+			return nil
+		}
+		files := make([]journal.FileRecord, 0, len(checked))
+		for _, change := range checked {
+			files = append(files, journal.FileRecord{
+				FilePath:   change.FilePath,
+				PreHash:    change.BaseHash,
+				PostHash:   fileops.ContentHash([]byte(change.ModifiedContent)),
+				PreContent: change.Original,
+			})
+		}
+		var err error
+		if entry, err = a.journal.Begin(operation, files); err != nil {
+			return fmt.Errorf("failed to record %s in the undo journal, no file was written: %v", operation, err)
+		}
+		return nil
+	})
+	if err != nil || entry == nil {
+		return results, err
+	}
+
+	var written []string
+	for _, result := range results {
+		if result.Success {
+			written = append(written, result.FilePath)
+		}
+	}
+	if err := a.journal.Commit(entry, written); err != nil {
+		// The files are written either way, and the pending entry still undoes them
+		warning := fmt.Sprintf("%s wrote %d files, but its undo journal entry %s could not be completed: %v; it can still be undone", operation, len(written), entry.ID, err)
+		log.Printf("⚠️ %s", warning)
+		for i := range results {
+			if results[i].Success {
+				results[i].Warn(warning)
+			}
+		}
+		return results, nil
+	}
+	log.Printf("📓 Recorded %s as journal entry %s (%d files)", operation, entry.ID, len(written))
+	return results, nil
+}
+
+// UndoLastOperation restores the files written by the most recent batch operation
+func (a *App) UndoLastOperation() (*journal.UndoReport, error) {
+	if a.journal == nil {
+		return nil, fmt.Errorf("undo journal is not available")
+	}
+
+	start := time.Now()
+	report, err := a.journal.UndoLast()
+	a.logUndo(start, report, err)
+	return report, err
+}
+
+// UndoOperation restores the files written by the batch operation with the given id
+func (a *App) UndoOperation(id string) (*journal.UndoReport, error) {
+	if a.journal == nil {
+		return nil, fmt.Errorf("undo journal is not available")
+	}
+
+	start := time.Now()
+	report, err := a.journal.Undo(id)
+	a.logUndo(start, report, err)
+	return report, err
+}
+
+// GetOperationHistory lists the batch operations recorded in the undo journal, newest first
+func (a *App) GetOperationHistory() ([]journal.Summary, error) {
+	if a.journal == nil {
+		return nil, fmt.Errorf("undo journal is not available")
+	}
+	return a.journal.List()
+}
+
+// logUndo logs the outcome of an undo
+func (a *App) logUndo(start time.Time, report *journal.UndoReport, err error) {
+	details := map[string]any{}
+	if report != nil {
+		restored := 0
+		for _, result := range report.Results {
+			if result.Success {
+				restored++
+			}
+		}
+		details["id"] = report.ID
+		details["operation"] = report.Operation
+		details["filesRestored"] = restored
+		details["filesRefused"] = len(report.Results) - restored
+	}
+	a.logOperation("Undo", time.Since(start), err, details)
+}
@@ -1,9 +1,31 @@
 package main_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"goldenMagic/internal/api"
+	"goldenMagic/internal/config"
+	"goldenMagic/internal/fileops"
+	"goldenMagic/internal/index"
+	"goldenMagic/internal/journal"
+	"goldenMagic/internal/jsoncst"
 	"goldenMagic/internal/jsonops"
+	"goldenMagic/internal/jsonpath"
+	"goldenMagic/internal/tree"
+	"goldenMagic/internal/yamldoc"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -39,6 +61,7 @@ func Test_duplicate_check(t *testing.T) {
 	_, err2 := jsonops.InsertItemAfter(testJSON, "test", "start", newObject2)
 	require.Error(t, err2)
 	require.Contains(t, err2.Error(), "object with key 'start' already exists")
+	require.ErrorIs(t, err2, jsonops.ErrKeyExists)
 
 	// Test 3: Try to add a key at different nesting level (should succeed)
 	fmt.Println("Test 3: Adding 'react' after 'express' in dependencies (should succeed)")
@@ -51,3 +74,1878 @@ func Test_duplicate_check(t *testing.T) {
 		fmt.Println(result3)
 	}
 }
+
+func Test_insert_json_key_value_paths(t *testing.T) {
+	testJSON := `{
+  "id": 1,
+  "user": {
+    "id": 2,
+    "profile": {
+      "address": {
+        "city": "Warsaw"
+      }
+    }
+  },
+  "billing": {
+    "address": {
+      "city": "Berlin"
+    }
+  }
+}`
+
+	// A dotted path only targets the object at that exact location
+	result, err := jsonops.InsertJSONKeyValue(testJSON, "user.profile.address", "zip", "00-001")
+	require.NoError(t, err)
+	require.Contains(t, result, "\"address\": {\n        \"zip\": \"00-001\",\n        \"city\": \"Warsaw\"")
+	require.Contains(t, result, "\"address\": {\n      \"city\": \"Berlin\"")
+
+	// Recursive descent targets every match
+	result, err = jsonops.InsertJSONKeyValue(testJSON, "..address", "zip", "00-001")
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(result, `"zip"`))
+
+	// A same-named key elsewhere in the file must not be picked up: a bare key
+	// selects the member of the root only, and the error points at the recursive form
+	_, err = jsonops.InsertJSONKeyValue(testJSON, "profile", "zip", "00-001")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "path 'profile' not found at the root; use '..profile' to match it at any depth")
+	_, err = jsonops.InsertJSONKeyValue(testJSON, "missing", "zip", "00-001")
+	require.EqualError(t, err, "path 'missing' not found")
+	result, err = jsonops.InsertJSONKeyValue(testJSON, "billing", "vat", true)
+	require.NoError(t, err)
+	require.Contains(t, result, "\"billing\": {\n    \"vat\": true,")
+
+	// Duplicate detection uses the resolved object, not the first line that mentions the key
+	_, err = jsonops.InsertJSONKeyValue(testJSON, "user", "id", 3)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "key 'id' already exists in object 'user'")
+	require.ErrorIs(t, err, jsonops.ErrKeyExists)
+
+	// Files that already have the key are skipped rather than failed, also when the
+	// records of a JSON Lines file report it
+	dir := t.TempDir()
+	jsonPath, linesPath := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.jsonl")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(testJSON), 0644))
+	require.NoError(t, os.WriteFile(linesPath, []byte("{\"id\": 1}\n{\"id\": 2}\n"), 0644))
+	added, err := jsonops.AddItemInFiles(jsonops.AddItemRequest{Key: "id", Value: 3, SelectedFiles: []string{jsonPath, linesPath}, DryRun: true})
+	require.NoError(t, err)
+	for _, result := range added {
+		require.True(t, result.Skipped, result.FilePath)
+	}
+	added, err = jsonops.AddItemInFiles(jsonops.AddItemRequest{ObjectPath: "missing", Key: "id", Value: 3, SelectedFiles: []string{jsonPath}, DryRun: true})
+	require.NoError(t, err)
+	require.False(t, added[0].Success)
+	require.False(t, added[0].Skipped)
+
+	// Array indices are canonical, as in JSON Pointer
+	for expr, valid := range map[string]bool{
+		"items[0]":     true,
+		"items[10].id": true,
+		"items[ 1 ]":   true,
+		"items[*]":     true,
+		"items[+1]":    false,
+		"items[01]":    false,
+		"items[-1]":    false,
+		"items[-0]":    false,
+		"items[1e1]":   false,
+		"items[]":      false,
+	} {
+		_, err := jsonpath.Parse(expr)
+		if valid {
+			require.NoError(t, err, expr)
+		} else {
+			require.ErrorContains(t, err, "invalid array index", expr)
+		}
+	}
+}
+
+func Test_operations_preserve_embedded_json(t *testing.T) {
+	testJSON := "{\n\t\"id\": 1,\n\t\"payload\": \"{\\\"id\\\": 2, \\\"items\\\": [{\\\"id\\\": 3}]}\",\n\t\"meta\": {\n\t\t\"id\": 4\n\t}\n}"
+
+	// Braces and keys inside string values are not structure and must not be touched
+	result, err := jsonops.InsertJSONKeyValue(testJSON, "meta", "region", "eu")
+	require.NoError(t, err)
+	require.Equal(t, "{\n\t\"id\": 1,\n\t\"payload\": \"{\\\"id\\\": 2, \\\"items\\\": [{\\\"id\\\": 3}]}\",\n\t\"meta\": {\n\t\t\"region\": \"eu\",\n\t\t\"id\": 4\n\t}\n}", result)
+
+	result, err = jsonops.InsertItemAfter(testJSON, "payload", "checksum", `"abc"`)
+	require.NoError(t, err)
+	require.Contains(t, result, "[{\\\"id\\\": 3}]}\",\n\t\"checksum\": \"abc\",\n\t\"meta\"")
+}
+
+func Test_delete_json_keys(t *testing.T) {
+	testJSON := `{
+  "id": 1,
+  "legacy": true,
+  "items": [
+    {"id": 2, "debug": "x", "name": "a"},
+    {"id": 3, "name": "b", "debug": "y"}
+  ],
+  "meta": {
+    "debug": {
+      "debug": 1
+    }
+  }
+}`
+
+	// Removing the last member drops the comma of the new last member
+	result, count, err := jsonops.DeleteJSONKeys(`{"a": 1, "b": 2}`, "b")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, `{"a": 1}`, result)
+
+	// A key under an array is removed from every object element
+	result, count, err = jsonops.DeleteJSONKeys(testJSON, "items.debug")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Contains(t, result, `{"id": 2, "name": "a"},`)
+	require.Contains(t, result, `{"id": 3, "name": "b"}`)
+
+	// Nested matches inside a removed member are not counted twice
+	result, count, err = jsonops.DeleteJSONKeys(testJSON, "..debug")
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Contains(t, result, "\"meta\": {}")
+
+	// Deleting every member of an object leaves a valid empty object
+	result, count, err = jsonops.DeleteJSONKeys(testJSON, "$.*")
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+	require.Equal(t, "{}", result)
+}
+
+func Test_set_json_value_modes(t *testing.T) {
+	testJSON := `{
+  "version": "1.0",
+  "features": {
+    "darkMode": false
+  }
+}`
+
+	// Update replaces only the value span
+	result, updated, inserted, err := jsonops.SetJSONValue(testJSON, "version", "2.0", jsonops.SetIfPresent)
+	require.NoError(t, err)
+	require.Equal(t, 1, updated)
+	require.Equal(t, 0, inserted)
+	require.Equal(t, strings.Replace(testJSON, `"1.0"`, `"2.0"`, 1), result)
+
+	// Update never adds a missing key
+	result, updated, inserted, err = jsonops.SetJSONValue(testJSON, "features.beta", true, jsonops.SetIfPresent)
+	require.NoError(t, err)
+	require.Equal(t, 0, updated+inserted)
+	require.Equal(t, testJSON, result)
+
+	// Missing-only leaves existing values alone
+	_, updated, inserted, err = jsonops.SetJSONValue(testJSON, "features.darkMode", true, jsonops.SetIfMissing)
+	require.NoError(t, err)
+	require.Equal(t, 0, updated+inserted)
+
+	// Upsert adds the key after the last member
+	result, updated, inserted, err = jsonops.SetJSONValue(testJSON, "features.beta", true, jsonops.SetUpsert)
+	require.NoError(t, err)
+	require.Equal(t, 0, updated)
+	require.Equal(t, 1, inserted)
+	require.Contains(t, result, "\"darkMode\": false,\n    \"beta\": true\n  }")
+
+	// Setting a value that is already there is a no-op
+	_, updated, inserted, err = jsonops.SetJSONValue(testJSON, "features.darkMode", false, jsonops.SetUpsert)
+	require.NoError(t, err)
+	require.Equal(t, 0, updated+inserted)
+}
+
+func Test_json_patch_operations(t *testing.T) {
+	testJSON := `{
+  "version": "1.0",
+  "legacyName": "svc",
+  "tags": ["a"],
+  "owner": {
+    "team": "core"
+  }
+}`
+
+	ops, err := jsonops.ParseJSONPatch(`[
+		{"op": "test", "path": "/version", "value": "1.0"},
+		{"op": "replace", "path": "/version", "value": "2.0"},
+		{"op": "move", "from": "/legacyName", "path": "/name"},
+		{"op": "add", "path": "/tags/-", "value": "b"},
+		{"op": "copy", "from": "/owner", "path": "/maintainer"},
+		{"op": "remove", "path": "/owner/team"}
+	]`)
+	require.NoError(t, err)
+
+	result, err := jsonops.PatchJSON(testJSON, ops)
+	require.NoError(t, err)
+	require.Equal(t, `{
+  "version": "2.0",
+  "tags": ["a", "b"],
+  "owner": {},
+  "name": "svc",
+  "maintainer": {
+    "team": "core"
+  }
+}`, result)
+
+	// A failing test op reports its index and leaves the document unchanged
+	ops, err = jsonops.ParseJSONPatch(`[
+		{"op": "replace", "path": "/version", "value": "3.0"},
+		{"op": "test", "path": "/owner/team", "value": "web"}
+	]`)
+	require.NoError(t, err)
+
+	result, err = jsonops.PatchJSON(testJSON, ops)
+	var patchErr *jsonops.PatchError
+	require.ErrorAs(t, err, &patchErr)
+	require.Equal(t, 1, patchErr.Index)
+	require.Equal(t, testJSON, result)
+
+	// Test ops compare numbers exactly, whatever their notation
+	ops, err = jsonops.ParseJSONPatch(`[{"op": "test", "path": "/id", "value": 12345678901234567891}]`)
+	require.NoError(t, err)
+	_, err = jsonops.PatchJSON(`{"id": 12345678901234567890}`, ops)
+	require.Error(t, err)
+	ops, err = jsonops.ParseJSONPatch(`[{"op": "test", "path": "/n", "value": 1.50}]`)
+	require.NoError(t, err)
+	_, err = jsonops.PatchJSON(`{"n": 15e-1}`, ops)
+	require.NoError(t, err)
+
+	// Unknown operations are rejected up front
+	_, err = jsonops.ParseJSONPatch(`[{"op": "rename", "path": "/a"}]`)
+	require.Error(t, err)
+
+	// Array indices are digits only, without a sign or a leading zero
+	for _, token := range []string{"+1", "-0", "01", ""} {
+		_, err = jsoncst.ArrayIndex(token, 1)
+		require.Error(t, err, token)
+	}
+	index, err := jsoncst.ArrayIndex("10", 1)
+	require.NoError(t, err)
+	require.Equal(t, 10, index)
+}
+
+func Test_merge_json_patch(t *testing.T) {
+	testJSON := `{
+  "name": "svc",
+  "meta": {
+    "legacy": true,
+    "region": "us"
+  }
+}`
+
+	result, added, updated, removed, err := jsonops.MergeJSONPatch(testJSON, `{"meta": {"region": "eu", "legacy": null, "tier": {"level": 1, "old": null}}}`)
+	require.NoError(t, err)
+	require.Equal(t, 1, added)
+	require.Equal(t, 1, updated)
+	require.Equal(t, 1, removed)
+	require.Equal(t, `{
+  "name": "svc",
+  "meta": {
+    "region": "eu",
+    "tier": {
+      "level": 1
+    }
+  }
+}`, result)
+
+	// Merging the same patch again changes nothing
+	_, added, updated, removed, err = jsonops.MergeJSONPatch(result, `{"meta": {"region": "eu", "legacy": null}}`)
+	require.NoError(t, err)
+	require.Equal(t, 0, added+updated+removed)
+
+	// A non-object patch replaces the whole document
+	result, _, updated, _, err = jsonops.MergeJSONPatch(testJSON, `["a"]`)
+	require.NoError(t, err)
+	require.Equal(t, 1, updated)
+	require.Equal(t, "[\n  \"a\"\n]", result)
+}
+
+func Test_dry_run_and_commit(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.json")
+	original := "{\n  \"version\": \"1.0\"\n}\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(original), 0644))
+
+	results, err := jsonops.SetValuesInFiles(jsonops.SetValueRequest{
+		KeyPath:       "version",
+		Value:         "2.0",
+		Mode:          jsonops.SetIfPresent,
+		SelectedFiles: []string{filePath},
+		DryRun:        true,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.True(t, results[0].Success)
+
+	// The dry run leaves the file untouched and describes the change
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, original, string(content))
+
+	change := results[0].Change
+	require.NotNil(t, change)
+	require.Equal(t, 1, change.LinesAdded)
+	require.Equal(t, 1, change.LinesRemoved)
+	require.Contains(t, change.Diff, "-  \"version\": \"1.0\"")
+	require.Contains(t, change.Diff, "+  \"version\": \"2.0\"")
+
+	// Committing writes exactly the previewed content
+	commits, err := jsonops.CommitChanges([]jsonops.FileChange{*change}, fileops.Syntaxes{}, nil)
+	require.NoError(t, err)
+	require.True(t, commits[0].Success)
+	content, err = os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, change.ModifiedContent, string(content))
+
+	// A stale preview is refused once the file has changed
+	commits, err = jsonops.CommitChanges([]jsonops.FileChange{*change}, fileops.Syntaxes{}, nil)
+	require.NoError(t, err)
+	require.False(t, commits[0].Success)
+	require.Contains(t, commits[0].Error, "changed since the preview")
+}
+
+func Test_undo_journal(t *testing.T) {
+	dir := t.TempDir()
+	j, err := journal.Open(filepath.Join(dir, "journal"))
+	require.NoError(t, err)
+
+	first := filepath.Join(dir, "a.json")
+	second := filepath.Join(dir, "b.json")
+	original := "{\n  \"name\": \"a\"\n}\n"
+	require.NoError(t, os.WriteFile(first, []byte(original), 0644))
+	require.NoError(t, os.WriteFile(second, []byte(original), 0644))
+
+	results, err := jsonops.ReplaceKeyInFiles(jsonops.ReplaceKeyRequest{
+		OldKey:        "name",
+		NewKey:        "title",
+		SelectedFiles: []string{first, second},
+		DryRun:        true,
+	})
+	require.NoError(t, err)
+	var changes []jsonops.FileChange
+	for _, result := range results {
+		require.True(t, result.Success)
+		changes = append(changes, *result.Change)
+	}
+
+	// When the journal cannot be written, no file is
+	_, err = jsonops.CommitChanges(changes, fileops.Syntaxes{}, func([]jsonops.FileChange) error {
+		return fmt.Errorf("disk full")
+	})
+	require.EqualError(t, err, "disk full")
+	content, err := os.ReadFile(first)
+	require.NoError(t, err)
+	require.Equal(t, original, string(content))
+
+	// The entry holds every pre-image before the files are written and is pending
+	// until they are
+	var entry *journal.Entry
+	commits, err := jsonops.CommitChanges(changes, fileops.Syntaxes{}, func(checked []jsonops.FileChange) error {
+		var files []journal.FileRecord
+		for _, change := range checked {
+			current, err := os.ReadFile(change.FilePath)
+			require.NoError(t, err)
+			require.Equal(t, original, string(current))
+			files = append(files, journal.FileRecord{
+				FilePath:   change.FilePath,
+				PreHash:    change.BaseHash,
+				PostHash:   fileops.ContentHash([]byte(change.ModifiedContent)),
+				PreContent: change.Original,
+			})
+		}
+		entry, err = j.Begin("ReplaceKeys", files)
+		return err
+	})
+	require.NoError(t, err)
+	history, err := j.List()
+	require.NoError(t, err)
+	require.True(t, history[0].Pending)
+	require.NoError(t, j.Commit(entry, []string{commits[0].FilePath, commits[1].FilePath}))
+	history, err = j.List()
+	require.NoError(t, err)
+	require.False(t, history[0].Pending)
+	require.Len(t, history[0].Files, 2)
+
+	// A file edited after the operation is left alone
+	require.NoError(t, os.WriteFile(second, []byte(`{"edited": true}`), 0644))
+
+	report, err := j.UndoLast()
+	require.NoError(t, err)
+	require.Equal(t, entry.ID, report.ID)
+	require.True(t, report.Results[0].Success)
+	require.False(t, report.Results[1].Success)
+
+	content, err = os.ReadFile(first)
+	require.NoError(t, err)
+	require.Equal(t, original, string(content))
+
+	// Once the conflict is resolved the rest of the entry can be undone
+	require.NoError(t, os.WriteFile(second, []byte(results[1].ModifiedContent), 0644))
+	report, err = j.Undo(entry.ID)
+	require.NoError(t, err)
+	require.True(t, report.Results[0].Success)
+	require.True(t, report.Results[1].Success)
+
+	_, err = j.UndoLast()
+	require.Error(t, err)
+}
+
+func Test_api_handler(t *testing.T) {
+	release, written := make(chan bool), make(chan bool, 1)
+	bindings := []api.Binding{
+		{Name: "greet", Route: "greet", Func: func(name string, times int) (string, error) {
+			if times < 1 {
+				return "", fmt.Errorf("times must be positive")
+			}
+			return strings.Repeat("hi "+name+" ", times), nil
+		}},
+		{Name: "wait", Route: "wait", Func: func() { <-release }},
+		{Name: "write", Route: "write", Func: func() { written <- true }, Mutates: true},
+	}
+	server := httptest.NewServer(api.NewHandler(bindings, "secret"))
+	defer server.Close()
+
+	post := func(route, token, body string) (int, string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/"+route, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	status, body := post("greet", "secret", `["bob", 2]`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, `"hi bob hi bob "`, body)
+
+	status, _ = post("greet", "", `["bob", 2]`)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = post("greet", "wrong", `["bob", 2]`)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	status, body = post("greet", "secret", `["bob"]`)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Contains(t, body, "expected 2 arguments")
+
+	status, body = post("greet", "secret", `["bob", 0]`)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, body, "times must be positive")
+
+	status, _ = post("missing", "secret", `[]`)
+	require.Equal(t, http.StatusNotFound, status)
+
+	// The browser builds its functions from the bindings the handler lists
+	get := func(token string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/"+api.RoutesRoute, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+	status, body = get("secret")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"greet": "greet", "wait": "wait", "write": "write"}`, body)
+	status, _ = get("wrong")
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	// The token is only accepted in the header
+	resp, err := http.Post(server.URL+"/api/greet?token=secret", "application/json", strings.NewReader(`["bob", 2]`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Calls that do not mutate run alongside each other, but not alongside a mutating one
+	waited := make(chan int)
+	go func() {
+		status, _ := post("wait", "secret", `[]`)
+		waited <- status
+	}()
+	time.Sleep(50 * time.Millisecond)
+	status, _ = post("greet", "secret", `["bob", 1]`)
+	require.Equal(t, http.StatusOK, status)
+
+	go post("write", "secret", `[]`)
+	select {
+	case <-written:
+		t.Fatal("a mutating call ran alongside another call")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	require.Equal(t, http.StatusOK, <-waited)
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the mutating call did not run")
+	}
+}
+
+func Test_base_path_containment(t *testing.T) {
+	base := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(base, "sub"), 0755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(base, "link")))
+
+	cfg, err := config.NewConfig([]string{base})
+	require.NoError(t, err)
+
+	require.True(t, cfg.Contains(filepath.Join(base, "a.json")))
+	require.True(t, cfg.Contains(filepath.Join(base, "sub", "b.json")))
+	require.False(t, cfg.Contains(filepath.Join(outside, "a.json")))
+	require.False(t, cfg.Contains(filepath.Join(base, "..", filepath.Base(outside), "a.json")))
+	require.False(t, cfg.Contains(filepath.Join(base, "link", "a.json")))
+	require.False(t, cfg.Contains(base+"-other/a.json"))
+}
+
+func Test_browse_folders_concurrently(t *testing.T) {
+	var basePaths []string
+	var want []string
+	for _, base := range []string{"b", "a"} {
+		dir := filepath.Join(t.TempDir(), base)
+		basePaths = append(basePaths, dir)
+		for i := 0; i < 20; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("sub%d", i%3), fmt.Sprintf("file%02d.json", i))
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			content := `{"other": 1}`
+			if i%2 == 0 {
+				content = `{"nested": {"target": true}}`
+			}
+			require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("target"), 0644))
+	}
+
+	// Walk order within each base path, base paths in the order given
+	for _, dir := range basePaths {
+		files, err := fileops.BrowseFolder(context.Background(), dir, ".json", fileops.MustParseQuery("target"), fileops.ScanOptions{})
+		require.NoError(t, err)
+		require.Len(t, files, 10)
+		for _, file := range files {
+			want = append(want, file.Path)
+		}
+	}
+
+	for run := 0; run < 5; run++ {
+		files, err := fileops.BrowseFolders(context.Background(), basePaths, "*.json", fileops.MustParseQuery("target"), fileops.ScanOptions{})
+		require.NoError(t, err)
+		var got []string
+		for _, file := range files {
+			got = append(got, file.Path)
+		}
+		require.Equal(t, want, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := fileops.BrowseFolders(ctx, basePaths, ".json", fileops.MustParseQuery("target"), fileops.ScanOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_persistent_index(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "data")
+	indexDir := filepath.Join(dir, "index")
+	require.NoError(t, os.MkdirAll(base, 0755))
+
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(base, name), []byte(content), 0644))
+	}
+	write("a.json", `{"user": {"address": {"city": "Oslo"}}}`)
+	write("b.json", `{"items": [{"id": 1}, {"id": 2}]}`)
+	write("c.json", `{"broken": `)
+
+	search := func(ix *index.Index, filter string) ([]string, index.Stats) {
+		files, err := fileops.BrowseFolders(context.Background(), []string{base}, ".json", nil, fileops.ScanOptions{})
+		require.NoError(t, err)
+		matched, stats, err := ix.Filter(context.Background(), files, filter)
+		require.NoError(t, err)
+		var names []string
+		for _, file := range matched {
+			names = append(names, file.Name)
+		}
+		return names, stats
+	}
+
+	ix, err := index.Open(indexDir, fileops.Syntaxes{})
+	require.NoError(t, err)
+
+	names, stats := search(ix, "city")
+	require.Equal(t, []string{"a.json"}, names)
+	require.Equal(t, index.Stats{Files: 3, Reparsed: 3}, stats)
+
+	// Full member paths, with array indices matching any element
+	names, _ = search(ix, "user.address.city")
+	require.Equal(t, []string{"a.json"}, names)
+	names, _ = search(ix, "items[1].id")
+	require.Equal(t, []string{"b.json"}, names)
+	names, _ = search(ix, "user.city")
+	require.Empty(t, names)
+
+	// Only the changed file is parsed again
+	write("b.json", `{"items": [], "city": "Bergen"}`)
+	names, stats = search(ix, "city")
+	require.Equal(t, []string{"a.json", "b.json"}, names)
+	require.Equal(t, 1, stats.Reparsed)
+
+	// The index survives a restart
+	reopened, err := index.Open(indexDir, fileops.Syntaxes{})
+	require.NoError(t, err)
+	names, stats = search(reopened, "city")
+	require.Equal(t, []string{"a.json", "b.json"}, names)
+	require.Equal(t, 0, stats.Reparsed)
+
+	// Deleted files drop out
+	require.NoError(t, os.Remove(filepath.Join(base, "a.json")))
+	names, _ = search(reopened, "city")
+	require.Equal(t, []string{"b.json"}, names)
+
+	// Files may parse differently with other lenient extensions, so the index is
+	// rebuilt rather than reused
+	lenient, err := index.Open(indexDir, fileops.Syntaxes{LenientExtensions: []string{"json"}})
+	require.NoError(t, err)
+	names, stats = search(lenient, "city")
+	require.Equal(t, []string{"b.json"}, names)
+	require.Equal(t, 2, stats.Reparsed)
+
+	// The index does not know where a file matches; that is located in the files
+	// it selected
+	ok, matches, _, err := fileops.LocateMatches(filepath.Join(base, "b.json"), fileops.MustParseQuery("city"), fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, matches, 1)
+	require.Equal(t, "city", matches[0].Path)
+	require.Equal(t, 1, matches[0].Line)
+	require.Equal(t, 15, matches[0].Column)
+}
+
+func Test_index_matches_full_scan(t *testing.T) {
+	base := t.TempDir()
+	for name, content := range map[string]string{
+		"literal.json": `{"meta.region": "eu"}`,
+		"nested.json":  `{"meta": {"region": "eu"}}`,
+		"deep.json":    `{"data": {"meta": {"region": "us"}}}`,
+		"items.json":   `{"items": [{"meta": {"region": "eu"}}]}`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(base, name), []byte(content), 0644))
+	}
+
+	ix, err := index.Open(t.TempDir(), fileops.Syntaxes{})
+	require.NoError(t, err)
+	all, err := fileops.BrowseFolders(context.Background(), []string{base}, ".json", nil, fileops.ScanOptions{})
+	require.NoError(t, err)
+
+	// Keys containing a dot and member paths are told apart, as in a full scan
+	for _, filter := range []string{"meta.region", `["meta.region"]`, "region", "data.meta.region"} {
+		query := fileops.MustParseQuery(filter)
+		key, ok := query.KeyFilter()
+		require.True(t, ok, filter)
+
+		scanned, err := fileops.BrowseFolders(context.Background(), []string{base}, ".json", query, fileops.ScanOptions{})
+		require.NoError(t, err)
+		require.NotEmpty(t, scanned, filter)
+		candidates, _, err := ix.Filter(context.Background(), all, key)
+		require.NoError(t, err)
+		indexed, err := fileops.FilterByQuery(context.Background(), candidates, query, fileops.Syntaxes{})
+		require.NoError(t, err)
+		require.Equal(t, scanned, indexed, filter)
+		require.Equal(t, len(scanned), len(candidates), filter)
+	}
+}
+
+func Test_browse_exclude_patterns(t *testing.T) {
+	base := t.TempDir()
+	for _, name := range []string{
+		"a.json",
+		"keep/b.json",
+		"build/stale.json",
+		"node_modules/pkg/c.json",
+		".git/objects/d.json",
+		"src/build/e.json",
+		"src/tmp.json",
+		"src/generated/f.json",
+		"src/generated/important.json",
+	} {
+		path := filepath.Join(base, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(base, "src", ".gitignore"), []byte("# generated output\ngenerated/\n!important.json\ntmp.json\n"), 0644))
+
+	browse := func(opts fileops.ScanOptions) []string {
+		files, err := fileops.BrowseFolder(context.Background(), base, ".json", nil, opts)
+		require.NoError(t, err)
+		var rels []string
+		for _, file := range files {
+			rel, err := filepath.Rel(base, file.Path)
+			require.NoError(t, err)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		return rels
+	}
+
+	// Hidden directories are skipped by default
+	require.NotContains(t, browse(fileops.ScanOptions{}), ".git/objects/d.json")
+	require.Contains(t, browse(fileops.ScanOptions{IncludeHidden: true}), ".git/objects/d.json")
+
+	// Unanchored patterns match at any depth, anchored ones only below the base path
+	require.Equal(t, []string{"a.json", "keep/b.json", "src/build/e.json", "src/generated/f.json", "src/generated/important.json", "src/tmp.json"},
+		browse(fileops.ScanOptions{Exclude: []string{"/build/", "node_modules"}}))
+	require.Equal(t, []string{"a.json", "keep/b.json", "src/generated/f.json", "src/generated/important.json", "src/tmp.json"},
+		browse(fileops.ScanOptions{
+			Exclude:         []string{"node_modules/"},
+			BasePathExclude: map[string][]string{base: {"**/build/**"}},
+		}))
+
+	// .gitignore files apply below their own directory
+	require.Equal(t, []string{"a.json", "build/stale.json", "keep/b.json", "node_modules/pkg/c.json", "src/build/e.json"},
+		browse(fileops.ScanOptions{Gitignore: true}))
+	require.Equal(t, []string{"a.json", "keep/b.json"},
+		browse(fileops.ScanOptions{Gitignore: true, Exclude: []string{"build", "node_modules", "src/*/"}}))
+}
+
+func Test_search_queries(t *testing.T) {
+	doc := []byte(`{
+  "status": "FAILED",
+  "httpStatus": 200,
+  "account": {"type": "savings", "balance": 1500.5},
+  "response": {"errorCode": "E42", "message": "Upstream Timeout"},
+  "items": [{"id": 1}, {"id": 7}]
+}`)
+
+	match := func(query string) bool {
+		q, err := fileops.ParseQuery(query)
+		require.NoError(t, err, query)
+		return q.Match(doc)
+	}
+
+	// A lone key matches at any depth, a dotted path only from the root
+	require.True(t, match("errorCode"))
+	require.True(t, match("account.type"))
+	require.False(t, match("type.account"))
+	require.False(t, match("$.errorCode"))
+
+	// Values, regexes and numeric comparisons
+	require.True(t, match(`status == "FAILED"`))
+	require.True(t, match(`account.type = savings`))
+	require.False(t, match(`status == "OK"`))
+	require.True(t, match(`message =~ /timeout/i`))
+	require.False(t, match(`message =~ /timeout/`))
+	require.True(t, match(`/^error/ == /^E\d+$/`))
+	require.True(t, match(`account.balance > 1000 && account.balance <= 1500.5`))
+	require.True(t, match(`items[*].id >= 7`))
+	require.False(t, match(`items[0].id >= 7`))
+
+	// Combinators and grouping
+	require.True(t, match(`errorCode && httpStatus == 200`))
+	require.False(t, match(`errorCode && !(httpStatus == 200)`))
+	require.True(t, match(`missing || (status != "OK" AND NOT missing)`))
+	require.False(t, match(`missing != 1`))
+
+	// Only plain existence checks are answered by the index
+	key, ok := fileops.MustParseQuery("errorCode").KeyFilter()
+	require.True(t, ok)
+	require.Equal(t, "errorCode", key)
+	key, ok = fileops.MustParseQuery("account.type").KeyFilter()
+	require.True(t, ok)
+	require.Equal(t, "account.type", key)
+	key, ok = fileops.MustParseQuery(`["account.type"]`).KeyFilter()
+	require.True(t, ok)
+	require.Equal(t, `["account.type"]`, key)
+	_, ok = fileops.MustParseQuery(`status == "FAILED"`).KeyFilter()
+	require.False(t, ok)
+
+	for _, bad := range []string{`status ==`, `(errorCode`, `status > "x"`, `a && && b`, `/[/`} {
+		_, err := fileops.ParseQuery(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func Test_value_search(t *testing.T) {
+	doc := []byte(`{
+  "accountNumber": "ACC-1234",
+  "ACC-1234": "key only",
+  "note": "transfer from ACC-1234 pending",
+  "payment": {"iban": "acc-9876", "amount": 42, "settled": true, "reference": null},
+  "history": [{"account": "ACC-1234"}, "ACC-1234", 42]
+}`)
+
+	hits := func(search fileops.ValueSearch) []string {
+		paths, err := fileops.ContainsValueDeep(doc, search)
+		require.NoError(t, err)
+		return paths
+	}
+
+	// A literal equals the whole value and never matches keys
+	require.Equal(t, []string{"accountNumber", "history[0].account", "history[1]"}, hits(fileops.ValueSearch{Pattern: "ACC-1234"}))
+
+	// A regex matches any part of a value
+	require.Equal(t, []string{"accountNumber", "note", "payment.iban", "history[0].account", "history[1]"},
+		hits(fileops.ValueSearch{Pattern: `acc-\d+`, Regex: true, IgnoreCase: true}))
+	require.Equal(t, []string{"payment.iban"}, hits(fileops.ValueSearch{Pattern: `acc-\d+`, Regex: true}))
+
+	// Numbers and booleans match by their JSON text, null never matches
+	require.Equal(t, []string{"payment.amount", "history[2]"}, hits(fileops.ValueSearch{Pattern: "42"}))
+	require.Equal(t, []string{"payment.settled"}, hits(fileops.ValueSearch{Pattern: "true"}))
+	require.Empty(t, hits(fileops.ValueSearch{Pattern: "null"}))
+
+	// Keys restrict the search to the values of those members
+	require.Equal(t, []string{"accountNumber", "history[0].account"},
+		hits(fileops.ValueSearch{Pattern: "ACC-1234", Keys: []string{"account", "accountNumber"}}))
+
+	_, err := fileops.ContainsValueDeep(doc, fileops.ValueSearch{})
+	require.Error(t, err)
+	_, err = fileops.ContainsValueDeep(doc, fileops.ValueSearch{Pattern: "[", Regex: true})
+	require.Error(t, err)
+
+	// Browsing with a value search reports the matching paths per file
+	base := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(base, "hit.json"), doc, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "miss.json"), []byte(`{"accountNumber": "ACC-5555"}`), 0644))
+	query, err := fileops.ValueSearch{Pattern: "ACC-1234", Keys: []string{"accountNumber"}}.Query()
+	require.NoError(t, err)
+	files, err := fileops.BrowseFolder(context.Background(), base, ".json", query, fileops.ScanOptions{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "hit.json", files[0].Name)
+	require.Equal(t, "accountNumber", files[0].Matches[0].Path)
+}
+
+func Test_match_locations(t *testing.T) {
+	base := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(base, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "one.json"), []byte("{\n  \"errorCode\": \"E1\"\n}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "nested", "many.json"), []byte(`{
+  "errors": [
+    {"errorCode": "E2"},
+    {"errorCode": {"id": 3}}
+  ],
+  "errorCode": "E4"
+}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "none.json"), []byte(`{"ok": true}`), 0644))
+
+	files, err := fileops.BrowseFolder(context.Background(), base, ".json", fileops.MustParseQuery("errorCode"), fileops.ScanOptions{})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	byName := map[string]fileops.JSONFile{}
+	for _, file := range files {
+		byName[file.Name] = file
+	}
+
+	// Spans start at the key and end just past the value
+	require.Equal(t, 1, byName["one.json"].MatchCount)
+	require.Equal(t, []fileops.Match{{Path: "errorCode", Line: 2, Column: 3, EndLine: 2, EndColumn: 20}}, byName["one.json"].Matches)
+
+	many := byName["many.json"]
+	require.Equal(t, 3, many.MatchCount)
+	require.Equal(t, []fileops.Match{
+		{Path: "errors[0].errorCode", Line: 3, Column: 6, EndLine: 3, EndColumn: 23},
+		{Path: "errors[1].errorCode", Line: 4, Column: 6, EndLine: 4, EndColumn: 28},
+		{Path: "errorCode", Line: 6, Column: 3, EndLine: 6, EndColumn: 20},
+	}, many.Matches)
+
+	// The tree totals the matches of every directory
+	root := tree.BuildFileTreeFromMultiplePaths(files, []string{base})
+	require.Equal(t, 2, root.Count)
+	require.Equal(t, 4, root.MatchCount)
+	require.Len(t, root.Children, 1)
+	require.Equal(t, 3, root.Children[0].MatchCount)
+
+	// Results answered by the index get the same locations
+	ix, err := index.Open(t.TempDir(), fileops.Syntaxes{})
+	require.NoError(t, err)
+	all, err := fileops.BrowseFolder(context.Background(), base, ".json", nil, fileops.ScanOptions{})
+	require.NoError(t, err)
+	candidates, _, err := ix.Filter(context.Background(), all, "errorCode")
+	require.NoError(t, err)
+	indexed, err := fileops.FilterByQuery(context.Background(), candidates, fileops.MustParseQuery("errorCode"), fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, files, indexed)
+}
+
+func Test_key_catalog(t *testing.T) {
+	base := t.TempDir()
+	write := func(name, content string) fileops.JSONFile {
+		path := filepath.Join(base, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return fileops.JSONFile{Name: name, Path: path, BasePath: base}
+	}
+	files := []fileops.JSONFile{
+		write("a.json", `{"user": {"name": "a", "tags": [{"id": 1}, {"id": 2}]}, "id": 3}`),
+		write("b.json", `{"user": {"name": "b", "userId": 7}}`),
+		write("c.json", `{"broken": `),
+	}
+
+	ix, err := index.Open(t.TempDir(), fileops.Syntaxes{})
+	require.NoError(t, err)
+	catalog, _, err := ix.Catalog(context.Background(), files)
+	require.NoError(t, err)
+
+	// Counts are the number of files containing the key or path; invalid files are skipped
+	require.Equal(t, 2, catalog.Files)
+	require.Equal(t, []index.Term{
+		{Kind: index.KindKey, Text: "name", Files: 2},
+		{Kind: index.KindKey, Text: "user", Files: 2},
+		{Kind: index.KindKey, Text: "id", Files: 1},
+		{Kind: index.KindKey, Text: "tags", Files: 1},
+		{Kind: index.KindKey, Text: "userId", Files: 1},
+	}, catalog.Keys)
+	require.Equal(t, []string{"user", "user.name", "id", "user.tags", "user.tags.*.id", "user.userId"}, termTexts(catalog.Paths))
+
+	// Building the catalog without an index gives the same result
+	parsed, err := index.BuildCatalog(context.Background(), files, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Equal(t, catalog, parsed)
+
+	// Completion ignores case, puts the most common first and can be limited to a kind
+	require.Equal(t, []string{"user", "user", "user.name", "userId", "user.tags", "user.tags.*.id", "user.userId"}, termTexts(catalog.Complete("USER", "", 0)))
+	require.Equal(t, []string{"user.name", "user.tags", "user.tags.*.id", "user.userId"}, termTexts(catalog.Complete("user.", index.KindPath, 0)))
+	require.Equal(t, []string{"user", "userId"}, termTexts(catalog.Complete("us", index.KindKey, 0)))
+	require.Len(t, catalog.Complete("", "", 3), 3)
+	require.Empty(t, catalog.Complete("missing", "", 0))
+}
+
+func termTexts(terms []index.Term) []string {
+	texts := []string{}
+	for _, term := range terms {
+		texts = append(texts, term.Text)
+	}
+	return texts
+}
+
+func Test_occurrence_selection(t *testing.T) {
+	testJSON := `{
+  "request": {"headers": {"id": 1}},
+  "response": {
+    "headers": {"id": 2},
+    "items": [{"headers": {"id": 3}}]
+  }
+}`
+
+	// Candidates are listed in document order with the selected ones marked
+	candidates, err := jsonops.TargetKeyCandidates(testJSON, "headers", jsonops.Occurrence{Mode: jsonops.OccurrenceLast})
+	require.NoError(t, err)
+	require.Equal(t, []jsonops.Candidate{
+		{Path: "request.headers", Line: 2, Column: 26, Kind: "object"},
+		{Path: "response.headers", Line: 4, Column: 16, Kind: "object"},
+		{Path: "response.items[0].headers", Line: 5, Column: 27, Kind: "object", Selected: true},
+	}, candidates)
+
+	candidates, err = jsonops.ObjectPathCandidates(testJSON, "..headers", jsonops.Occurrence{})
+	require.NoError(t, err)
+	require.Len(t, candidates, 3)
+	for _, candidate := range candidates {
+		require.True(t, candidate.Selected)
+	}
+
+	// Both operations apply to every candidate by default and accept the same selectors
+	insertAfter := func(occurrence jsonops.Occurrence) string {
+		result, err := jsonops.InsertItemAfterAt(testJSON, "headers", "trace", `true`, occurrence)
+		require.NoError(t, err)
+		return result
+	}
+	addTo := func(occurrence jsonops.Occurrence) string {
+		result, err := jsonops.InsertJSONKeyValueAt(testJSON, "..headers", "trace", true, occurrence)
+		require.NoError(t, err)
+		return result
+	}
+	require.Equal(t, 3, strings.Count(insertAfter(jsonops.Occurrence{}), `"trace"`))
+	require.Equal(t, 3, strings.Count(addTo(jsonops.Occurrence{}), `"trace"`))
+
+	first := insertAfter(jsonops.Occurrence{Mode: jsonops.OccurrenceFirst})
+	require.Contains(t, first, `"request": {"headers": {"id": 1}, "trace": true}`)
+	require.Equal(t, 1, strings.Count(first, `"trace"`))
+
+	nth := addTo(jsonops.Occurrence{Mode: jsonops.OccurrenceNth, N: 2})
+	require.Contains(t, nth, `"headers": {"trace": true, "id": 2}`)
+	require.Equal(t, 1, strings.Count(nth, `"trace"`))
+
+	paths := addTo(jsonops.Occurrence{Mode: jsonops.OccurrencePaths, Paths: []string{"request.headers", "response.items[0].headers"}})
+	require.Contains(t, paths, `{"headers": {"trace": true, "id": 1}}`)
+	require.Contains(t, paths, `[{"headers": {"trace": true, "id": 3}}]`)
+	require.Equal(t, 2, strings.Count(paths, `"trace"`))
+
+	// Selecting a location that does not exist fails instead of changing another one
+	_, err = jsonops.InsertItemAfterAt(testJSON, "headers", "trace", `true`, jsonops.Occurrence{Mode: jsonops.OccurrenceNth, N: 4})
+	require.ErrorContains(t, err, "occurrence 4 requested but only 3 found")
+	_, err = jsonops.InsertJSONKeyValueAt(testJSON, "..headers", "trace", true, jsonops.Occurrence{Mode: jsonops.OccurrencePaths, Paths: []string{"headers"}})
+	require.ErrorContains(t, err, "not one of the 3 candidate locations")
+	require.Error(t, jsonops.Occurrence{Mode: jsonops.OccurrencePaths, Paths: []string{"..headers"}}.Validate())
+
+	for text, want := range map[string]jsonops.Occurrence{
+		"":      {},
+		"all":   {},
+		"First": {Mode: jsonops.OccurrenceFirst},
+		"last":  {Mode: jsonops.OccurrenceLast},
+		"2":     {Mode: jsonops.OccurrenceNth, N: 2},
+	} {
+		occurrence, err := jsonops.ParseOccurrence(text)
+		require.NoError(t, err, text)
+		require.Equal(t, want, occurrence, text)
+	}
+	_, err = jsonops.ParseOccurrence("0")
+	require.Error(t, err)
+
+	// The file analysis flags ambiguous files
+	dir := t.TempDir()
+	single := filepath.Join(dir, "single.json")
+	multi := filepath.Join(dir, "multi.json")
+	require.NoError(t, os.WriteFile(single, []byte(`{"headers": {}}`), 0644))
+	require.NoError(t, os.WriteFile(multi, []byte(testJSON), 0644))
+	report := jsonops.FindCandidatesInFiles([]string{single, multi}, func(jsonStr string) ([]jsonops.Candidate, error) {
+		return jsonops.TargetKeyCandidates(jsonStr, "headers", jsonops.Occurrence{})
+	})
+	require.Len(t, report, 2)
+	require.False(t, report[0].Ambiguous)
+	require.True(t, report[1].Ambiguous)
+	require.Len(t, report[1].Candidates, 3)
+}
+
+func Test_scoped_key_rename(t *testing.T) {
+	testJSON := `{
+  "id": 1,
+  "payload": "{\"id\": 2}",
+  "response": {
+    "body": {
+      "id": 3,
+      "items": [{"id": 4, "name": "a"}, {"id": 5}]
+    }
+  }
+}`
+
+	// Without a scope every real key is renamed, never text inside strings
+	result, count, err := jsonops.RenameJSONKeys(testJSON, "id", "uid", jsonops.KeyScope{})
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+	require.Contains(t, result, `"payload": "{\"id\": 2}"`)
+
+	// A parent path limits the rename to the members of the selected objects
+	result, count, err = jsonops.RenameJSONKeys(testJSON, "id", "uid", jsonops.KeyScope{ParentPath: "response.body.items[*]"})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Contains(t, result, `[{"uid": 4, "name": "a"}, {"uid": 5}]`)
+	require.Contains(t, result, `"id": 3,`)
+	require.Contains(t, result, `"id": 1,`)
+
+	// An array stands for its object elements
+	_, count, err = jsonops.RenameJSONKeys(testJSON, "id", "uid", jsonops.KeyScope{ParentPath: "..items"})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// Depth counts every key and index of the member's path
+	result, count, err = jsonops.RenameJSONKeys(testJSON, "id", "uid", jsonops.KeyScope{Depth: 3})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Contains(t, result, `"uid": 3,`)
+	_, count, err = jsonops.RenameJSONKeys(testJSON, "id", "uid", jsonops.KeyScope{ParentPath: "..items", Depth: 1})
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// An existing sibling with the new name is a conflict and nothing is renamed
+	_, _, err = jsonops.RenameJSONKeys(testJSON, "id", "name", jsonops.KeyScope{})
+	var conflict *jsonops.KeyConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.Equal(t, []string{"response.body.items[0]"}, conflict.Paths)
+	_, count, err = jsonops.RenameJSONKeys(testJSON, "id", "name", jsonops.KeyScope{ParentPath: "response.body"})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// The file operation reports the conflict and leaves the file alone
+	filePath := filepath.Join(t.TempDir(), "conflict.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(testJSON), 0644))
+	results, err := jsonops.ReplaceKeyInFiles(jsonops.ReplaceKeyRequest{OldKey: "id", NewKey: "name", SelectedFiles: []string{filePath}})
+	require.NoError(t, err)
+	require.False(t, results[0].Success)
+	require.Equal(t, []string{"response.body.items[0]"}, results[0].Conflicts)
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, testJSON, string(content))
+
+	_, err = jsonops.ReplaceKeyInFiles(jsonops.ReplaceKeyRequest{OldKey: "id", NewKey: "uid", Scope: jsonops.KeyScope{ParentPath: "items["}})
+	require.Error(t, err)
+}
+
+func Test_key_case_conversion(t *testing.T) {
+	testJSON := `{
+  "userId": 1,
+  "HTTPStatus": 200,
+  "$ref": "#/a",
+  "_id": "x",
+  "response": {
+    "body": {
+      "first_name": "a",
+      "_links": {"self_ref": "/"},
+      "items": [{"item-count": 2}]
+    }
+  }
+}`
+
+	// Words split at separators and case changes, a run of capitals is one word
+	for keyCase, want := range map[jsonops.KeyCase]string{
+		jsonops.CamelCase:  "httpStatusCode",
+		jsonops.SnakeCase:  "http_status_code",
+		jsonops.KebabCase:  "http-status-code",
+		jsonops.PascalCase: "HttpStatusCode",
+	} {
+		require.Equal(t, want, jsonops.KeyCaseOptions{Case: keyCase}.Convert("HTTPStatus_code"))
+	}
+	require.Equal(t, "user_id", jsonops.KeyCaseOptions{Case: jsonops.SnakeCase}.Convert("userID"))
+	require.Equal(t, "$ref", jsonops.KeyCaseOptions{Case: jsonops.SnakeCase}.Convert("$ref"))
+
+	// The whole file: keys that are not plain words keep their name
+	result, renames, err := jsonops.ConvertJSONKeys(testJSON, jsonops.KeyCaseOptions{Case: jsonops.SnakeCase})
+	require.NoError(t, err)
+	require.Contains(t, result, `"user_id": 1,`)
+	require.Contains(t, result, `"http_status": 200,`)
+	require.Contains(t, result, `"$ref": "#/a",`)
+	require.Contains(t, result, `"_id": "x",`)
+	require.Contains(t, result, `[{"item_count": 2}]`)
+	require.Contains(t, renames, jsonops.KeyRename{From: "userId", To: "user_id", Count: 1})
+
+	// A path limits the conversion to the keys inside its value, deny excludes keys
+	result, _, err = jsonops.ConvertJSONKeys(testJSON, jsonops.KeyCaseOptions{Case: jsonops.CamelCase, Path: "response.body", Deny: []string{"_links", "self_ref"}})
+	require.NoError(t, err)
+	require.Contains(t, result, `"firstName": "a",`)
+	require.Contains(t, result, `{"self_ref": "/"}`)
+	require.Contains(t, result, `[{"itemCount": 2}]`)
+	require.Contains(t, result, `"HTTPStatus": 200,`)
+
+	// Allow only converts the listed keys
+	result, renames, err = jsonops.ConvertJSONKeys(testJSON, jsonops.KeyCaseOptions{Case: jsonops.KebabCase, Allow: []string{"userId"}})
+	require.NoError(t, err)
+	require.Contains(t, result, `"user-id": 1,`)
+	require.Len(t, renames, 1)
+
+	// Keys of one object that would share a name leave the file unchanged
+	collidingJSON := `{"a": {"user_id": 1, "userId": 2}}`
+	filePath := filepath.Join(t.TempDir(), "collide.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(collidingJSON), 0644))
+	results, err := jsonops.ConvertKeysInFiles(jsonops.ConvertKeysRequest{KeyCaseOptions: jsonops.KeyCaseOptions{Case: jsonops.CamelCase}, SelectedFiles: []string{filePath}})
+	require.NoError(t, err)
+	require.False(t, results[0].Success)
+	require.Equal(t, []jsonops.KeyCollision{{Path: "a", Name: "userId", Keys: []string{"user_id", "userId"}}}, results[0].Collisions)
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, collidingJSON, string(content))
+
+	_, _, err = jsonops.ConvertJSONKeys(testJSON, jsonops.KeyCaseOptions{Case: "upper"})
+	require.Error(t, err)
+	_, _, err = jsonops.ConvertJSONKeys(testJSON, jsonops.KeyCaseOptions{Case: jsonops.CamelCase, Path: "missing"})
+	require.Error(t, err)
+}
+
+func Test_insert_into_minified_json(t *testing.T) {
+	minified := `{"a":1,"b":{"c":2},"items":[{"x":1},{"x":2}],"e":{},"list":[]}`
+
+	// Every insert stays on the single line and uses the compact separators
+	result, err := jsonops.InsertJSONKeyValue(minified, "", "k", map[string]any{"z": []int{1, 2}})
+	require.NoError(t, err)
+	require.Equal(t, `{"k":{"z":[1,2]},"a":1,"b":{"c":2},"items":[{"x":1},{"x":2}],"e":{},"list":[]}`, result)
+
+	result, err = jsonops.InsertJSONKeyValue(minified, "items", "k", true)
+	require.NoError(t, err)
+	require.Contains(t, result, `"items":[{"k":true,"x":1},{"k":true,"x":2}]`)
+
+	result, err = jsonops.InsertJSONKeyValue(minified, "e", "k", 1)
+	require.NoError(t, err)
+	require.Contains(t, result, `"e":{"k":1}`)
+
+	result, err = jsonops.InsertJSONKeyValue(minified, "list", "k", 1)
+	require.NoError(t, err)
+	require.Contains(t, result, `"list":[1]`)
+
+	result, err = jsonops.InsertItemAfter(minified, "c", "new", `{"p": 1, "q": [1, 2]}`)
+	require.NoError(t, err)
+	require.Contains(t, result, `"b":{"c":2,"new":{"p":1,"q":[1,2]}}`)
+
+	result, _, _, err = jsonops.SetJSONValue(minified, "b.d", map[string]any{"n": 1}, jsonops.SetUpsert)
+	require.NoError(t, err)
+	require.Contains(t, result, `"b":{"c":2,"d":{"n":1}}`)
+
+	ops, err := jsonops.ParseJSONPatch(`[{"op": "add", "path": "/b/d", "value": {"n": [1, 2]}}]`)
+	require.NoError(t, err)
+	result, err = jsonops.PatchJSON(minified, ops)
+	require.NoError(t, err)
+	require.Contains(t, result, `"b":{"c":2,"d":{"n":[1,2]}}`)
+
+	// In a pretty-printed file inline objects stay inline and multi-line ones get a new line
+	pretty := "{\n  \"a\": 1,\n  \"b\": {\"c\": 2}\n}"
+	result, err = jsonops.InsertItemAfter(pretty, "c", "new", `{"p":1,"q":[1,2]}`)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"a\": 1,\n  \"b\": {\"c\": 2, \"new\": {\"p\": 1, \"q\": [1, 2]}}\n}", result)
+
+	result, err = jsonops.InsertItemAfter(pretty, "a", "new", `{"p":1}`)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"a\": 1,\n  \"new\": {\n    \"p\": 1\n  },\n  \"b\": {\"c\": 2}\n}", result)
+}
+
+func Test_insert_into_empty_containers(t *testing.T) {
+	// Empty objects and arrays get the new entry without a stray comma
+	result, err := jsonops.InsertJSONKeyValue(`{}`, "", "k", 1)
+	require.NoError(t, err)
+	require.Equal(t, `{"k": 1}`, result)
+
+	result, err = jsonops.InsertJSONKeyValue("{\n}", "", "k", 1)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"k\": 1\n}", result)
+
+	testJSON := "{\n  \"items\": [\n    {},\n    {\"x\": 1}\n  ],\n  \"tags\": [ ],\n  \"meta\": {\"a\": 1, \"b\": {}}\n}"
+	result, err = jsonops.InsertJSONKeyValue(testJSON, "items", "k", true)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"items\": [\n    {\n      \"k\": true\n    },\n    {\"k\": true, \"x\": 1}\n  ],\n  \"tags\": [ ],\n  \"meta\": {\"a\": 1, \"b\": {}}\n}", result)
+
+	result, err = jsonops.InsertJSONKeyValue(testJSON, "tags", "k", "new")
+	require.NoError(t, err)
+	require.Contains(t, result, "\"tags\": [\n    \"new\"\n  ],")
+
+	result, err = jsonops.InsertJSONKeyValue(testJSON, "meta.b", "k", 1)
+	require.NoError(t, err)
+	require.Contains(t, result, `"meta": {"a": 1, "b": {"k": 1}}`)
+
+	// Appending after the last member, with the closing bracket on the same line
+	result, _, _, err = jsonops.SetJSONValue("{\"a\": 1,\n  \"b\": [1,\n    2]}", "c", 3, jsonops.SetUpsert)
+	require.NoError(t, err)
+	require.Equal(t, "{\"a\": 1,\n  \"b\": [1,\n    2],\n  \"c\": 3}", result)
+
+	// Every file operation produces valid JSON for these layouts
+	filePath := filepath.Join(t.TempDir(), "empty.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(testJSON), 0644))
+	results, err := jsonops.AddItemInFiles(jsonops.AddItemRequest{ObjectPath: "..b", Key: "k", Value: []any{}, SelectedFiles: []string{filePath}})
+	require.NoError(t, err)
+	require.True(t, results[0].Success, results[0].Error)
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"b": {"k": []}`)
+
+	// A result that is not valid JSON is rejected and never written
+	change := jsonops.NewFileChange(filePath, content, `{"k": [}`)
+	commits, err := jsonops.CommitChanges([]jsonops.FileChange{change}, fileops.Syntaxes{}, nil)
+	require.NoError(t, err)
+	require.False(t, commits[0].Success)
+	require.Contains(t, commits[0].Error, "not valid JSON")
+	unchanged, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, content, unchanged)
+
+	// The error names the format of the file
+	yamlPath := filepath.Join(t.TempDir(), "empty.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte("a: 1\n"), 0644))
+	change = jsonops.NewFileChange(yamlPath, []byte("a: 1\n"), "a: [\n")
+	commits, err = jsonops.CommitChanges([]jsonops.FileChange{change}, fileops.Syntaxes{}, nil)
+	require.NoError(t, err)
+	require.False(t, commits[0].Success)
+	require.Contains(t, commits[0].Error, "not valid YAML")
+}
+
+func Test_jsonc_lenient_syntax(t *testing.T) {
+	jsonc := `{
+  // Compiler settings
+  "compilerOptions": {
+    "target": "es2020", // keep in sync with node
+    /* strictness */
+    "strict": true,
+    paths: {'@app/*': ['src/*',],},
+  },
+  "exclude": [
+    "dist", // build output
+  ],
+}
+`
+	comments := []string{"// Compiler settings", "// keep in sync with node", "/* strictness */", "// build output"}
+	requireComments := func(content string) {
+		t.Helper()
+		for _, comment := range comments {
+			require.Contains(t, content, comment)
+		}
+	}
+
+	// Strict parsing rejects the comments, lenient parsing decodes the values
+	_, err := jsoncst.Parse(jsonc)
+	require.Error(t, err)
+	doc, err := jsoncst.ParseAs(jsonc, jsoncst.Lenient)
+	require.NoError(t, err)
+	value, err := doc.Value(doc.Root)
+	require.NoError(t, err)
+	require.Equal(t, []any{"src/*"}, value.(map[string]any)["compilerOptions"].(map[string]any)["paths"].(map[string]any)["@app/*"])
+	standard, err := jsoncst.Standard(`{a: 'it\'s "x"', /* c */ "b": [1,],}`)
+	require.NoError(t, err)
+	require.Equal(t, `{"a":"it's \"x\"","b":[1]}`, standard)
+
+	// The syntax is chosen by extension
+	dir := t.TempDir()
+	jsoncPath := filepath.Join(dir, "tsconfig.jsonc")
+	jsonPath := filepath.Join(dir, "tsconfig.json")
+	require.NoError(t, os.WriteFile(jsoncPath, []byte(jsonc), 0644))
+	require.NoError(t, os.WriteFile(jsonPath, []byte(jsonc), 0644))
+	require.Equal(t, jsoncst.Lenient, fileops.Syntaxes{}.SyntaxOf(jsoncPath))
+	require.Equal(t, jsoncst.Strict, fileops.Syntaxes{}.SyntaxOf(jsonPath))
+
+	content, err := fileops.GetJSONFileContent(jsoncPath, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Equal(t, jsonc, content)
+	_, err = fileops.GetJSONFileContent(jsonPath, fileops.Syntaxes{})
+	require.Error(t, err)
+
+	// Search finds keys and values in JSONC files
+	files, err := fileops.BrowseFolder(context.Background(), dir, ".jsonc", fileops.MustParseQuery(`compilerOptions.target == "es2020"`), fileops.ScanOptions{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, 4, files[0].Matches[0].Line)
+
+	// Every mutation keeps the comments exactly
+	added, err := jsonops.AddItemInFiles(jsonops.AddItemRequest{ObjectPath: "compilerOptions", Key: "noEmit", Value: true, SelectedFiles: []string{jsoncPath}, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, added[0].Success, added[0].Error)
+	require.Contains(t, added[0].ModifiedContent, "\"compilerOptions\": {\n    \"noEmit\": true,\n    \"target\"")
+	requireComments(added[0].ModifiedContent)
+
+	inserted, err := jsonops.InsertAfterInFiles(jsonops.InsertAfterRequest{TargetKey: "target", NewObjectKey: "lib", NewObjectJSON: `["dom"]`, SelectedFiles: []string{jsoncPath}, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, inserted[0].Success, inserted[0].Error)
+	require.Contains(t, inserted[0].ModifiedContent, "\"target\": \"es2020\", // keep in sync with node\n    \"lib\": [\n      \"dom\"\n    ],\n    /* strictness */")
+	requireComments(inserted[0].ModifiedContent)
+
+	set, err := jsonops.SetValuesInFiles(jsonops.SetValueRequest{KeyPath: "exclude[0]", Value: "out", Mode: jsonops.SetIfPresent, SelectedFiles: []string{jsoncPath}, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, set[0].Success, set[0].Error)
+	require.Contains(t, set[0].ModifiedContent, `"out", // build output`)
+
+	appended, _, _, err := jsonops.SetJSONValue(jsonc, "compilerOptions.module", "esnext", jsonops.SetUpsert)
+	require.NoError(t, err)
+	require.Contains(t, appended, "paths: {'@app/*': ['src/*',],},\n    \"module\": \"esnext\",\n  },")
+
+	deleted, err := jsonops.DeleteKeysInFiles(jsonops.DeleteKeyRequest{KeyPath: "compilerOptions.target", SelectedFiles: []string{jsoncPath}})
+	require.NoError(t, err)
+	require.True(t, deleted[0].Success, deleted[0].Error)
+	content, err = fileops.GetJSONFileContent(jsoncPath, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.NotContains(t, content, `"target"`)
+	// The comment trailing the deleted member goes with it
+	require.NotContains(t, content, "keep in sync with node")
+	comments = []string{"// Compiler settings", "/* strictness */", "// build output"}
+	requireComments(content)
+
+	// Also when it was the last member, rather than moving onto the new last one
+	for _, tc := range []struct{ path, want string }{
+		{"b", "{\n  \"a\": 1 // keep a\n}"},
+		{"a", "{\n  \"b\": 2 // b\n}"},
+		{"*", "{\n}"},
+	} {
+		trimmed, _, err := jsonops.DeleteJSONKeys("{\n  \"a\": 1, // keep a\n  \"b\": 2 // b\n}", tc.path)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, trimmed, tc.path)
+	}
+
+	renamed, err := jsonops.ReplaceKeyInFiles(jsonops.ReplaceKeyRequest{OldKey: "paths", NewKey: "aliases", SelectedFiles: []string{jsoncPath}})
+	require.NoError(t, err)
+	require.True(t, renamed[0].Success, renamed[0].Error)
+	content, err = fileops.GetJSONFileContent(jsoncPath, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Contains(t, content, `"aliases": {'@app/*'`)
+	requireComments(content)
+
+	// A strict file with comments is refused and left unchanged
+	strict, err := jsonops.AddItemInFiles(jsonops.AddItemRequest{Key: "noEmit", Value: true, SelectedFiles: []string{jsonPath}})
+	require.NoError(t, err)
+	require.False(t, strict[0].Success)
+	require.Contains(t, strict[0].Error, "invalid JSON content")
+	unchanged, err := os.ReadFile(jsonPath)
+	require.NoError(t, err)
+	require.Equal(t, jsonc, string(unchanged))
+
+	// Unless its extension is configured as lenient
+	// JSON5 beyond comments, trailing commas and quoting is rejected
+	for _, json5 := range []string{`{a: 0x1F}`, `{a: Infinity}`, `{a: NaN}`, `{a: .5}`, `{a: 5.}`, `{a: +1}`} {
+		_, err := jsoncst.ParseAs(json5, jsoncst.Lenient)
+		require.Error(t, err, json5)
+	}
+
+	lenient := fileops.Syntaxes{LenientExtensions: []string{"json"}}
+	require.Equal(t, jsoncst.Lenient, lenient.SyntaxOf(jsonPath))
+	require.Equal(t, []string{".json", ".json5", ".jsonc"}, lenient.Lenient())
+	strict, err = jsonops.AddItemInFiles(jsonops.AddItemRequest{Key: "noEmit", Value: true, SelectedFiles: []string{jsonPath}, DryRun: true, Syntaxes: lenient})
+	require.NoError(t, err)
+	require.True(t, strict[0].Success, strict[0].Error)
+}
+
+func Test_ndjson_records(t *testing.T) {
+	jsonl := `{"event":"start","payload":{"id":1}}
+{"event":"error","payload":{"id":2,"error_code":"E42"}}
+
+{"event":"error","payload":{"id":3,"error_code":"E7"}}
+{"event":"stop"}
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stream.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(jsonl), 0644))
+
+	// Records are the non-blank lines
+	records := fileops.SplitRecords(jsonl)
+	require.Len(t, records, 4)
+	require.Equal(t, 4, records[2].Line)
+	require.Equal(t, 3, records[2].Number)
+
+	// The viewer accepts several documents
+	content, err := fileops.GetJSONFileContent(path, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Equal(t, jsonl, content)
+
+	// Search reports the matching records, and each match its record and line
+	files, err := fileops.BrowseFolder(context.Background(), dir, ".jsonl", fileops.MustParseQuery(`event == "error"`), fileops.ScanOptions{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, []int{2, 3}, files[0].Records)
+	require.Len(t, files[0].Matches, 2)
+	require.Equal(t, 3, files[0].Matches[1].Record)
+	require.Equal(t, 4, files[0].Matches[1].Line)
+	require.Equal(t, "event", files[0].Matches[1].Path)
+
+	// Operations apply per record, leaving records they do not apply to unchanged
+	added, err := jsonops.AddItemInFiles(jsonops.AddItemRequest{ObjectPath: "payload", Key: "traceId", Value: nil, SelectedFiles: []string{path}, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, added[0].Success, added[0].Error)
+	require.Equal(t, `{"event":"start","payload":{"traceId":null,"id":1}}
+{"event":"error","payload":{"traceId":null,"id":2,"error_code":"E42"}}
+
+{"event":"error","payload":{"traceId":null,"id":3,"error_code":"E7"}}
+{"event":"stop"}
+`, added[0].ModifiedContent)
+	require.Equal(t, []jsonops.RecordError{{Record: 4, Error: "path 'payload' not found"}}, added[0].RecordErrors)
+
+	candidates := jsonops.FindCandidatesInFiles([]string{path}, func(jsonStr string) ([]jsonops.Candidate, error) {
+		return jsonops.ObjectPathCandidates(jsonStr, "payload", jsonops.Occurrence{})
+	})
+	require.Len(t, candidates[0].Candidates, 3)
+	require.False(t, candidates[0].Ambiguous)
+	require.Equal(t, 3, candidates[0].Candidates[2].Record)
+	require.Equal(t, 4, candidates[0].Candidates[2].Line)
+
+	converted, err := jsonops.ConvertKeysInFiles(jsonops.ConvertKeysRequest{KeyCaseOptions: jsonops.KeyCaseOptions{Case: jsonops.CamelCase}, SelectedFiles: []string{path}, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, converted[0].Success, converted[0].Error)
+	require.Equal(t, []jsonops.KeyRename{{From: "error_code", To: "errorCode", Count: 2}}, converted[0].Renames)
+
+	deleted, err := jsonops.DeleteKeysInFiles(jsonops.DeleteKeyRequest{KeyPath: "payload.error_code", SelectedFiles: []string{path}})
+	require.NoError(t, err)
+	require.True(t, deleted[0].Success, deleted[0].Error)
+	require.Equal(t, 2, deleted[0].DeletedCount)
+	content, err = fileops.GetJSONFileContent(path, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Equal(t, `{"event":"start","payload":{"id":1}}
+{"event":"error","payload":{"id":2}}
+
+{"event":"error","payload":{"id":3}}
+{"event":"stop"}
+`, content)
+
+	// A file fails only when the operation applies to no record
+	skipped, err := jsonops.AddItemInFiles(jsonops.AddItemRequest{ObjectPath: "missing", Key: "x", Value: 1, SelectedFiles: []string{path}, DryRun: true})
+	require.NoError(t, err)
+	require.False(t, skipped[0].Success)
+	require.Contains(t, skipped[0].Error, "record 1:")
+	require.Len(t, skipped[0].RecordErrors, 4)
+
+	// Every record must stay valid
+	require.Error(t, fileops.Syntaxes{}.ValidateContent(path, []byte("{\"a\":1}\n{\"b\":\n2}\n")))
+}
+
+func Test_yaml_goldens(t *testing.T) {
+	golden := `# Golden for GET /users/1
+defaults: &defaults
+  retries: 3 # keep low
+  timeout: 30
+user:
+  # the primary account
+  userName: alice
+  roles: [admin, dev]
+service:
+  <<: *defaults
+  name: api
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "user.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(golden), 0644))
+
+	// The viewer shows the document as written and rejects broken YAML
+	content, err := fileops.GetJSONFileContent(path, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Equal(t, golden, content)
+	brokenPath := filepath.Join(dir, "broken.yaml")
+	require.NoError(t, os.WriteFile(brokenPath, []byte("user: [alice\n"), 0644))
+	_, err = fileops.GetJSONFileContent(brokenPath, fileops.Syntaxes{})
+	require.Error(t, err)
+
+	// Key filtering searches the YAML values, aliases included, and locates matches
+	// in the YAML source
+	files, err := fileops.BrowseFolder(context.Background(), dir, ".yaml", fileops.MustParseQuery(`user.userName == "alice"`), fileops.ScanOptions{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, 7, files[0].Matches[0].Line)
+	require.Equal(t, 3, files[0].Matches[0].Column)
+	files, err = fileops.BrowseFolder(context.Background(), dir, ".yaml", fileops.MustParseQuery(`service["<<"].retries == 3`), fileops.ScanOptions{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// Every edit keeps comments, anchors, aliases and key order
+	added, err := jsonops.AddItemInFiles(jsonops.AddItemRequest{ObjectPath: "user", Key: "active", Value: true, SelectedFiles: []string{path}, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, added[0].Success, added[0].Error)
+	require.Equal(t, `# Golden for GET /users/1
+defaults: &defaults
+  retries: 3 # keep low
+  timeout: 30
+user:
+  active: true
+  # the primary account
+  userName: alice
+  roles: [admin, dev]
+service:
+  <<: *defaults
+  name: api
+`, added[0].ModifiedContent)
+
+	inserted, err := jsonops.InsertAfterInFiles(jsonops.InsertAfterRequest{TargetKey: "userName", NewObjectKey: "profile", NewObjectJSON: `{"locale": "en", "tags": ["a"]}`, SelectedFiles: []string{path}, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, inserted[0].Success, inserted[0].Error)
+	require.Contains(t, inserted[0].ModifiedContent, `  # the primary account
+  userName: alice
+  profile:
+    locale: en
+    tags:
+      - a
+  roles: [admin, dev]
+`)
+
+	renamed, err := jsonops.ReplaceKeyInFiles(jsonops.ReplaceKeyRequest{OldKey: "userName", NewKey: "username", SelectedFiles: []string{path}})
+	require.NoError(t, err)
+	require.True(t, renamed[0].Success, renamed[0].Error)
+	require.Equal(t, 1, renamed[0].ReplacementCount)
+
+	// Renaming a key of an anchor renames it in its aliases too, and counts as one
+	// rename of the YAML document
+	renamed, err = jsonops.ReplaceKeyInFiles(jsonops.ReplaceKeyRequest{OldKey: "timeout", NewKey: "timeoutSeconds", SelectedFiles: []string{path}})
+	require.NoError(t, err)
+	require.True(t, renamed[0].Success, renamed[0].Error)
+	require.Equal(t, 1, renamed[0].ReplacementCount)
+
+	deleted, err := jsonops.DeleteKeysInFiles(jsonops.DeleteKeyRequest{KeyPath: "user.roles", SelectedFiles: []string{path}})
+	require.NoError(t, err)
+	require.True(t, deleted[0].Success, deleted[0].Error)
+
+	content, err = fileops.GetJSONFileContent(path, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Equal(t, `# Golden for GET /users/1
+defaults: &defaults
+  retries: 3 # keep low
+  timeoutSeconds: 30
+user:
+  # the primary account
+  username: alice
+service:
+  <<: *defaults
+  name: api
+`, content)
+
+	// A value reached through an alias cannot change on its own
+	set, err := jsonops.SetValuesInFiles(jsonops.SetValueRequest{KeyPath: `service["<<"].retries`, Value: 5, Mode: jsonops.SetIfPresent, SelectedFiles: []string{path}, DryRun: true})
+	require.NoError(t, err)
+	require.False(t, set[0].Success)
+	require.Contains(t, set[0].Error, "cannot change 'service.<<' on its own: it is an alias of &defaults")
+
+	// Changing the anchor changes its aliases with it, whether the edit targets the
+	// anchor alone or every copy alike
+	for _, keyPath := range []string{"defaults.retries", "..retries"} {
+		set, err = jsonops.SetValuesInFiles(jsonops.SetValueRequest{KeyPath: keyPath, Value: 5, Mode: jsonops.SetIfPresent, SelectedFiles: []string{path}, DryRun: true})
+		require.NoError(t, err)
+		require.True(t, set[0].Success, set[0].Error)
+		require.Contains(t, set[0].ModifiedContent, "  retries: 5 # keep low\n")
+		require.Contains(t, set[0].ModifiedContent, "  <<: *defaults\n")
+	}
+	added, err = jsonops.AddItemInFiles(jsonops.AddItemRequest{ObjectPath: "defaults", Key: "backoff", Value: "linear", SelectedFiles: []string{path}, DryRun: true})
+	require.NoError(t, err)
+	require.True(t, added[0].Success, added[0].Error)
+	require.Contains(t, added[0].ModifiedContent, "defaults: &defaults\n  backoff: linear\n  retries: 3 # keep low\n")
+
+	// Edits are spliced into the source, so blank lines and the spacing of comments
+	// around them stay as they were
+	spaced := `# Golden for GET /orders/17
+
+order:
+  id: 17          # primary key
+  status: open    # open | closed
+  total: 9.5      # in EUR
+
+  # line items
+  items:
+    - sku: a-1    # first
+      qty: 2
+
+    - sku: b-2
+      qty: 1
+
+meta: {source: api, version: 1}
+`
+	spacedPath := filepath.Join(dir, "order.yaml")
+	require.NoError(t, os.WriteFile(spacedPath, []byte(spaced), 0644))
+
+	set, err = jsonops.SetValuesInFiles(jsonops.SetValueRequest{KeyPath: "order.status", Value: "closed", Mode: jsonops.SetIfPresent, SelectedFiles: []string{spacedPath}})
+	require.NoError(t, err)
+	require.True(t, set[0].Success, set[0].Error)
+	deleted, err = jsonops.DeleteKeysInFiles(jsonops.DeleteKeyRequest{KeyPath: "order.total", SelectedFiles: []string{spacedPath}})
+	require.NoError(t, err)
+	require.True(t, deleted[0].Success, deleted[0].Error)
+	added, err = jsonops.AddItemInFiles(jsonops.AddItemRequest{ObjectPath: "meta", Key: "cached", Value: false, SelectedFiles: []string{spacedPath}})
+	require.NoError(t, err)
+	require.True(t, added[0].Success, added[0].Error)
+	renamed, err = jsonops.ReplaceKeyInFiles(jsonops.ReplaceKeyRequest{OldKey: "qty", NewKey: "quantity", SelectedFiles: []string{spacedPath}})
+	require.NoError(t, err)
+	require.True(t, renamed[0].Success, renamed[0].Error)
+	require.Equal(t, 2, renamed[0].ReplacementCount)
+
+	content, err = fileops.GetJSONFileContent(spacedPath, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Equal(t, `# Golden for GET /orders/17
+
+order:
+  id: 17          # primary key
+  status: closed    # open | closed
+
+  # line items
+  items:
+    - sku: a-1    # first
+      quantity: 2
+
+    - sku: b-2
+      quantity: 1
+
+meta: {cached: false, source: api, version: 1}
+`, content)
+
+	// Integers keep every digit, and keys added to the root go below the header
+	bigPath := filepath.Join(dir, "big.yaml")
+	require.NoError(t, os.WriteFile(bigPath, []byte("# Golden for GET /ids\n# generated\nid: 12345678901234567890\ncount: 7\n"), 0644))
+	doc, err := yamldoc.Parse("id: 12345678901234567890\ncount: 7\nhex: 0x1F\n")
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"id\": 12345678901234567890,\n  \"count\": 7,\n  \"hex\": 31\n}", doc.JSON())
+
+	set, err = jsonops.SetValuesInFiles(jsonops.SetValueRequest{KeyPath: "id", Value: json.RawMessage("12345678901234567891"), Mode: jsonops.SetIfPresent, SelectedFiles: []string{bigPath}})
+	require.NoError(t, err)
+	require.True(t, set[0].Success, set[0].Error)
+	added, err = jsonops.AddItemInFiles(jsonops.AddItemRequest{Key: "version", Value: 2, SelectedFiles: []string{bigPath}})
+	require.NoError(t, err)
+	require.True(t, added[0].Success, added[0].Error)
+
+	content, err = fileops.GetJSONFileContent(bigPath, fileops.Syntaxes{})
+	require.NoError(t, err)
+	require.Equal(t, "# Golden for GET /ids\n# generated\nversion: 2\nid: 12345678901234567891\ncount: 7\n", content)
+}
+
+func Test_yaml_alias_limits(t *testing.T) {
+	// An alias inside the value of its own anchor is rejected instead of expanding forever
+	_, err := yamldoc.Parse("a: &a\n  b: *a\n")
+	require.ErrorContains(t, err, "alias *a refers to a value that contains it")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "loop.yaml"), []byte("a: &a\n  b: [1, *a]\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ok.yaml"), []byte("a: &a {b: 1}\nc: *a\n"), 0644))
+	files, err := fileops.BrowseFolder(context.Background(), dir, ".yaml", fileops.MustParseQuery("c.b"), fileops.ScanOptions{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "ok.yaml", filepath.Base(files[0].Path))
+
+	// Aliases of aliases may not expand without limit
+	laughs := "l0: &l0 [x, x, x, x, x, x, x, x, x, x]\n"
+	for i := 1; i <= 8; i++ {
+		prev := fmt.Sprintf("*l%d", i-1)
+		laughs += fmt.Sprintf("l%d: &l%d [%s]\n", i, i, strings.Join([]string{prev, prev, prev, prev, prev, prev, prev, prev, prev, prev}, ", "))
+	}
+	_, err = yamldoc.Parse(laughs)
+	require.ErrorContains(t, err, "aliases expand to more than")
+
+	// Moderate reuse is fine
+	doc, err := yamldoc.Parse("a: &a {x: 1}\nb: *a\nc: [*a, *a]\n")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"x": float64(1)}, doc.Value().(map[string]any)["b"])
+}
+
+func Test_cli_commands(t *testing.T) {
+	// The tests live outside package main, so they run the built command
+	bin := filepath.Join(t.TempDir(), "goldenMagic")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.json":      "{\n  \"name\": \"a\",\n  \"version\": 1\n}\n",
+		"b.json":      "{\n  \"name\": \"b\",\n  \"version\": 1\n}\n",
+		"broken.json": "{\n  \"name\": \n",
+		"skip/c.json": "{\n  \"name\": \"c\"\n}\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	valueFile := filepath.Join(t.TempDir(), "value.json")
+	require.NoError(t, os.WriteFile(valueFile, []byte(`{"z": 1, "a": 12345678901234567890}`), 0644))
+
+	// Scan settings still come from the environment when -base-path is given
+	env := []string{"CONFIG_DIR=" + t.TempDir(), "JSON_MANAGER_EXCLUDE=skip/"}
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "JSON_MANAGER_") && !strings.HasPrefix(v, "CONFIG_DIR=") {
+			env = append(env, v)
+		}
+	}
+
+	a, b, broken := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json"), filepath.Join(dir, "broken.json")
+
+	// The steps run in order against the same files
+	steps := []struct {
+		name      string
+		args      []string
+		stdin     string
+		code      int
+		stdout    []string
+		notStdout []string
+		stderr    string
+		file      string // a file that must contain the fileHas texts afterwards
+		fileHas   []string
+	}{
+		{name: "help", args: []string{"help"}, stdout: []string{"Commands:", "set-value", "Exit codes:"}},
+		{name: "unknown command", args: []string{"frobnicate"}, code: 2, stderr: `unknown command "frobnicate"`},
+		{name: "unknown flag", args: []string{"search", "-nope"}, code: 2, stderr: "flag provided but not defined: -nope"},
+		{name: "command help", args: []string{"set-value", "-h"}, stderr: "Usage: goldenMagic set-value"},
+		{name: "search json", args: []string{"search", "--json"}, stdout: []string{"a.json", "b.json", "broken.json"}, notStdout: []string{"c.json"}},
+		{
+			// Key filters are answered by the search index, which still reports the matches
+			name:      "indexed search",
+			args:      []string{"search", "-key-filter", "name", "--json"},
+			stdout:    []string{`"matchCount": 1`, `"path": "name"`, `"line": 2`},
+			notStdout: []string{"broken.json", "c.json"},
+		},
+		{name: "required flag", args: []string{"set-value", "-path", "version"}, code: 2, stderr: "flag -value is required"},
+		{name: "invalid value", args: []string{"set-value", "-path", "version", "-value", "{bad"}, code: 2, stderr: "invalid JSON value"},
+		{
+			name:    "interspersed dry run",
+			args:    []string{"set-value", a, "-path", "version", b, "-value", "12345678901234567890", "-dry-run"},
+			stdout:  []string{"+  \"version\": 12345678901234567890", "2 would change, 0 skipped, 0 failed"},
+			file:    a,
+			fileHas: []string{`"version": 1` + "\n"},
+		},
+		{
+			name:    "value from file",
+			args:    []string{"add", a, "-key", "extra", "-value", "@" + valueFile},
+			stdout:  []string{"1 changed, 0 skipped, 0 failed"},
+			file:    a,
+			fileHas: []string{`"extra": {"z":1,"a":12345678901234567890}`},
+		},
+		{
+			name:    "value from stdin",
+			args:    []string{"set-value", "-path", "note", "-value", "@-", b},
+			stdin:   `"from stdin"`,
+			file:    b,
+			fileHas: []string{`"note": "from stdin"`},
+		},
+		{
+			name:   "file errors",
+			args:   []string{"set-value", a, broken, "-path", "name", "-value", `"x"`},
+			code:   1,
+			stdout: []string{"ok    " + a, "FAIL  " + broken, "1 changed, 0 skipped, 1 failed"},
+		},
+		{name: "history json", args: []string{"history", "--json"}, stdout: []string{`"operation": "SetJSONValues"`, `"operation": "AddJSONItemToFiles"`}},
+		{
+			name:    "undo json",
+			args:    []string{"undo", "--json"},
+			stdout:  []string{`"operation": "SetJSONValues"`, `"success": true`},
+			file:    a,
+			fileHas: []string{`"name": "a"`},
+		},
+	}
+
+	for _, step := range steps {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(bin, append(step.args, "-base-path", dir)...)
+		cmd.Env = env
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(step.stdin)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		code := 0
+		if err := cmd.Run(); err != nil {
+			exitErr, ok := err.(*exec.ExitError)
+			require.True(t, ok, "%s: %v", step.name, err)
+			code = exitErr.ExitCode()
+		}
+
+		require.Equal(t, step.code, code, "%s: %s%s", step.name, stdout.String(), stderr.String())
+		for _, text := range step.stdout {
+			require.Contains(t, stdout.String(), text, step.name)
+		}
+		for _, text := range step.notStdout {
+			require.NotContains(t, stdout.String(), text, step.name)
+		}
+		require.Contains(t, stderr.String(), step.stderr, step.name)
+		if step.file != "" {
+			content, err := os.ReadFile(step.file)
+			require.NoError(t, err)
+			for _, text := range step.fileHas {
+				require.Contains(t, string(content), text, step.name)
+			}
+		}
+	}
+
+	// The server lists the routes of its bindings, which are the documented ones, and
+	// the browser takes them from there instead of keeping a copy
+	serve := exec.Command(bin, "serve", "-addr", "127.0.0.1:0", "-base-path", dir)
+	serve.Env, serve.Dir = env, dir
+	pipe, err := serve.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, serve.Start())
+	defer func() {
+		serve.Process.Kill()
+		serve.Wait()
+	}()
+
+	var addr, token string
+	for lines := bufio.NewScanner(pipe); token == "" && lines.Scan(); {
+		if rest, ok := strings.CutPrefix(lines.Text(), "Serving goldenMagic on "); ok {
+			addr = rest
+		}
+		if rest, ok := strings.CutPrefix(lines.Text(), "Token: "); ok {
+			token = rest
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, addr+"/api/"+api.RoutesRoute, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var routes map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&routes))
+
+	readme, err := os.ReadFile("README.md")
+	require.NoError(t, err)
+	table := string(readme)[strings.Index(string(readme), "| Route | Arguments |"):]
+	table = table[:strings.Index(table, "\n\n")]
+	var documented []string
+	for _, row := range strings.Split(table, "\n")[2:] {
+		for _, route := range regexp.MustCompile("`([^`]+)`").FindAllStringSubmatch(strings.Split(row, "|")[1], -1) {
+			documented = append(documented, route[1])
+		}
+	}
+	apiJS, err := os.ReadFile(filepath.Join("frontend", "js", "api.js"))
+	require.NoError(t, err)
+
+	var served []string
+	for _, route := range routes {
+		served = append(served, route)
+		require.NotContains(t, string(apiJS), "'"+route+"'")
+	}
+	require.ElementsMatch(t, documented, served)
+}